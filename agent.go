@@ -0,0 +1,194 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tmc/aistudio/api"
+)
+
+const (
+	// defaultAgentMaxSteps bounds the model requests of a sub-agent.
+	defaultAgentMaxSteps = 10
+
+	// agentToolTimeout is how long a dispatch_agent call may run.
+	agentToolTimeout = 10 * time.Minute
+
+	// maxAgentTranscriptLine is the longest transcript line reported for a
+	// tool call made by a sub-agent.
+	maxAgentTranscriptLine = 200
+)
+
+// defaultAgentSystemPrompt is the system prompt of sub-agents.
+const defaultAgentSystemPrompt = `You are a research agent working on a task for another assistant.
+Use your tools to look at files and web pages; you cannot change anything.
+Work through the task on your own, then answer with what you found: be concise and specific, and include file paths, line numbers and URLs where they help.
+Your answer is the only thing the other assistant will see.`
+
+// agentToolNames are the tools a sub-agent may use. They read files and web
+// pages without changing anything, so their calls need no approval. web_fetch
+// is only included when limited to an allowlist: fetching any URL would let
+// the sub-agent send the files it reads anywhere.
+var agentToolNames = []string{"read_file", "glob", "grep", "list_directory", "web_fetch", "read_artifact"}
+
+// SubAgent runs a child conversation for the dispatch_agent tool: a task is
+// sent to its own model, with its own system prompt and a restricted tool
+// set, and the final answer returned.
+type SubAgent struct {
+	Client       *api.Client
+	Config       api.StreamClientConfig // Model and generation settings
+	Tools        *ToolManager           // The tools the child may call, without approval; may be nil
+	SystemPrompt string                 // Defaults to defaultAgentSystemPrompt
+	MaxSteps     int                    // Model requests allowed (default 10)
+}
+
+// Run sends prompt to a new child conversation and returns its final answer.
+// The child's text and tool calls are passed to progress as transcript
+// lines as they happen.
+func (a *SubAgent) Run(ctx context.Context, prompt string, progress func(line string)) (string, error) {
+	config := a.Config
+	config.SystemPrompt = a.SystemPrompt
+	if config.SystemPrompt == "" {
+		config.SystemPrompt = defaultAgentSystemPrompt
+	}
+	// The child is a plain request/response conversation
+	config.EnableWebSocket = false
+	config.EnableAudio = false
+	config.ResponseMimeType = ""
+	config.ResponseSchemaFile = ""
+	config.IncludeThoughts = false
+
+	chat := NewChat(a.Client, config, a.Tools)
+	chat.MaxSteps = a.MaxSteps
+	if chat.MaxSteps <= 0 {
+		chat.MaxSteps = defaultAgentMaxSteps
+	}
+	stream, err := chat.Send(ctx, TextPart(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	// Text arrives in fragments; report it a line at a time
+	var text strings.Builder
+	flush := func() {
+		for _, line := range strings.Split(strings.TrimSpace(text.String()), "\n") {
+			if line != "" {
+				progress(line)
+			}
+		}
+		text.Reset()
+	}
+	var answer string
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			flush()
+			return "", err
+		}
+		switch ev := ev.(type) {
+		case TextDeltaEvent:
+			text.WriteString(ev.Text)
+		case ToolCallEvent:
+			flush()
+			progress(truncateLine(fmt.Sprintf("→ %s %s", ev.Call.Name, ev.Call.Arguments), maxAgentTranscriptLine))
+		case ToolResultEvent:
+			if ev.Err != nil {
+				progress(truncateLine(fmt.Sprintf("  ✗ %s: %v", ev.Call.Name, ev.Err), maxAgentTranscriptLine))
+			} else {
+				progress("  ✓ " + ev.Call.Name)
+			}
+		case TurnCompleteEvent:
+			flush()
+			answer = ev.Text
+			if ev.BlockedReason != "" {
+				return "", fmt.Errorf("sub-agent response blocked: %s", ev.BlockedReason)
+			}
+		}
+	}
+	if strings.TrimSpace(answer) == "" {
+		return "", errors.New("sub-agent finished without an answer")
+	}
+	return answer, nil
+}
+
+// truncateLine shortens s to at most n bytes, marking the cut.
+func truncateLine(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "…"
+}
+
+// agentConfig returns the settings of a sub-agent using model, or the
+// conversation's model when model is empty. Live models only answer over the
+// Live API, which sub-agents do not use, so DefaultModel replaces them.
+func (m *Model) agentConfig(model string) api.StreamClientConfig {
+	config := m.chatConfig() // chat_tui.go
+	if model != "" {
+		config.ModelName = model
+	} else if api.IsLiveModel(config.ModelName) {
+		config.ModelName = DefaultModel
+	}
+	return config
+}
+
+// readOnlyTools returns a tool manager holding the tools of tm a sub-agent
+// may use. It has its own concurrency limit, so the child's calls do not
+// wait for the dispatch_agent call that started them.
+func (tm *ToolManager) readOnlyTools() *ToolManager {
+	sub := NewToolManager()
+	sub.workspace = tm.workspace
+	sub.artifacts, sub.resultLimit = tm.artifacts, tm.resultLimit
+	for _, name := range agentToolNames {
+		if name == "web_fetch" && (tm.webFetcher == nil || len(tm.webFetcher.AllowDomains) == 0) {
+			continue
+		}
+		if tool, ok := tm.RegisteredTools[name]; ok {
+			sub.RegisteredTools[name] = tool
+			sub.RegisteredToolDefs = append(sub.RegisteredToolDefs, &tool.ToolDefinition)
+		}
+	}
+	return sub
+}
+
+// RegisterAgentTool registers the dispatch_agent tool. newAgent returns the
+// sub-agent for a call, so that it gets the tools registered by then.
+func (tm *ToolManager) RegisterAgentTool(newAgent func() *SubAgent) error {
+	description := "Start a sub-agent that works on a task on its own and returns its answer. " +
+		"The sub-agent can read files and fetch web pages but not change anything. " +
+		"Use it for searches and research that would take many steps, such as finding where something is defined or summarizing a set of files. " +
+		"Give it a complete, self-contained task: it does not see this conversation."
+	parameters := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"prompt": {"type": "string", "description": "The task for the sub-agent, with everything it needs to know"}
+		},
+		"required": ["prompt"]
+	}`)
+	err := tm.RegisterStreamingTool("dispatch_agent", description, parameters, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		var params struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+		answer, err := newAgent().Run(ctx, params.Prompt, progress)
+		if err != nil {
+			return nil, fmt.Errorf("sub-agent failed: %w", err)
+		}
+		return map[string]any{"answer": answer}, nil
+	})
+	if err != nil {
+		return err
+	}
+	return tm.SetToolTimeout("dispatch_agent", agentToolTimeout)
+}
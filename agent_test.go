@@ -0,0 +1,127 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+
+	"github.com/tmc/aistudio/api"
+)
+
+// TestDispatchAgent tests a sub-agent reading a file with its read-only tools
+// and returning its answer, with its transcript as the call's progress
+func TestDispatchAgent(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{TextPart("Let me look."), functionCallPart("read_file", map[string]any{"file_path": "VERSION"})},
+		{TextPart("The version is 1.2.3.")},
+	}}
+	client := newFakeChatClient(t, svc)
+
+	tm := NewToolManager()
+	tm.SetWorkspace(newTestWorkspace(t, map[string]string{"VERSION": "1.2.3\n"}))
+	if err := tm.RegisterFileTools(); err != nil {
+		t.Fatal(err)
+	}
+	err := tm.RegisterAgentTool(func() *SubAgent {
+		return &SubAgent{Client: client, Config: api.StreamClientConfig{ModelName: "models/cheap", EnableWebSocket: true}, Tools: tm.readOnlyTools()}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []string
+	call := ToolCall{ID: "1", Name: "dispatch_agent", Arguments: json.RawMessage(`{"prompt":"What version is this?"}`)}
+	resp, err := tm.ExecuteWithProgress(context.Background(), call, func(line string) {
+		progress = append(progress, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Response.AsMap()["result"]; !reflect.DeepEqual(got, map[string]any{"answer": "The version is 1.2.3."}) {
+		t.Errorf("Got result %v", got)
+	}
+	want := []string{"Let me look.", `→ read_file {"file_path":"VERSION"}`, "  ✓ read_file", "The version is 1.2.3."}
+	if !reflect.DeepEqual(progress, want) {
+		t.Errorf("Got transcript %q, want %q", progress, want)
+	}
+
+	req := svc.request(0)
+	if req.Model != "models/cheap" || !strings.Contains(req.SystemInstruction.GetParts()[0].GetText(), "research agent") {
+		t.Errorf("Expected the sub-agent's model and system prompt, got %q and %v", req.Model, req.SystemInstruction)
+	}
+	var tools []string
+	for _, decl := range req.Tools[0].FunctionDeclarations {
+		tools = append(tools, decl.Name)
+	}
+	for _, name := range tools {
+		if name == "write_file" || name == "edit_file" || name == "dispatch_agent" {
+			t.Errorf("Expected only read-only tools for the sub-agent, got %v", tools)
+		}
+	}
+}
+
+// TestReadOnlyToolsWebFetch tests the sub-agent only gets web_fetch when it
+// is limited to an allowlist, as its calls are not approved
+func TestReadOnlyToolsWebFetch(t *testing.T) {
+	for _, tt := range []struct {
+		allow []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"go.dev"}, true},
+	} {
+		tm := NewToolManager()
+		if err := tm.RegisterWebFetchTool(&WebFetcher{AllowDomains: tt.allow}); err != nil {
+			t.Fatal(err)
+		}
+		if _, got := tm.readOnlyTools().RegisteredTools["web_fetch"]; got != tt.want {
+			t.Errorf("With allowlist %v, sub-agent has web_fetch = %v, want %v", tt.allow, got, tt.want)
+		}
+	}
+}
+
+// TestAgentConfig tests the sub-agent uses the conversation's model unless
+// it is a Live model, which sub-agents cannot use
+func TestAgentConfig(t *testing.T) {
+	for _, tt := range []struct {
+		conversation, agent, want string
+	}{
+		{"models/gemini-2.5-pro", "", "models/gemini-2.5-pro"},
+		{"models/gemini-2.0-flash-live-001", "", DefaultModel},
+		{"models/gemini-2.0-flash-live-001", "models/gemini-2.5-pro", "models/gemini-2.5-pro"},
+	} {
+		m := &Model{modelName: tt.conversation, temperature: 0.3}
+		config := m.agentConfig(tt.agent)
+		if config.ModelName != tt.want || config.Temperature != 0.3 {
+			t.Errorf("With model %q and agent model %q, got %q (temperature %v), want %q", tt.conversation, tt.agent, config.ModelName, config.Temperature, tt.want)
+		}
+	}
+}
+
+// TestToolOutputExpansion tests a tool call's output is collapsed in its result
+// until expanded
+func TestToolOutputExpansion(t *testing.T) {
+	vm := ToolCallViewModel{ID: "1", Name: "dispatch_agent", Status: ToolCallStatusRunning}
+	for _, line := range []string{"one", "two", "three", "four", "five", "six"} {
+		vm.addProgress(line)
+	}
+	if len(vm.Progress) != maxToolProgressLines || len(vm.Output) != 6 {
+		t.Fatalf("Expected the last %d progress lines and all 6 output lines, got %d and %d", maxToolProgressLines, len(vm.Progress), len(vm.Output))
+	}
+	vm.Status = ToolCallStatusCompleted
+	msg := formatToolResultMessageFromViewModel(vm)
+
+	m := &Model{messages: []Message{msg}}
+	r := NewMessageRenderer(m)
+	if got := r.formatMessageText(msg, 0); !strings.Contains(got, "Transcript (6 lines)") || strings.Contains(got, "│ one") {
+		t.Errorf("Expected a collapsed transcript, got:\n%s", got)
+	}
+	m.toggleDetails()
+	if got := r.formatMessageText(msg, 0); !strings.Contains(got, "│ one") || !strings.Contains(got, "│ six") {
+		t.Errorf("Expected the expanded transcript, got:\n%s", got)
+	}
+}
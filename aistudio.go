@@ -279,6 +279,32 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Handle mouse events if needed
 		return m, nil
 
+	case editorFinishedMsg:
+		// Draft edited in $EDITOR
+		m.handleEditorFinished(msg) // editor.go
+		return m, nil
+
+	case pagerFinishedMsg:
+		// Large tool result viewed in $PAGER
+		if msg.err != nil {
+			log.Printf("Pager failed: %v", msg.err)
+			m.messages = append(m.messages, formatError(fmt.Errorf("pager failed: %w", msg.err)))
+		}
+		return m, nil
+
+	case toolEditFinishedMsg:
+		// Proposed file content edited in $EDITOR
+		m.handleToolEditFinished(msg) // tool_diff.go
+		return m, nil
+
+	case toolReloadTickMsg:
+		// Check the tools file and directory for changes
+		return m, m.handleToolReloadTick() // tool_reload.go
+
+	case toolSourceLoadedMsg:
+		m.handleToolSourceLoaded(msg) // tool_reload.go
+		return m, nil
+
 	case playbackTickMsg:
 		// Update audio playback status
 		// Note: UI will be updated automatically via View() call
@@ -318,6 +344,43 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Don't continue ticking if not initializing
 		return m, nil
 
+	case uiUpdateMsg:
+		// Handle the background message, then listen for the next one
+		model, cmd := m.Update(msg.msg)
+		return model, tea.Batch(cmd, m.listenForUIUpdatesCmd())
+
+	case toolCallResultMsg:
+		return m, m.handleToolCallResult(msg)
+
+	case toolBatchResultMsg:
+		return m, m.handleToolBatchResult(msg)
+
+	case toolStartedMsg:
+		if vm, ok := m.toolCallCache[msg.id]; ok && vm.Status.Finished() {
+			return m, nil // The result arrived first
+		}
+		vm := m.getOrCreateToolVM(msg.id, func(vm *ToolCallViewModel) {
+			vm.Status = ToolCallStatusRunning
+			vm.StartedAt = time.Now()
+		})
+		m.replaceToolCallMessage(*vm)
+		return m, nil
+
+	case toolProgressMsg:
+		m.addToolProgress(msg.id, msg.line)
+		return m, nil
+
+	case chatEventMsg: // chat_tui.go
+		return m, m.handleChatEvent(msg)
+
+	case chatDoneMsg: // chat_tui.go
+		m.handleChatDone(msg)
+		return m, nil
+
+	case chatApprovalMsg: // chat_tui.go
+		m.handleChatApproval(msg)
+		return m, nil
+
 	case func() tea.Msg:
 		// Handle deferred function messages (from uiUpdateChan)
 		return m, tea.Batch(msg) // Execute the function
@@ -383,6 +446,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					Content: fmt.Sprintf("🔧 Function call: %s", msg.FunctionCall.Name),
 				})
 			}
+		case "input_transcription":
+			cmds = append(cmds, m.appendTranscription(senderNameUserSpoken, msg.Content))
+		case "output_transcription":
+			cmds = append(cmds, m.appendTranscription(senderNameModelSpoken, msg.Content))
+		case "usage":
+			m.sessionTokenCount = msg.TotalTokens
 		}
 		return m, tea.Batch(cmds...)
 
@@ -533,6 +602,9 @@ func (m *Model) Init() tea.Cmd {
 			m.multimodalConfig.EnableAudio, m.multimodalConfig.EnableImages)
 		
 		// Initialize multimodal manager
+		m.multimodalConfig.ContextWindowCompression = m.contextCompression
+		m.multimodalConfig.CompressionTriggerTokens = m.compressionTriggerTokens
+		m.multimodalConfig.CompressionTargetTokens = m.compressionTargetTokens
 		m.multimodalManager = NewMultimodalStreamingManager(m.multimodalConfig, m.client, m.uiUpdateChan)
 		
 		if m.multimodalConfig.EnableAudio {
@@ -554,6 +626,10 @@ func (m *Model) Init() tea.Cmd {
 	// Start with ready state
 	//m.currentState = AppStateReady
 
+	if tickCmd := m.toolReloadTickCmd(); tickCmd != nil {
+		cmds = append(cmds, tickCmd)
+	}
+
 	if uiCmd := m.setupInitialUICmd(); uiCmd != nil {
 		cmds = append(cmds, uiCmd)
 	} else {
@@ -610,10 +686,80 @@ func (m *Model) ToolManager() *ToolManager {
 // ProcessStdinMode processes messages from stdin without running the TUI
 // This is useful for scripting or non-interactive usage
 func (m *Model) ProcessStdinMode(ctx context.Context) error {
+	if err := m.startHeadlessStream(ctx); err != nil {
+		return err
+	}
+	defer m.chat.Close()
+
+	// Create scanner to read from stdin
+	scanner := bufio.NewScanner(os.Stdin)
+
+	fmt.Fprintln(os.Stderr, "aistudio stdin mode - Type messages and press Enter. Use Ctrl+D to exit.")
+
+	// Process messages from stdin
+	for scanner.Scan() {
+		message := scanner.Text()
+		if message == "" {
+			continue
+		}
+
+		response, err := m.sendStructuredMessage(message) // structured_output.go
+		if err != nil {
+			return err
+		}
+
+		// Validated JSON is written bare so pipelines can consume it
+		if m.responseSchema != nil {
+			fmt.Println(response)
+			continue
+		}
+
+		// Output response
+		fmt.Println("rt:", response)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stdin read error: %w", err)
+	}
+
+	return nil
+}
+
+// RunPrompt sends a single prompt without running the TUI and writes the
+// response to w. It is used for scripted runs such as prompt templates.
+func (m *Model) RunPrompt(ctx context.Context, prompt string, w io.Writer) error {
+	if err := m.startHeadlessStream(ctx); err != nil {
+		return err
+	}
+	defer m.chat.Close()
+
+	response, err := m.sendStructuredMessage(prompt) // structured_output.go
+	if err != nil {
+		return err
+	}
+	if m.responseSchema != nil {
+		_, err = fmt.Fprintln(w, response)
+		return err
+	}
+
+	// Grounded answers are printed with their footnotes and sources
+	if last := m.messages[len(m.messages)-1]; last.HasGroundingMetadata {
+		response = annotateCitations(response, last.GroundingMetadata) // citations.go
+		if sources := citationSources(last.GroundingMetadata); len(sources) > 0 {
+			response += "\n\nSources:\n" + strings.Join(sources, "\n")
+		}
+	}
+	_, err = fmt.Fprintln(w, response)
+	return err
+}
+
+// startHeadlessStream initializes the client and chat used outside the TUI.
+func (m *Model) startHeadlessStream(ctx context.Context) error {
 	if m.client == nil {
 		m.client = &api.Client{}
 	}
 	m.client.APIKey = m.apiKey
+	m.client.KeySource = m.apiKeySources[m.backend]
 
 	// Create root context with timeout - use shorter timeout for stdin mode
 	if ctx == nil {
@@ -652,119 +798,120 @@ func (m *Model) ProcessStdinMode(ctx context.Context) error {
 		TopK:            m.topK,
 		MaxOutputTokens: m.maxOutputTokens,
 		// Feature flags
-		EnableWebSocket: m.enableWebSocket,
+		EnableWebSocket:    m.enableWebSocket,
+		ResponseMimeType:   m.responseMimeType,
+		ResponseSchemaFile: m.responseSchemaFile,
+		SafetySettings:     m.safetySettings,
+		ThinkingBudget:     m.thinkingBudget,
+		IncludeThoughts:    m.includeThoughts,
 	}
 
-	// Add tool definitions if enabled
-	if m.enableTools && m.toolManager != nil {
-		config.ToolDefinitions = m.toolManager.GetAvailableTools()
+	// Tool calls run through the chat's tool manager
+	var tools *ToolManager
+	if m.enableTools {
+		tools = m.toolManager
 	}
+	m.chat = NewChat(m.client, config, tools) // chat.go
+	m.chat.ResponseSchema = m.responseSchema
+	m.chat.ApproveToolCall = m.approveHeadlessToolCall
+	return nil
+}
 
-	// Initialize bidirectional stream
-	var err error
-	m.bidiStream, err = m.client.InitBidiStream(m.rootCtx, &config)
-	if err != nil {
-		return fmt.Errorf("failed to initialize bidirectional stream: %w", err)
+// approveHeadlessToolCall approves tool calls when no one can be asked: calls
+// run unless approval is required and the tool type was not pre-approved.
+func (m *Model) approveHeadlessToolCall(ctx context.Context, call ToolCall) (ToolCall, bool) {
+	if !m.requireApproval || m.approvedToolTypes[call.Name] {
+		return call, true
 	}
-	defer m.bidiStream.CloseSend()
-
-	// Create scanner to read from stdin
-	scanner := bufio.NewScanner(os.Stdin)
-
-	fmt.Fprintln(os.Stderr, "aistudio stdin mode - Type messages and press Enter. Use Ctrl+D to exit.")
-
-	// Process messages from stdin
-	for scanner.Scan() {
-		message := scanner.Text()
-		if message == "" {
-			continue
-		}
-
-		// Create a user message
-		userMsg := formatMessage("You", message)
-		m.messages = append(m.messages, userMsg)
-
-		// Add to history if enabled
-		if m.historyEnabled && m.historyManager != nil {
-			m.historyManager.AddMessage(userMsg)
-		}
-
-		// Send message to stream
-		if err := m.client.SendMessageToBidiStream(m.bidiStream, message); err != nil {
-			return fmt.Errorf("failed to send message: %w", err)
-		}
+	fmt.Fprintf(os.Stderr, "Tool call to '%s' denied: approval required\n", call.Name)
+	return call, false
+}
 
-		// Receive response
-		var responseText strings.Builder
+// sendHeadlessMessage sends one user message on the headless chat, records
+// the exchange, including any tool calls, and returns the response text.
+func (m *Model) sendHeadlessMessage(message string) (string, error) {
+	// Create a user message
+	userMsg := formatMessage("You", message)
+	m.messages = append(m.messages, userMsg)
 
-		for {
-			resp, err := m.bidiStream.Recv()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return fmt.Errorf("stream error: %w", err)
-			}
-
-			// Extract text from response
-			output := api.ExtractBidiOutput(resp)
-			responseText.WriteString(output.Text)
+	// Add to history if enabled
+	if m.historyEnabled && m.historyManager != nil {
+		m.historyManager.AddMessage(userMsg)
+	}
 
-			// Process tool calls if enabled
-			if m.enableTools && output.FunctionCall != nil {
-				// Extract and process tool calls
-				toolCalls := ExtractToolCalls(&output)
-				if len(toolCalls) > 0 {
-					// Use processToolCalls which handles approval logic
-					results, err := m.processToolCalls(toolCalls)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error processing tool calls: %v\n", err)
-					} else if len(results) > 0 {
-						// Convert ToolResult slice to []*generativelanguagepb.FunctionResponse
-						var fnResults []*generativelanguagepb.FunctionResponse
-						for _, result := range results {
-							fnResults = append(fnResults, (*generativelanguagepb.FunctionResponse)(result))
-						}
+	stream, err := m.chat.Send(m.rootCtx, TextPart(message))
+	if err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
 
-						// Send function responses back to model
-						if err := m.client.SendToolResultsToBidiStream(m.bidiStream, fnResults...); err != nil {
-							fmt.Fprintf(os.Stderr, "Error sending tool results: %v\n", err)
-						}
-					}
-				}
-			}
-			// Process grounding, safety, tokens
-			m.ProcessGenerativeLanguageResponse(output)
+	// Receive response
+	var responseText strings.Builder
+	var thoughts strings.Builder
+	var done TurnCompleteEvent
+	var usage *TokenCounts
 
-			if output.TurnComplete {
-				log.Println("Turn complete, exiting loop.")
-				break
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("stream error: %w", err)
+		}
+
+		switch ev := ev.(type) {
+		case TextDeltaEvent:
+			responseText.WriteString(ev.Text)
+		case ThoughtEvent:
+			thoughts.WriteString(ev.Text)
+		case ToolCallEvent:
+			m.messages = append(m.messages, formatToolCallMessage(ev.Call, "Executing..."))
+		case ToolResultEvent:
+			if ev.Err != nil {
+				fmt.Fprintf(os.Stderr, "Tool call '%s' failed: %v\n", ev.Call.Name, ev.Err)
 			}
+			m.messages = append(m.messages, formatToolResultMessage(ev.Call.ID, ev.Call.Name, ev.Result.Response, ToolCallStatusCompleted))
+			// Answers before and after tool calls are separate responses
+			responseText.Reset()
+		case UsageEvent:
+			usage = &ev.TokenCounts
+		case TurnCompleteEvent:
+			done = ev
 		}
+	}
 
-		// Create a model response message
-		modelMsg := formatMessage("Gemini", responseText.String())
-		m.messages = append(m.messages, modelMsg)
-
-		// Add to history if enabled
-		if m.historyEnabled && m.historyManager != nil {
-			m.historyManager.AddMessage(modelMsg)
-
-			// Save the history
-			if err := m.historyManager.SaveSession(m.historyManager.CurrentSession); err != nil {
-				log.Printf("Error saving history: %v", err)
-			}
+	// Create a model response message
+	modelMsg := formatMessage("Gemini", responseText.String())
+	if done.GroundingMetadata != nil {
+		modelMsg.HasGroundingMetadata = true
+		modelMsg.GroundingMetadata = m.convertGroundingMetadata(done.GroundingMetadata)
+	}
+	modelMsg.Thoughts = thoughts.String()
+	modelMsg.SafetyRatings = NewAPIFormatter().ConvertSafetyRatings(done.SafetyRatings)
+	if usage != nil {
+		modelMsg.TokenCounts = usage
+		modelMsg.HasTokenInfo = true
+	}
+	// A blocked turn reports why instead of an empty response
+	if reason := done.BlockedReason; reason != "" {
+		modelMsg.BlockReason = reason
+		if responseText.Len() == 0 {
+			responseText.WriteString(describeBlock(reason, modelMsg.SafetyRatings)) // safety.go
 		}
-
-		// Output response
-		fmt.Println("rt:", responseText.String())
 	}
+	m.messages = append(m.messages, modelMsg)
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("stdin read error: %w", err)
+	// Add to history if enabled
+	if m.historyEnabled && m.historyManager != nil {
+		m.historyManager.AddMessage(modelMsg)
+
+		// Save the history
+		if err := m.historyManager.SaveSession(m.historyManager.CurrentSession); err != nil {
+			log.Printf("Error saving history: %v", err)
+		}
 	}
 
-	return nil
+	return responseText.String(), nil
 }
 
 // playbackTickCmd creates a command for the playback UI ticker.
@@ -775,6 +922,12 @@ func playbackTickCmd() tea.Cmd {
 	})
 }
 
+// uiUpdateMsg wraps a message received on uiUpdateChan so that Update can
+// resume listening once it has been handled.
+type uiUpdateMsg struct {
+	msg tea.Msg
+}
+
 // listenForUIUpdatesCmd returns a command that listens on the uiUpdateChan
 // and forwards messages to the main Bubble Tea update loop.
 func (m *Model) listenForUIUpdatesCmd() tea.Cmd {
@@ -782,7 +935,7 @@ func (m *Model) listenForUIUpdatesCmd() tea.Cmd {
 		// Blocks until a message is available on the channel
 		// This is fine because it runs in its own goroutine
 		msg := <-m.uiUpdateChan
-		return msg
+		return uiUpdateMsg{msg: msg}
 	}
 }
 
@@ -814,6 +967,11 @@ func (m *Model) setupInitialUICmd() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// sharedTextareaKeys are keys with their own handling that the textarea
+// also uses: up and down recall input history at the edges of the draft,
+// ctrl+k cancels running tool calls and ctrl+f opens the pager.
+var sharedTextareaKeys = map[string]bool{"up": true, "down": true, "ctrl+k": true, "ctrl+f": true}
+
 // handleKeyMsg handles keyboard input messages.
 func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var (
@@ -822,6 +980,11 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// startPlaybackTicker bool // Flag is handled in the main Update loop
 	)
 
+	// The template picker captures all keys while open
+	if m.templatePicker != nil {
+		return m.handleTemplatePickerKey(msg) // template_picker.go
+	}
+
 	// Check if settings panel is focused first
 	if m.showSettingsPanel && m.settingsPanel != nil && m.focusedComponent == "settings" {
 		// Update the settings panel
@@ -829,6 +992,8 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		*m.settingsPanel, settingsCmd = m.settingsPanel.Update(msg)
 		cmds = append(cmds, settingsCmd)
 
+		m.applyCompressionSettings()
+
 		// If settings panel no longer focused, return to input
 		if !m.settingsPanel.Focused {
 			m.focusedComponent = "input"
@@ -837,16 +1002,18 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Return early as settings panel handled the key
 		return m, tea.Batch(cmds...)
 	}
-	// Update textarea if input is focused (for typing to work)
-	if m.focusedComponent == "input" {
+	// Update textarea if input is focused (for typing to work); the tool
+	// approval modal takes the keys while it is open. Keys handled below
+	// that the textarea also uses are passed on there when not handled.
+	msgStr := msg.String()
+	if m.focusedComponent == "input" && !m.showToolApproval && !sharedTextareaKeys[msgStr] {
 		var taCmd tea.Cmd
 		m.textarea, taCmd = m.textarea.Update(msg)
 		cmds = append(cmds, taCmd)
 	}
 
 	// Check if this is a regular printable character that should bypass special handling
-	msgStr := msg.String()
-	if len(msgStr) == 1 && msgStr[0] >= 32 && msgStr[0] <= 126 {
+	if len(msgStr) == 1 && msgStr[0] >= 32 && msgStr[0] <= 126 && !m.showToolApproval {
 		// This is a regular printable character - just return with textarea update
 		return m, tea.Batch(cmds...)
 	}
@@ -857,95 +1024,52 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.showToolApproval && len(m.pendingToolCalls) > 0 && m.approvalIndex < len(m.pendingToolCalls) {
 			// Get the current tool call
 			approvedCall := m.pendingToolCalls[m.approvalIndex]
-			approvedCalls := []ToolCall{approvedCall} // Keep for executeToolCalls
 
-			// Add formatted message for executing the tool call
+			// Chat turns run the call themselves once approved
+			if m.answerChatApproval(approvedCall, true) { // chat_tui.go
+				m.advanceToolApproval()
+				return m, nil
+			}
+
+			// Add formatted message for the tool call; it runs with the other
+			// approved calls once the queue is done
 			m.messages = append(m.messages, formatToolCallMessage(approvedCall, "Executing...")) // Use helper
 			log.Printf("Tool call approved and executing: %s", approvedCall.Name)
-
-			// Process the tool call
-			results, err := m.executeToolCalls(approvedCalls)
-			if err != nil {
-				log.Printf("Error executing tool call: %v", err)
-				// Add formatted message for the error result (optional, could use formatError)
-				// m.messages = append(m.messages, helpers.formatToolResultMessage(approvedCall.ID, approvedCall.Name, json.RawMessage(fmt.Sprintf(`{"error": "%v"}`, err))))
-				m.messages = append(m.messages, formatError(fmt.Errorf("error executing tool call '%s': %w", approvedCall.Name, err)))
-			} else if len(results) > 0 {
-				// Add formatted message for the successful result
-				// Assuming one result for the one approved call
-				if len(results) == 1 {
-					m.messages = append(m.messages, formatToolResultMessage(results[0].Id, results[0].Name, results[0].Response, ToolCallStatusCompleted))
-				} else {
-					// Handle unexpected multiple results if necessary
-					log.Printf("Warning: Expected 1 result for tool call %s, got %d", approvedCall.Name, len(results))
-					for _, res := range results {
-						m.messages = append(m.messages, formatToolResultMessage(res.Id, res.Name, res.Response, ToolCallStatusUnknown))
-					}
-				}
-				// Send results back to model
-				cmds = append(cmds, m.sendToolResultsCmd(results))
-			} else {
-				// Handle case where execution succeeded but returned no results (if applicable)
-				log.Printf("Tool call %s executed successfully but returned no results.", approvedCall.Name)
-				// Optionally add a message indicating no results were returned
-				// m.messages = append(m.messages, helpers.formatToolResultMessage(approvedCall.ID, approvedCall.Name, json.RawMessage(`{"info": "No results returned"}`)))
-			}
+			m.approvedToolCalls = append(m.approvedToolCalls, approvedCall)
 
 			// Move to next tool call or close modal
-			m.approvalIndex++
-			if m.approvalIndex >= len(m.pendingToolCalls) {
-				// All tool calls processed, close the modal
-				m.showToolApproval = false
-				m.pendingToolCalls = nil
-				m.approvalIndex = 0
-			}
+			m.advanceToolApproval() // chat_tui.go
 
 			// UI will update automatically
 			return m, tea.Batch(cmds...)
 		}
+	case "e", "E": // Edit the proposed content of a file-editing tool call
+		if cmd := m.editToolCallCmd(); cmd != nil { // tool_diff.go
+			return m, cmd
+		}
 	case "2": // Approve tool call and don't ask again for this tool type
 		if m.showToolApproval && len(m.pendingToolCalls) > 0 && m.approvalIndex < len(m.pendingToolCalls) {
 			// Get the current tool call
 			approvedCall := m.pendingToolCalls[m.approvalIndex]
-			approvedCalls := []ToolCall{approvedCall} // Keep for executeToolCalls
 
 			// Mark this tool type as pre-approved for future calls
 			m.approvedToolTypes[approvedCall.Name] = true
 			log.Printf("Tool type '%s' marked as pre-approved for future calls", approvedCall.Name)
 
-			// Add formatted message for executing the tool call
+			if m.answerChatApproval(approvedCall, true) { // chat_tui.go
+				m.messages = append(m.messages, formatMessage("System", fmt.Sprintf("Tool type '%s' will be auto-approved in the future", approvedCall.Name)))
+				m.advanceToolApproval()
+				return m, nil
+			}
+
+			// Add formatted message for the tool call
 			m.messages = append(m.messages, formatToolCallMessage(approvedCall, "Executing..."))
 			m.messages = append(m.messages, formatMessage("System", fmt.Sprintf("Tool type '%s' will be auto-approved in the future", approvedCall.Name)))
 			log.Printf("Tool call approved and executing: %s", approvedCall.Name)
-
-			// Process the tool call
-			results, err := m.executeToolCalls(approvedCalls)
-			if err != nil {
-				log.Printf("Error executing tool call: %v", err)
-				m.messages = append(m.messages, formatError(fmt.Errorf("error executing tool call '%s': %w", approvedCall.Name, err)))
-			} else if len(results) > 0 {
-				// Add formatted message for the successful result
-				if len(results) == 1 {
-					m.messages = append(m.messages, formatToolResultMessage(results[0].Id, results[0].Name, results[0].Response, ToolCallStatusCompleted))
-				} else {
-					// Handle unexpected multiple results if necessary
-					log.Printf("Warning: Expected 1 result for tool call %s, got %d", approvedCall.Name, len(results))
-					for _, res := range results {
-						m.messages = append(m.messages, formatToolResultMessage(res.Id, res.Name, res.Response, ToolCallStatusUnknown))
-					}
-				}
-				// Send results back to model
-				cmds = append(cmds, m.sendToolResultsCmd(results))
-			}
+			m.approvedToolCalls = append(m.approvedToolCalls, approvedCall)
 
 			// Move to next tool call or close modal
-			m.approvalIndex++
-			if m.approvalIndex >= len(m.pendingToolCalls) {
-				// All tool calls processed, close the modal
-				m.showToolApproval = false
-				m.pendingToolCalls = nil
-				m.approvalIndex = 0
-			}
+			m.advanceToolApproval() // chat_tui.go
 
 			// UI will update automatically
 			return m, tea.Batch(cmds...)
@@ -961,9 +1085,17 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Create a message about the denial
 			m.messages = append(m.messages, formatMessage("System", fmt.Sprintf("Tool call to '%s' was denied by the user.", deniedCall.Name)))
 
+			// Chat turns send the denial themselves
+			if m.answerChatApproval(deniedCall, false) { // chat_tui.go
+				m.advanceToolApproval()
+				m.viewport.GotoBottom()
+				return m, nil
+			}
+
 			// Create an error function response
 			var fnResponse generativelanguagepb.FunctionResponse
 			fnResponse.Id = deniedCall.ID
+			fnResponse.Name = deniedCall.Name
 			fnResponse.Response, _ = structpb.NewStruct(map[string]any{
 				"error": "Tool call denied by user",
 			})
@@ -983,13 +1115,7 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			})
 
 			// Move to next tool call or close modal
-			m.approvalIndex++
-			if m.approvalIndex >= len(m.pendingToolCalls) {
-				// All tool calls processed, close the modal
-				m.showToolApproval = false
-				m.pendingToolCalls = nil
-				m.approvalIndex = 0
-			}
+			m.advanceToolApproval() // chat_tui.go
 
 			// Update UI
 			// UI will update automatically
@@ -1006,6 +1132,57 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// }
 			return m, tea.Batch(cmds...)
 		}
+		// Outside the approval modal, esc stops the response in flight
+		if msgStr == "esc" && m.StopGeneration() {
+			m.viewport.GotoBottom()
+			return m, tea.Batch(cmds...)
+		}
+	case "ctrl+l": // Choose a prompt template
+		m.openTemplatePicker() // template_picker.go
+		return m, nil
+
+	case "ctrl+x": // Compose the draft in $EDITOR
+		return m, m.openEditorCmd() // editor.go
+
+	case "ctrl+k": // Cancel running tool calls
+		if m.processingTool && m.cancelRunningToolCalls() { // tools.go
+			m.viewport.GotoBottom()
+			return m, tea.Batch(cmds...)
+		}
+		// Otherwise the textarea deletes to the end of the line
+		var textareaCmd tea.Cmd
+		m.textarea, textareaCmd = m.textarea.Update(msg)
+		cmds = append(cmds, textareaCmd)
+		return m, tea.Batch(cmds...)
+
+	case "ctrl+g": // Regenerate the last response
+		if cmd := m.RegenerateLastResponse(); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		m.viewport.GotoBottom()
+		return m, tea.Batch(cmds...)
+
+	case "ctrl+f": // View the latest large tool result in $PAGER
+		if cmd := m.openPagerCmd(); cmd != nil { // artifacts.go
+			return m, cmd
+		}
+		// Otherwise the textarea moves the cursor forward
+		var textareaCmd tea.Cmd
+		m.textarea, textareaCmd = m.textarea.Update(msg)
+		cmds = append(cmds, textareaCmd)
+		return m, tea.Batch(cmds...)
+
+	case "ctrl+y": // Expand or collapse thought summaries and tool output
+		m.toggleDetails() // thoughts.go
+		return m, tea.Batch(cmds...)
+
+	case "ctrl+left", "ctrl+right": // Flip between alternate answers
+		delta := 1
+		if msgStr == "ctrl+left" {
+			delta = -1
+		}
+		m.CycleAlternate(delta)
+		return m, tea.Batch(cmds...)
 	case "ctrl+c":
 		m.currentState = AppStateQuitting
 		log.Println("Ctrl+C pressed, entering Quitting state.")
@@ -1062,6 +1239,8 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.showSettingsPanel = !m.showSettingsPanel
 		if m.showSettingsPanel {
 			m.focusedComponent = "settings"
+			m.settingsPanel.ContextCompression = m.contextCompression
+			m.settingsPanel.CompressionTriggerTokens = m.compressionTriggerTokens
 			m.settingsPanel.Focus()
 			m.textarea.Blur()
 		} else {
@@ -1157,6 +1336,20 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Batch(cmds...) // Return early
 
+	case "ctrl+@": // Ctrl+Space: start or finish a push-to-talk utterance
+		if m.enableMultimodal && m.multimodalManager != nil && m.multimodalManager.IsPushToTalk() {
+			var err error
+			if m.multimodalManager.IsTalking() {
+				err = m.multimodalManager.StopTalking()
+			} else {
+				err = m.multimodalManager.StartTalking()
+			}
+			if err != nil {
+				m.messages = append(m.messages, formatError(err))
+			}
+		}
+		return m, tea.Batch(cmds...) // Return early
+
 	case "ctrl+shift+a": // Toggle audio input only
 		if m.enableMultimodal && m.multimodalManager != nil {
 			audioManager := m.multimodalManager.GetAudioInputManager()
@@ -1390,6 +1583,8 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "up": // Scroll up in viewport or navigate history
 		if m.focusedComponent == "viewport" {
 			m.viewport.LineUp(1)
+		} else if m.recallInput(-1) { // editor.go
+			return m, nil
 		} else {
 			// Let textarea handle cursor movement
 			var textareaCmd tea.Cmd
@@ -1398,9 +1593,11 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Batch(cmds...)
 
-	case "down": // Scroll down in viewport
+	case "down": // Scroll down in viewport or navigate history
 		if m.focusedComponent == "viewport" {
 			m.viewport.LineDown(1)
+		} else if m.recallInput(1) { // editor.go
+			return m, nil
 		} else {
 			// Let textarea handle cursor movement
 			var textareaCmd tea.Cmd
@@ -1429,6 +1626,7 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if txt := strings.TrimSpace(m.textarea.Value()); txt != "" {
 			log.Printf("Sending message: %s", txt)
 			m.messages = append(m.messages, formatMessage("You", txt)) // helpers.go
+			m.recordInput(txt) // editor.go
 
 			m.textarea.Reset()
 			m.textarea.Focus()
@@ -1441,11 +1639,8 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				cmds = append(cmds, m.saveSessionCmd())
 			}
 
-			if m.useBidi && m.bidiStream != nil {
-				sendCmd = m.sendToBidiStreamCmd(txt) // stream.go
-			} else {
-				sendCmd = m.sendToStreamCmd(txt) // stream.go
-			}
+			sendCmd = m.sendTextCmd(txt) // generation_controls.go
+			m.currentState = AppStateWaiting
 			cmds = append(cmds, sendCmd)
 		}
 
@@ -1594,6 +1789,8 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, m.receiveBidiStreamCmd())
 
 	case streamResponseMsg: // stream.go (One-way stream response)
+		m.appendThought(msg.output.Thought) // thoughts.go
+		cmds = append(cmds, m.showBlockedResponse(msg.output)) // safety.go
 		//m.currentState = AppStateWaiting // Ensure state reflects waiting
 		if msg.output.Text != "" || len(msg.output.Audio) > 0 || msg.output.FunctionCall != nil || msg.output.GroundingMetadata != nil || len(msg.output.SafetyRatings) > 0 {
 			// Process grounding, safety, tokens first
@@ -1682,6 +1879,9 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Handle setup completion if needed
 			m.messages = append(m.messages, formatMessage("System", "Setup complete."))
 		}
+		if msg.output.TurnComplete {
+			cmds = append(cmds, m.checkStructuredOutput()) // structured_output.go
+		}
 		// Decide next state based on whether the stream is still active
 		if m.stream != nil && m.currentState != AppStateQuitting {
 			// If we received the final chunk, transition back to Chatting
@@ -1701,6 +1901,20 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case bidiStreamResponseMsg: // stream.go (Bidirectional stream response)
+		if msg.output.InputTranscription != "" {
+			cmds = append(cmds, m.appendTranscription(senderNameUserSpoken, msg.output.InputTranscription))
+		}
+		if msg.output.OutputTranscription != "" {
+			cmds = append(cmds, m.appendTranscription(senderNameModelSpoken, msg.output.OutputTranscription))
+		}
+		if len(msg.output.CancelledToolCallIDs) > 0 {
+			m.cancelToolCalls(msg.output.CancelledToolCallIDs)
+		}
+		if msg.output.TotalTokenCount > 0 {
+			m.sessionTokenCount = msg.output.TotalTokenCount
+		}
+		m.appendThought(msg.output.Thought) // thoughts.go
+		cmds = append(cmds, m.showBlockedResponse(msg.output)) // safety.go
 		m.ProcessGenerativeLanguageResponse(msg.output)
 		if !msg.output.TurnComplete {
 			m.currentState = AppStateResponding
@@ -1968,6 +2182,11 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 			log.Println("Stream handling skipped (application is quitting)")
 		}
 
+		// Validate structured responses once the turn is complete
+		if msg.output.TurnComplete {
+			cmds = append(cmds, m.checkStructuredOutput()) // structured_output.go
+		}
+
 	case sentMsg: // stream.go
 		// Message sent, transition back to chatting/receiving if we were sending
 		// if m.currentState == AppStateSending {
@@ -1990,6 +2209,15 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// UI will update automatically
 
 	case streamErrorMsg: // stream.go
+		// A cancellation caused by StopGeneration is expected, not a failure
+		if m.stopRequested && strings.Contains(msg.err.Error(), "context canceled") {
+			log.Println("Stream cancelled after user stopped generation")
+			m.stopRequested = false
+			m.stream = nil
+			m.currentState = AppStateReady
+			break
+		}
+
 		// Error receiving or connecting
 		// Categorize the error for better handling
 		var errorCategory string
@@ -2166,6 +2394,7 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case streamClosedMsg: // stream.go
 		log.Println("Stream closed cleanly or unexpectedly.")
+		m.stopRequested = false
 		// Stream closed cleanly or unexpectedly (but not an error handled by streamErrorMsg)
 		if m.currentState != AppStateQuitting && m.currentState != AppStateError {
 			// Automatically reconnect instead of showing user message
@@ -2196,6 +2425,9 @@ func (m *Model) handleStreamMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.streamCtxCancel = nil
 		}
 	}
+
+	// Link any regenerated answer to the ones it replaced
+	m.attachRegeneratedAnswer()
 	return m, tea.Batch(cmds...)
 }
 
@@ -38,8 +38,15 @@ func (m *Model) renderToolApprovalModalContent() string {
 	// Tool name in the dialog style
 	builder.WriteString(fmt.Sprintf("%s\n\n", viewToolNameStyle.Render(toolCall.Name)))
 
-	// Arguments formatted in code block style
-	builder.WriteString(formattedArgs)
+	// File edits show the change they propose instead of their arguments
+	edit := m.toolFileEdit(toolCall) // tool_diff.go
+	if edit != nil {
+		// The modal is m.width-10 wide, less its border and padding
+		builder.WriteString(renderFileEditPreview(edit, m.width-18))
+	} else {
+		// Arguments formatted in code block style
+		builder.WriteString(formattedArgs)
+	}
 	builder.WriteString("\n")
 	builder.WriteString(dialogOptionUnselected.Render(fmt.Sprintf("Execute %s tool", toolCall.Name)))
 	builder.WriteString("\n\n")
@@ -66,6 +73,11 @@ func (m *Model) renderToolApprovalModalContent() string {
 	builder.WriteString(fmt.Sprintf(" (%s)", dialogHintStyle.Render("esc")))
 	builder.WriteString("\n")
 
+	if edit != nil && edit.Err == nil {
+		builder.WriteString(fmt.Sprintf("\nPress %s to edit the proposed %s", dialogHintStyle.Render("e"), edit.Field))
+		builder.WriteString("\n")
+	}
+
 	// Add progress indicator if there are multiple pending tool calls
 	if len(m.pendingToolCalls) > 1 {
 		builder.WriteString(fmt.Sprintf("\nTool call %d of %d", m.approvalIndex+1, len(m.pendingToolCalls)))
@@ -108,7 +120,9 @@ func (m *Model) renderStatusLine() string {
 	var statusLine strings.Builder
 
 	// Add input mode indicator if active
-	if m.micActive {
+	if m.multimodalManager != nil && m.multimodalManager.IsTalking() {
+		statusLine.WriteString(inputModeStyle.Render("[Talking] "))
+	} else if m.micActive {
 		statusLine.WriteString(inputModeStyle.Render("[Mic ON] "))
 	} else if m.videoInputMode != VideoInputNone {
 		statusLine.WriteString(inputModeStyle.Render(fmt.Sprintf("[%s ON] ", m.videoInputMode)))
@@ -139,6 +153,10 @@ func (m *Model) renderStatusLine() string {
 		statusLine.WriteString(statusStyle.Render(string(m.currentState)))
 	}
 
+	if usage := m.renderContextUsage(); usage != "" {
+		statusLine.WriteString(statusStyle.Render(" | " + usage))
+	}
+
 	return statusLine.String()
 }
 
@@ -147,6 +165,23 @@ func (m *Model) renderHelpText() string {
 	// Build help text for available keyboard shortcuts
 	helpParts := []string{"Enter: Send", "Alt+Enter: New Line", "Tab: Switch Focus", "Shift+Tab: Reverse Focus", "↑/↓: Scroll", "PgUp/PgDn: Page", "Ctrl+C: Quit"}
 
+	helpParts = append(helpParts, "Ctrl+X: Editor", "Ctrl+L: Templates")
+	if m.isGenerating() {
+		helpParts = append(helpParts, "Esc: Stop")
+	}
+	if m.processingTool {
+		helpParts = append(helpParts, "Ctrl+K: Cancel Tool")
+	}
+	if m.lastUserMessageIndex() >= 0 {
+		helpParts = append(helpParts, "Ctrl+G: Regenerate")
+	}
+	if m.includeThoughts || m.enableTools {
+		helpParts = append(helpParts, "Ctrl+Y: Details")
+	}
+	if m.latestArtifact() != "" {
+		helpParts = append(helpParts, "Ctrl+F: Full Output")
+	}
+
 	if m.historyEnabled {
 		helpParts = append(helpParts, "Ctrl+H: Save History")
 	}
@@ -160,6 +195,9 @@ func (m *Model) renderHelpText() string {
 			helpParts = append(helpParts, "Ctrl+A: Enable Approval")
 		}
 	}
+	if m.multimodalManager != nil && m.multimodalManager.IsStreaming() && m.multimodalManager.IsPushToTalk() {
+		helpParts = append(helpParts, "Ctrl+Space: Talk")
+	}
 	if m.enableAudio {
 		helpParts = append(helpParts, "Ctrl+P: Play/Pause")
 		helpParts = append(helpParts, "Ctrl+R: Replay")
@@ -202,6 +240,10 @@ func (m *Model) View() string {
 	if m.showToolApproval && len(m.pendingToolCalls) > 0 {
 		parts = append(parts, m.renderViewToolApprovalModal())
 	}
+	// Add the template picker if open
+	if m.templatePicker != nil {
+		parts = append(parts, m.renderTemplatePicker())
+	}
 
 	return strings.Join(parts, "\n")
 }
@@ -25,7 +25,6 @@ import (
 	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -42,8 +41,9 @@ const (
 // APIClientConfig holds configuration for the Client.
 type APIClientConfig struct {
 	// Authentication and service selection
-	APIKey  string
-	Backend Backend
+	APIKey    string
+	KeySource KeySource
+	Backend   Backend
 
 	// Vertex AI settings
 	ProjectID string
@@ -60,7 +60,8 @@ type APIClientConfig struct {
 // Client wraps the Google Generative Language client and Vertex AI client.
 type Client struct {
 	// Configuration
-	APIKey        string // Optional API Key
+	APIKey        string    // Optional API Key
+	KeySource     KeySource // Where to obtain the API key when APIKey is empty
 	Backend       Backend
 	ProjectID     string
 	Location      string
@@ -87,10 +88,17 @@ type ToolDefinition = generativelanguagepb.FunctionDeclaration
 // ToolResponse
 type ToolResponse = generativelanguagepb.FunctionResponse
 
+// ToolStreamHandler handles a tool call that can be cancelled through ctx and
+// reports progress, one line at a time, while it runs.
+type ToolStreamHandler func(ctx context.Context, args json.RawMessage, progress func(line string)) (any, error)
+
 // RegisteredTool represents a tool registered with the application that can be called
 type RegisteredTool struct {
 	ToolDefinition ToolDefinition                          // The tool definition
 	Handler        func(args json.RawMessage) (any, error) // Function that handles the tool call
+	StreamHandler  ToolStreamHandler                       // Optional; used instead of Handler when set
+	Timeout        time.Duration                           // How long a call may run; zero uses the default
+	Exclusive      bool                                    // Not parallel-safe: calls run alone
 	IsAvailable    bool                                    // Whether the tool is currently available
 }
 
@@ -114,6 +122,23 @@ type StreamClientConfig struct {
 	ResponseMimeType    string // MIME type of the expected response (e.g., "application/json")
 	ResponseSchemaFile  string // Path to JSON schema file defining response structure
 
+	// Live API realtime input
+	ManualActivityDetection bool // Push-to-talk: disable server VAD and send activityStart/activityEnd
+	AudioTranscription      bool // Transcribe spoken input and output into StreamOutput
+
+	// Live API context window compression (sliding window)
+	ContextWindowCompression bool  // Drop the oldest turns instead of ending the session at the context limit
+	CompressionTriggerTokens int64 // Context size that triggers compression (0 = server default)
+	CompressionTargetTokens  int64 // Context size to compress down to (0 = server default)
+
+	// Thinking (Gemini 2.5 models)
+	ThinkingBudget  *int32 // Thinking token budget: nil = model default, 0 = off, -1 = dynamic
+	IncludeThoughts bool   // Return thought summaries alongside the answer
+
+	// Per-category block thresholds; categories left out use the server
+	// default. Not supported by the Live API.
+	SafetySettings []*generativelanguagepb.SafetySetting
+
 	// Display options
 	DisplayTokenCounts bool // Whether to display token counts in the UI
 
@@ -122,8 +147,9 @@ type StreamClientConfig struct {
 
 // StreamOutput holds the processed output from a stream response chunk.
 type StreamOutput struct {
-	Text  string
-	Audio []byte // Raw audio data (PCM S16LE, 24kHz expected if audio is generated)
+	Text    string
+	Thought string // Thought summary text, kept apart from the answer in Text
+	Audio   []byte // Raw audio data (PCM S16LE, 24kHz expected if audio is generated)
 
 	FunctionCall        *generativelanguagepb.FunctionCall        // Function call data
 	ExecutableCode      *generativelanguagepb.ExecutableCode      // Executable code data
@@ -132,14 +158,25 @@ type StreamOutput struct {
 	SetupComplete *bool
 	TurnComplete  bool
 
+	// Live API transcriptions of spoken audio, delivered in fragments
+	InputTranscription  string // What the user said
+	OutputTranscription string // What the model said
+
+	CancelledToolCallIDs []string // Live API tool calls the server withdrew
+
 	// Additional feedback and metadata
 	SafetyRatings     []*generativelanguagepb.SafetyRating    // Safety ratings for content
 	GroundingMetadata *generativelanguagepb.GroundingMetadata // Grounding metadata
 
+	// Why generation stopped, see BlockedReason
+	FinishReason generativelanguagepb.Candidate_FinishReason                             // Finish reason of the candidate
+	BlockReason  generativelanguagepb.GenerateContentResponse_PromptFeedback_BlockReason // Set when the prompt itself was blocked
+
 	// Usage information
 	PromptTokenCount    int32 // Number of tokens in the prompt (only available at end of response)
 	CandidateTokenCount int32 // Number of tokens in the response (only available at end of response)
 	TotalTokenCount     int32 // Total tokens used (prompt + response, only available at end of response)
+	ThoughtsTokenCount  int32 // Tokens spent thinking (only available at end of response)
 }
 
 // NewClient creates a new Client with the given config.
@@ -153,6 +190,7 @@ func NewClient(ctx context.Context, config *APIClientConfig) (*Client, error) {
 	// Apply configuration
 	if config != nil {
 		client.APIKey = config.APIKey
+		client.KeySource = config.KeySource
 		client.Backend = config.Backend
 		client.ProjectID = config.ProjectID
 		client.Location = config.Location
@@ -164,6 +202,10 @@ func NewClient(ctx context.Context, config *APIClientConfig) (*Client, error) {
 		client.Location = "us-central1"
 	}
 
+	if err := client.resolveAPIKey(ctx); err != nil {
+		return nil, err
+	}
+
 	// Check environment variables if not set directly
 	if client.APIKey == "" {
 		client.APIKey = os.Getenv("GEMINI_API_KEY")
@@ -210,6 +252,10 @@ func (c *Client) InitVertexAIClient(ctx context.Context) error {
 
 	c.Backend = BackendVertexAI
 
+	if err := c.resolveAPIKey(ctx); err != nil {
+		return err
+	}
+
 	// Close existing clients if they exist
 	if c.VertexAIClient != nil {
 		c.VertexAIClient.Close()
@@ -327,6 +373,20 @@ func (c *Client) InitGeminiClient(ctx context.Context) error {
 	return c.InitClient(ctx)
 }
 
+// resolveAPIKey fills in APIKey from KeySource when no key was given directly
+// and registers the key for redaction from logs and recordings.
+func (c *Client) resolveAPIKey(ctx context.Context) error {
+	if c.APIKey == "" && !c.KeySource.IsZero() {
+		key, err := ResolveAPIKey(ctx, c.Backend, c.KeySource)
+		if err != nil {
+			return err
+		}
+		c.APIKey = key
+	}
+	RegisterSecret(c.APIKey)
+	return nil
+}
+
 // InitClient initializes the Google Cloud Generative Language client with the specified API version.
 func (c *Client) InitClient(ctx context.Context) error {
 	log.Printf("[DEBUG] InitClient called - starting Gemini API client initialization")
@@ -337,6 +397,10 @@ func (c *Client) InitClient(ctx context.Context) error {
 		return fmt.Errorf("context cannot be nil")
 	}
 
+	if err := c.resolveAPIKey(ctx); err != nil {
+		return err
+	}
+
 	// Handle Grok backend initialization
 	if c.Backend == BackendGrok {
 		return c.InitGrokClient(ctx)
@@ -581,6 +645,7 @@ func (c *Client) InitBidiStream(ctx context.Context, config *StreamClientConfig)
 	if config.MaxOutputTokens > 0 {
 		genConfig.MaxOutputTokens = &config.MaxOutputTokens
 	}
+	genConfig.ThinkingConfig = ThinkingConfig(config)
 
 	// Only set voice config if both VoiceName is specified AND EnableAudio is true
 	if config.VoiceName != "" && config.EnableAudio {
@@ -602,14 +667,9 @@ func (c *Client) InitBidiStream(ctx context.Context, config *StreamClientConfig)
 
 	// If output schema is specified, set it in the setup message
 	if config.ResponseSchemaFile != "" {
-		schema, err := os.ReadFile(config.ResponseSchemaFile)
+		schemaObj, err := LoadResponseSchema(config.ResponseSchemaFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response schema file: %w", err)
-		}
-		schemaObj := &generativelanguagepb.Schema{}
-		m := protojson.UnmarshalOptions{}
-		if err := m.Unmarshal(schema, schemaObj); err != nil {
-			return nil, fmt.Errorf("failed to parse response schema: %w", err)
+			return nil, err
 		}
 		log.Printf("Parsed response schema: %s", prototext.Format(schemaObj))
 		genConfig.ResponseSchema = schemaObj
@@ -633,6 +693,7 @@ func (c *Client) InitBidiStream(ctx context.Context, config *StreamClientConfig)
 			textContent("I'm ready to help.", withRole("user")),
 		},
 		GenerationConfig: genConfig,
+		SafetySettings:   config.SafetySettings,
 	}
 
 	// For Gemini 2.0 models, don't use tools at all for now
@@ -682,6 +743,11 @@ type CustomToolResponse struct {
 // SendToolResultsToBidiStream sends tool results to an existing StreamGenerateContent stream.
 // This is a compatibility function - in v1beta StreamGenerateContent doesn't support tool calls.
 func (c *Client) SendToolResultsToBidiStream(stream generativelanguagepb.GenerativeService_StreamGenerateContentClient, toolResults ...*generativelanguagepb.FunctionResponse) error {
+	// Live models accept tool responses over the WebSocket
+	if adapter, ok := stream.(*LiveStreamAdapter); ok {
+		return adapter.SendToolResponse(toolResults...)
+	}
+
 	log.Printf("SendToolResultsToBidiStream is a no-op in this implementation as StreamGenerateContent doesn't support tool responses.")
 	return nil
 }
@@ -773,6 +839,7 @@ func (c *Client) initBidiStreamAlpha(ctx context.Context, config *StreamClientCo
 			alphaTextContent("I'm ready to help.", withAlphaRole("user")),
 		},
 		GenerationConfig: alphaGenConfig,
+		SafetySettings:   toAlphaSafetySettings(config.SafetySettings),
 	}
 
 	// For Gemini 2.0 models, don't use tools at all for now
@@ -944,6 +1011,12 @@ func ExtractOutput(resp *generativelanguagepb.GenerateContentResponse) StreamOut
 
 		if candidate.Content != nil && candidate.Content.Parts != nil {
 			for _, part := range candidate.Content.Parts {
+				// Thought summaries are text parts flagged as thoughts
+				if part.GetThought() {
+					output.Thought += part.GetText()
+					continue
+				}
+
 				// Extract text
 				if textData := part.GetText(); textData != "" {
 					log.Printf("Extracted text from part: %q", textData)
@@ -954,6 +1027,11 @@ func ExtractOutput(resp *generativelanguagepb.GenerateContentResponse) StreamOut
 					output.Text += textData
 				}
 
+				// Extract function calls
+				if fc := part.GetFunctionCall(); fc != nil {
+					output.FunctionCall = fc
+				}
+
 				// Extract audio if available - checking any inline data
 				if inlineData := part.GetInlineData(); inlineData != nil {
 					log.Printf("Part contains inline data (%s, %d bytes)",
@@ -976,21 +1054,40 @@ func ExtractOutput(resp *generativelanguagepb.GenerateContentResponse) StreamOut
 		if candidate.FinishReason == generativelanguagepb.Candidate_STOP {
 			output.TurnComplete = true
 		}
+		output.FinishReason = candidate.FinishReason
+		output.SafetyRatings = candidate.SafetyRatings
+	}
+
+	// Usage is reported with the final chunk
+	if usage := resp.GetUsageMetadata(); usage != nil {
+		output.PromptTokenCount = usage.PromptTokenCount
+		output.CandidateTokenCount = usage.CandidatesTokenCount
+		output.TotalTokenCount = usage.TotalTokenCount
+		output.ThoughtsTokenCount = usage.ThoughtsTokenCount
 	}
 
 	// Handle feedback
 	if promptFeedback := resp.GetPromptFeedback(); promptFeedback != nil {
+		if promptFeedback.BlockReason != generativelanguagepb.GenerateContentResponse_PromptFeedback_BLOCK_REASON_UNSPECIFIED {
+			output.BlockReason = promptFeedback.BlockReason
+			output.SafetyRatings = promptFeedback.SafetyRatings
+		}
 		feedbackText := processFeedback(promptFeedback)
 		if feedbackText != "" {
 			output.Text += " " + feedbackText
 		}
 	}
 
-	if output.Text == "" && len(output.Audio) == 0 && output.FunctionCall == nil && output.ExecutableCode == nil && output.CodeExecutionResult == nil && output.TurnComplete == false {
+	if output.Text == "" && output.Thought == "" && len(output.Audio) == 0 && output.FunctionCall == nil && output.ExecutableCode == nil && output.CodeExecutionResult == nil && output.TurnComplete == false {
 
 		log.Printf("Received response chunk contained no processable output: %s", prototext.Format(resp))
 	}
 
+	// A blocked prompt or candidate ends the turn
+	if output.BlockedReason() != "" {
+		output.TurnComplete = true
+	}
+
 	output.Text = strings.TrimSpace(output.Text)
 	return output
 }
@@ -1020,6 +1117,19 @@ func processFeedback(promptFeedback *generativelanguagepb.GenerateContentRespons
 	return strings.Join(feedbackParts, " ")
 }
 
+// ThinkingConfig returns the thinking configuration for a request, or nil to
+// leave thinking at the model default.
+func ThinkingConfig(config *StreamClientConfig) *generativelanguagepb.ThinkingConfig {
+	if config.ThinkingBudget == nil && !config.IncludeThoughts {
+		return nil
+	}
+	tc := &generativelanguagepb.ThinkingConfig{ThinkingBudget: config.ThinkingBudget}
+	if config.IncludeThoughts {
+		tc.IncludeThoughts = &config.IncludeThoughts
+	}
+	return tc
+}
+
 type textContentOption func(c *generativelanguagepb.Content)
 
 // withRole sets the role of a Content object
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/aistudio/internal/httprr"
+)
+
+// DefaultKeyringService is the keyring service name used when a KeySource
+// enables keyring lookup without naming a service.
+const DefaultKeyringService = "aistudio"
+
+// redactedPlaceholder replaces secrets in logs and recordings.
+const redactedPlaceholder = "[REDACTED]"
+
+// keyCommandTimeout bounds how long an api_key_command may run.
+const keyCommandTimeout = 30 * time.Second
+
+// KeySource describes where to obtain an API key without passing it on the
+// command line or through the environment.
+type KeySource struct {
+	Command        string // Shell command whose trimmed stdout is the key (e.g. "pass show gemini")
+	Keyring        bool   // Look the key up in the OS keyring
+	KeyringService string // Keyring service name (defaults to DefaultKeyringService)
+	KeyringAccount string // Keyring account name (defaults to the backend name)
+}
+
+// IsZero reports whether the source has nothing configured.
+func (s KeySource) IsZero() bool {
+	return s.Command == "" && !s.Keyring
+}
+
+// keyCache holds resolved keys in memory only, keyed by backend and source,
+// so that commands and keyring lookups run at most once per process. Each
+// source has its own lock, held while it is resolved, so a slow command only
+// holds up lookups of the same key.
+var keyCache = struct {
+	sync.Mutex
+	keys    map[string]string
+	sources map[string]*sync.Mutex
+}{keys: make(map[string]string), sources: make(map[string]*sync.Mutex)}
+
+// lockKeySource locks the source with the given cache key and returns its
+// unlock function.
+func lockKeySource(cacheKey string) func() {
+	keyCache.Lock()
+	mu, ok := keyCache.sources[cacheKey]
+	if !ok {
+		mu = new(sync.Mutex)
+		keyCache.sources[cacheKey] = mu
+	}
+	keyCache.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// String returns the account name used for the backend in the keyring.
+func (b Backend) String() string {
+	switch b {
+	case BackendVertexAI:
+		return "vertexai"
+	case BackendGrok:
+		return "grok"
+	default:
+		return "gemini"
+	}
+}
+
+// ResolveAPIKey obtains an API key for the backend from the given source.
+// The command takes precedence over the keyring. An empty key with a nil
+// error means the source had nothing to offer and callers should fall back
+// to their usual environment lookup. Resolved keys are cached in memory and
+// registered for redaction.
+func ResolveAPIKey(ctx context.Context, backend Backend, src KeySource) (string, error) {
+	if src.IsZero() {
+		return "", nil
+	}
+
+	service := src.KeyringService
+	if service == "" {
+		service = DefaultKeyringService
+	}
+	account := src.KeyringAccount
+	if account == "" {
+		account = backend.String()
+	}
+	cacheKey := fmt.Sprintf("%s\x00%s\x00%t\x00%s\x00%s", backend, src.Command, src.Keyring, service, account)
+
+	defer lockKeySource(cacheKey)()
+	keyCache.Lock()
+	key, ok := keyCache.keys[cacheKey]
+	keyCache.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if src.Command != "" {
+		out, err := runKeyCommand(ctx, src.Command)
+		if err != nil {
+			return "", fmt.Errorf("api key command for %s failed: %w", backend, err)
+		}
+		key = out
+		log.Printf("Using API key for %s from api_key_command", backend)
+	} else if src.Keyring {
+		out, err := lookupKeyring(ctx, service, account)
+		if err != nil {
+			log.Printf("No API key for %s in keyring (service %q, account %q): %v", backend, service, account, err)
+			return "", nil
+		}
+		key = out
+		log.Printf("Using API key for %s from OS keyring", backend)
+	}
+
+	if key == "" {
+		return "", nil
+	}
+	RegisterSecret(key)
+	keyCache.Lock()
+	keyCache.keys[cacheKey] = key
+	keyCache.Unlock()
+	return key, nil
+}
+
+// runKeyCommand runs command through the shell and returns its trimmed stdout.
+// Stderr is passed through to the error so prompts such as gpg-agent failures
+// are visible, but stdout is never logged.
+func runKeyCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, keyCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	// Tools like pass print the secret on the first line followed by metadata.
+	key, _, _ := strings.Cut(stdout.String(), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("command produced no output")
+	}
+	return key, nil
+}
+
+// lookupKeyring reads a secret from the platform keyring using the native
+// command line helper (security on macOS, secret-tool elsewhere).
+func lookupKeyring(ctx context.Context, service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", fmt.Errorf("keyring lookup is not supported on %s", runtime.GOOS)
+	default:
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("empty secret")
+	}
+	return key, nil
+}
+
+// ----------------------------------------
+// Redaction
+// ----------------------------------------
+
+// minSecretLength avoids redacting short strings that would mangle output.
+const minSecretLength = 8
+
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// RegisterSecret records a value that must never appear in logs or recordings.
+func RegisterSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, s := range secrets.values {
+		if s == secret {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, secret)
+}
+
+// Redact replaces every registered secret in s with a placeholder.
+func Redact(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.values {
+		s = strings.ReplaceAll(s, secret, redactedPlaceholder)
+	}
+	return s
+}
+
+// RedactBytes is like Redact but operates on a byte slice.
+func RedactBytes(b []byte) []byte {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, secret := range secrets.values {
+		b = bytes.ReplaceAll(b, []byte(secret), []byte(redactedPlaceholder))
+	}
+	return b
+}
+
+// redactingWriter scrubs registered secrets from everything written through it.
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter wraps w so that registered secrets are redacted.
+// It is intended for log output.
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write(RedactBytes(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sensitiveHeaders carry credentials in requests to the supported backends.
+var sensitiveHeaders = []string{"Authorization", "X-Goog-Api-Key"}
+
+// ScrubHTTPRequest removes credentials from a request before it is written
+// to an HTTP recording. It is suitable for use with httprr's ScrubReq.
+func ScrubHTTPRequest(req *http.Request) error {
+	for _, h := range sensitiveHeaders {
+		if req.Header.Get(h) != "" {
+			req.Header.Set(h, redactedPlaceholder)
+		}
+	}
+	if req.URL != nil {
+		q := req.URL.Query()
+		if q.Get("key") != "" {
+			q.Set("key", redactedPlaceholder)
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+	if body, ok := req.Body.(*httprr.Body); ok {
+		body.Data = RedactBytes(body.Data)
+	}
+	return nil
+}
+
+// ScrubHTTPResponse removes registered secrets from a recorded response.
+// It is suitable for use with httprr's ScrubResp.
+func ScrubHTTPResponse(buf *bytes.Buffer) error {
+	data := RedactBytes(buf.Bytes())
+	buf.Reset()
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmc/aistudio/internal/httprr"
+)
+
+// TestResolveAPIKeyCommand tests that api_key_command output is used and cached
+func TestResolveAPIKeyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+
+	counter := filepath.Join(t.TempDir(), "count")
+	src := KeySource{
+		Command: "echo run >> " + counter + "; printf 'cmd-secret-key-123\\nlogin: me\\n'",
+	}
+
+	for i := 0; i < 3; i++ {
+		key, err := ResolveAPIKey(context.Background(), BackendGrok, src)
+		if err != nil {
+			t.Fatalf("ResolveAPIKey failed: %v", err)
+		}
+		if key != "cmd-secret-key-123" {
+			t.Fatalf("Expected first line of command output, got %q", key)
+		}
+	}
+
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("Failed to read counter file: %v", err)
+	}
+	if runs := strings.Count(string(data), "run"); runs != 1 {
+		t.Errorf("Expected command to run once, ran %d times", runs)
+	}
+
+	if got := Redact("key is cmd-secret-key-123"); got != "key is "+redactedPlaceholder {
+		t.Errorf("Expected resolved key to be redacted, got %q", got)
+	}
+}
+
+// TestResolveAPIKeySlowCommand tests that a running command does not hold up
+// lookups of other keys
+func TestResolveAPIKeySlowCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+
+	cached := KeySource{Command: "echo cached-secret-key-456"}
+	if _, err := ResolveAPIKey(context.Background(), BackendGeminiAPI, cached); err != nil {
+		t.Fatalf("ResolveAPIKey failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	started, release := filepath.Join(dir, "started"), filepath.Join(dir, "release")
+	slow := make(chan error, 1)
+	go func() {
+		_, err := ResolveAPIKey(context.Background(), BackendGrok, KeySource{
+			Command: "touch " + started + "; while [ ! -e " + release + " ]; do sleep 0.01; done; echo slow-secret-key-789",
+		})
+		slow <- err
+	}()
+	defer func() {
+		os.WriteFile(release, nil, 0o600)
+		if err := <-slow; err != nil {
+			t.Errorf("Slow command failed: %v", err)
+		}
+	}()
+
+	for {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	done := make(chan struct{})
+	go func() {
+		ResolveAPIKey(context.Background(), BackendGeminiAPI, cached)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected a cached key while another command runs")
+	}
+}
+
+// TestResolveAPIKeyCommandFailure tests that a failing command surfaces its stderr
+func TestResolveAPIKeyCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+
+	_, err := ResolveAPIKey(context.Background(), BackendGeminiAPI, KeySource{Command: "echo vault locked >&2; exit 3"})
+	if err == nil {
+		t.Fatal("Expected error from failing command")
+	}
+	if !strings.Contains(err.Error(), "vault locked") {
+		t.Errorf("Expected stderr in error, got %v", err)
+	}
+}
+
+// TestResolveAPIKeyEmptySource tests that an empty source defers to other lookups
+func TestResolveAPIKeyEmptySource(t *testing.T) {
+	key, err := ResolveAPIKey(context.Background(), BackendGeminiAPI, KeySource{})
+	if err != nil || key != "" {
+		t.Errorf("Expected empty key and nil error, got %q, %v", key, err)
+	}
+}
+
+// TestRedactingWriter tests that registered secrets never reach log output
+func TestRedactingWriter(t *testing.T) {
+	RegisterSecret("writer-secret-abcdef")
+	RegisterSecret("short") // Too short to redact safely
+
+	var buf bytes.Buffer
+	w := NewRedactingWriter(&buf)
+	msg := "using writer-secret-abcdef for a short request\n"
+	n, err := w.Write([]byte(msg))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if n != len(msg) {
+		t.Errorf("Expected %d bytes written, got %d", len(msg), n)
+	}
+	if want := "using [REDACTED] for a short request\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+// TestScrubHTTPRequest tests that credentials are removed from recorded requests
+func TestScrubHTTPRequest(t *testing.T) {
+	RegisterSecret("body-secret-987654")
+
+	req, err := http.NewRequest("POST", "https://example.com/v1/models?key=query-secret&alt=json", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer header-secret")
+	req.Header.Set("x-goog-api-key", "goog-secret")
+	req.Body = &httprr.Body{Data: []byte(`{"token":"body-secret-987654"}`)}
+
+	if err := ScrubHTTPRequest(req); err != nil {
+		t.Fatalf("ScrubHTTPRequest failed: %v", err)
+	}
+
+	if got := req.Header.Get("Authorization"); got != redactedPlaceholder {
+		t.Errorf("Authorization not redacted: %q", got)
+	}
+	if got := req.Header.Get("x-goog-api-key"); got != redactedPlaceholder {
+		t.Errorf("x-goog-api-key not redacted: %q", got)
+	}
+	if got := req.URL.Query().Get("key"); got != redactedPlaceholder {
+		t.Errorf("key query parameter not redacted: %q", got)
+	}
+	if body := string(req.Body.(*httprr.Body).Data); strings.Contains(body, "body-secret-987654") {
+		t.Errorf("Body not redacted: %s", body)
+	}
+}
+
+// TestWSRecorderRedactsSecrets tests that WebSocket recordings never contain keys
+func TestWSRecorderRedactsSecrets(t *testing.T) {
+	RegisterSecret("ws-secret-key-5555")
+
+	recordFile := filepath.Join(t.TempDir(), "redact.wsrec")
+	recorder, err := NewWSRecorder(recordFile, true)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.RecordSend([]byte(`{"apiKey":"ws-secret-key-5555"}`), 1); err != nil {
+		t.Fatalf("RecordSend failed: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(recordFile)
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	if strings.Contains(string(data), "ws-secret-key-5555") {
+		t.Errorf("Recording contains secret: %s", data)
+	}
+}
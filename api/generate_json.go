@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+)
+
+// DefaultGenerateModel is the model GenerateJSON uses when none is configured.
+const DefaultGenerateModel = "models/gemini-2.5-flash"
+
+// SchemaValidationError reports a response that does not match the schema
+// it was generated with.
+type SchemaValidationError struct {
+	Response string   // The raw response text
+	Problems []string // One entry per mismatch, see ValidateJSON
+}
+
+func (e *SchemaValidationError) Error() string {
+	return "response does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// GenerateJSON asks the model for a JSON answer to prompt shaped like T and
+// decodes it. The response schema is derived from T with SchemaFor. The
+// optional config selects the model and generation parameters; only its
+// model, sampling, thinking, safety and system prompt settings are used.
+// A response that fails validation is returned as a *SchemaValidationError.
+func GenerateJSON[T any](ctx context.Context, c *Client, prompt string, config *StreamClientConfig) (T, error) {
+	var result T
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return result, err
+	}
+
+	if c.GenerativeClient == nil {
+		if err := c.InitClient(ctx); err != nil {
+			return result, err
+		}
+		if c.GenerativeClient == nil {
+			return result, fmt.Errorf("GenerateJSON requires the Gemini API backend")
+		}
+	}
+
+	resp, err := c.GenerativeClient.GenerateContent(ctx, generateJSONRequest(prompt, schema, config))
+	if err != nil {
+		return result, fmt.Errorf("failed to generate content: %w", err)
+	}
+	output := ExtractOutput(resp)
+	if reason := output.BlockedReason(); reason != "" {
+		return result, fmt.Errorf("generation failed: %s", reason)
+	}
+
+	if problems := ValidateJSON([]byte(output.Text), schema); len(problems) > 0 {
+		return result, &SchemaValidationError{Response: output.Text, Problems: problems}
+	}
+	if err := json.Unmarshal([]byte(output.Text), &result); err != nil {
+		return result, fmt.Errorf("failed to decode response into %T: %w", result, err)
+	}
+	return result, nil
+}
+
+// generateJSONRequest builds a single-turn request constrained to schema.
+func generateJSONRequest(prompt string, schema *generativelanguagepb.Schema, config *StreamClientConfig) *generativelanguagepb.GenerateContentRequest {
+	genConfig := &generativelanguagepb.GenerationConfig{
+		ResponseMimeType: "application/json",
+		ResponseSchema:   schema,
+	}
+	request := &generativelanguagepb.GenerateContentRequest{
+		Model:            DefaultGenerateModel,
+		Contents:         []*generativelanguagepb.Content{textContent(prompt, withRole("user"))},
+		GenerationConfig: genConfig,
+	}
+	if config == nil {
+		return request
+	}
+
+	if config.ModelName != "" {
+		request.Model = config.ModelName
+	}
+	if config.SystemPrompt != "" {
+		request.SystemInstruction = textContent(config.SystemPrompt)
+	}
+	request.SafetySettings = config.SafetySettings
+
+	genConfig.Temperature = &config.Temperature
+	if config.TopP > 0 {
+		genConfig.TopP = &config.TopP
+	}
+	if config.TopK > 0 {
+		genConfig.TopK = &config.TopK
+	}
+	if config.MaxOutputTokens > 0 {
+		genConfig.MaxOutputTokens = &config.MaxOutputTokens
+	}
+	genConfig.ThinkingConfig = ThinkingConfig(config)
+	return request
+}
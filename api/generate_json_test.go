@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type testRecipe struct {
+	Name        string       `json:"name" jsonschema:"description=Name of the dish, in English"`
+	Course      string       `json:"course" jsonschema:"enum=starter|main|dessert"`
+	Servings    int          `json:"servings"`
+	Ingredients []testAmount `json:"ingredients"`
+	Notes       string       `json:"notes,omitempty"`
+	Rating      *float64     `json:"rating"`
+	Internal    string       `json:"-"`
+}
+
+type testAmount struct {
+	Item     string  `json:"item"`
+	Quantity float64 `json:"quantity" jsonschema:"optional"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	s, err := SchemaFor[testRecipe]()
+	if err != nil {
+		t.Fatalf("SchemaFor failed: %v", err)
+	}
+	if s.Type != generativelanguagepb.Type_OBJECT {
+		t.Fatalf("Expected object, got %v", s.Type)
+	}
+	wantOrder := []string{"name", "course", "servings", "ingredients", "notes", "rating"}
+	if !reflect.DeepEqual(s.PropertyOrdering, wantOrder) {
+		t.Errorf("PropertyOrdering = %q, want %q", s.PropertyOrdering, wantOrder)
+	}
+	if want := []string{"name", "course", "servings", "ingredients"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("Required = %q, want %q", s.Required, want)
+	}
+	if d := s.Properties["name"].Description; d != "Name of the dish, in English" {
+		t.Errorf("Unexpected description %q", d)
+	}
+	if e := s.Properties["course"].Enum; !reflect.DeepEqual(e, []string{"starter", "main", "dessert"}) {
+		t.Errorf("Unexpected enum %q", e)
+	}
+	if p := s.Properties["servings"]; p.Type != generativelanguagepb.Type_INTEGER {
+		t.Errorf("Expected integer servings, got %v", p.Type)
+	}
+	if p := s.Properties["rating"]; p.Type != generativelanguagepb.Type_NUMBER || !p.Nullable {
+		t.Errorf("Expected nullable number rating, got %v", p)
+	}
+	items := s.Properties["ingredients"].GetItems()
+	if items.GetType() != generativelanguagepb.Type_OBJECT || !reflect.DeepEqual(items.Required, []string{"item"}) {
+		t.Errorf("Unexpected ingredient schema: %v", items)
+	}
+}
+
+func TestSchemaForErrors(t *testing.T) {
+	type node struct {
+		Children []node `json:"children"`
+	}
+	if _, err := SchemaFor[node](); err == nil {
+		t.Error("Expected an error for a recursive type")
+	}
+	if _, err := SchemaFor[struct {
+		N int `jsonschema:"enum=1|2"`
+	}](); err == nil {
+		t.Error("Expected an error for an enum on a non-string field")
+	}
+	if _, err := SchemaFor[map[string]any](); err == nil {
+		t.Error("Expected an error for a map")
+	}
+}
+
+// fakeGenerativeService answers GenerateContent with a fixed text response
+type fakeGenerativeService struct {
+	generativelanguagepb.UnimplementedGenerativeServiceServer
+	response string
+	request  *generativelanguagepb.GenerateContentRequest
+}
+
+func (s *fakeGenerativeService) GenerateContent(ctx context.Context, req *generativelanguagepb.GenerateContentRequest) (*generativelanguagepb.GenerateContentResponse, error) {
+	s.request = req
+	return &generativelanguagepb.GenerateContentResponse{
+		Candidates: []*generativelanguagepb.Candidate{{
+			Content: textContent(s.response, withRole("model")),
+		}},
+	}, nil
+}
+
+func newFakeGenerativeClient(t *testing.T, svc *fakeGenerativeService) *Client {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	generativelanguagepb.RegisterGenerativeServiceServer(server, svc)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{}
+	if err := c.InitWithGRPCConn(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestGenerateJSON(t *testing.T) {
+	svc := &fakeGenerativeService{response: `{"name":"Soup","course":"starter","servings":2,"ingredients":[{"item":"leek"}],"rating":null}`}
+	c := newFakeGenerativeClient(t, svc)
+
+	recipe, err := GenerateJSON[testRecipe](context.Background(), c, "A quick soup", &StreamClientConfig{ModelName: "models/test", SystemPrompt: "Be brief"})
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+	if recipe.Name != "Soup" || recipe.Servings != 2 || len(recipe.Ingredients) != 1 || recipe.Rating != nil {
+		t.Errorf("Unexpected recipe: %+v", recipe)
+	}
+
+	req := svc.request
+	if req.Model != "models/test" || req.GetSystemInstruction() == nil {
+		t.Errorf("Expected the configured model and system prompt, got %v", req)
+	}
+	if gc := req.GetGenerationConfig(); gc.ResponseMimeType != "application/json" || gc.GetResponseSchema().GetType() != generativelanguagepb.Type_OBJECT {
+		t.Errorf("Expected a JSON response schema, got %v", gc)
+	}
+}
+
+func TestGenerateJSONValidationError(t *testing.T) {
+	svc := &fakeGenerativeService{response: `{"name":"Soup","course":"soup","servings":2,"ingredients":[]}`}
+	c := newFakeGenerativeClient(t, svc)
+
+	_, err := GenerateJSON[testRecipe](context.Background(), c, "A quick soup", nil)
+	var verr *SchemaValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a SchemaValidationError, got %v", err)
+	}
+	want := []string{`$.course: "soup" is not one of ["starter" "main" "dessert"]`}
+	if !reflect.DeepEqual(verr.Problems, want) {
+		t.Errorf("Problems = %q, want %q", verr.Problems, want)
+	}
+	if svc.request.Model != DefaultGenerateModel {
+		t.Errorf("Expected the default model, got %q", svc.request.Model)
+	}
+}
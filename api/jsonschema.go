@@ -0,0 +1,243 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// JSONSchema represents a standard JSON Schema structure, used for intermediate
+// unmarshalling of tool parameters before converting to the protobuf Schema type.
+type JSONSchema struct {
+	Type        string                 `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Nullable    bool                   `json:"nullable,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"` // Recursive for object
+	Required    []string               `json:"required,omitempty"`   // For object
+	Items       *JSONSchema            `json:"items,omitempty"`      // Recursive for array
+	MinItems    int64                  `json:"minItems,omitempty"`   // For array
+	MaxItems    int64                  `json:"maxItems,omitempty"`   // For array
+	Minimum     *float64               `json:"minimum,omitempty"`    // For number and integer
+	Maximum     *float64               `json:"maximum,omitempty"`    // For number and integer
+	Default     any                    `json:"default,omitempty"`    // Used when the value is left out
+}
+
+// ConvertJSONSchema converts the intermediate JSONSchema representation
+// into the generativelanguagepb.Schema format required by the API.
+func ConvertJSONSchema(js *JSONSchema) (*generativelanguagepb.Schema, error) {
+	if js == nil {
+		return nil, nil
+	}
+
+	protoSchema := &generativelanguagepb.Schema{
+		Description: js.Description,
+		Nullable:    js.Nullable,
+		Format:      js.Format,
+		Enum:        js.Enum,
+		MinItems:    js.MinItems,
+		MaxItems:    js.MaxItems,
+		Minimum:     js.Minimum,
+		Maximum:     js.Maximum,
+	}
+	if js.Default != nil {
+		var err error
+		if protoSchema.Default, err = structpb.NewValue(js.Default); err != nil {
+			return nil, fmt.Errorf("invalid default for field '%s': %w", js.Description, err)
+		}
+	}
+
+	// Convert the type string to the corresponding generativelanguagepb.Type enum
+	switch strings.ToLower(js.Type) {
+	case "string":
+		protoSchema.Type = generativelanguagepb.Type_STRING
+	case "integer", "int", "int32", "int64":
+		protoSchema.Type = generativelanguagepb.Type_INTEGER
+	case "number", "float", "double":
+		protoSchema.Type = generativelanguagepb.Type_NUMBER
+	case "boolean", "bool":
+		protoSchema.Type = generativelanguagepb.Type_BOOLEAN
+	case "array":
+		protoSchema.Type = generativelanguagepb.Type_ARRAY
+		if js.Items != nil {
+			var err error
+			protoSchema.Items, err = ConvertJSONSchema(js.Items)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert array items for field '%s': %w", js.Description, err)
+			}
+		}
+	case "object":
+		protoSchema.Type = generativelanguagepb.Type_OBJECT
+		protoSchema.Properties = make(map[string]*generativelanguagepb.Schema)
+		for key, propJSONSchema := range js.Properties {
+			var err error
+			protoSchema.Properties[key], err = ConvertJSONSchema(propJSONSchema)
+			if err != nil {
+				// Add context about which property failed
+				return nil, fmt.Errorf("failed to convert object property '%s' (described as '%s'): %w", key, propJSONSchema.Description, err)
+			}
+		}
+		protoSchema.Required = js.Required
+	case "":
+		// If type is empty, treat as unspecified. Could potentially infer object if properties exist,
+		// but explicit type is preferred.
+		protoSchema.Type = generativelanguagepb.Type_TYPE_UNSPECIFIED
+		if len(js.Properties) > 0 {
+			log.Printf("Warning: Schema for field '%s' has properties but no 'type' specified. Treating as unspecified.", js.Description)
+		}
+	default:
+		// Add context about the field description
+		return nil, fmt.Errorf("unsupported schema type: '%s' for field '%s'", js.Type, js.Description)
+	}
+
+	return protoSchema, nil
+}
+
+// SchemaFor derives a response schema from the Go type T, following the
+// field names encoding/json uses. Fields are required unless they are
+// pointers or tagged omitempty. A jsonschema struct tag refines a field:
+//
+//	Kind  string `json:"kind" jsonschema:"enum=fruit|vegetable"`
+//	Notes string `json:"notes" jsonschema:"optional,description=Anything else, in one line"`
+//
+// The description option must come last, as it runs to the end of the tag.
+func SchemaFor[T any]() (*generativelanguagepb.Schema, error) {
+	return schemaForType(reflect.TypeFor[T](), nil)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemaForType builds the schema for t. seen holds the struct types being
+// expanded, as recursive types cannot be expressed in a response schema.
+func schemaForType(t reflect.Type, seen []reflect.Type) (*generativelanguagepb.Schema, error) {
+	if t == timeType {
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_STRING, Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s, err := schemaForType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		s.Nullable = true
+		return s, nil
+	case reflect.String:
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_STRING}, nil
+	case reflect.Bool:
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_BOOLEAN}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_INTEGER}, nil
+	case reflect.Float32, reflect.Float64:
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_NUMBER}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_STRING, Format: "byte"}, nil
+		}
+		items, err := schemaForType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &generativelanguagepb.Schema{Type: generativelanguagepb.Type_ARRAY, Items: items}, nil
+	case reflect.Struct:
+		for _, s := range seen {
+			if s == t {
+				return nil, fmt.Errorf("recursive type %s cannot be used as a response schema", t)
+			}
+		}
+		s := &generativelanguagepb.Schema{
+			Type:       generativelanguagepb.Type_OBJECT,
+			Properties: make(map[string]*generativelanguagepb.Schema),
+		}
+		if err := addStructFields(s, t, append(seen, t)); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s for a response schema", t)
+}
+
+// addStructFields adds the JSON fields of struct type t to s, promoting the
+// fields of untagged embedded structs as encoding/json does.
+func addStructFields(s *generativelanguagepb.Schema, t reflect.Type, seen []reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, jsonOpts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addStructFields(s, ft, seen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, err := schemaForType(f.Type, seen)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		required := f.Type.Kind() != reflect.Pointer && !strings.Contains(","+jsonOpts+",", ",omitempty,")
+		if required, err = applySchemaTag(prop, f.Tag.Get("jsonschema"), required); err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+
+		s.Properties[name] = prop
+		s.PropertyOrdering = append(s.PropertyOrdering, name)
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// applySchemaTag applies the options of a jsonschema struct tag to prop and
+// returns whether the field is required.
+func applySchemaTag(prop *generativelanguagepb.Schema, tag string, required bool) (bool, error) {
+	for tag != "" {
+		var opt string
+		if strings.HasPrefix(tag, "description=") {
+			opt, tag = tag, ""
+		} else {
+			opt, tag, _ = strings.Cut(tag, ",")
+		}
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "required":
+			required = true
+		case "optional":
+			required = false
+		case "description":
+			prop.Description = value
+		case "enum":
+			if prop.Type != generativelanguagepb.Type_STRING {
+				return false, fmt.Errorf("enum is only supported on strings")
+			}
+			prop.Enum = strings.Split(value, "|")
+		default:
+			return false, fmt.Errorf("unknown jsonschema option %q", key)
+		}
+	}
+	return required, nil
+}
@@ -10,12 +10,18 @@ import (
 	"sync"
 	"time"
 
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
 	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const (
 	// LiveModelEndpoint is the WebSocket endpoint for Gemini Live API
 	LiveModelEndpoint = "wss://generativelanguage.googleapis.com/ws/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateContent"
+
+	// maxLiveReconnectAttempts bounds reconnects after a dropped connection
+	maxLiveReconnectAttempts = 5
 )
 
 // LiveClient manages communication with Gemini live models through WebSockets
@@ -31,8 +37,9 @@ type LiveClient struct {
 	cancel context.CancelFunc
 
 	// Authentication and model config
-	apiKey string
-	model  string
+	apiKey   string
+	model    string
+	endpoint string // WebSocket URL, LiveModelEndpoint unless overridden in tests
 
 	// Stream configuration
 	temperature     float32
@@ -42,6 +49,31 @@ type LiveClient struct {
 	systemPrompt    string
 	enableAudio     bool
 	voiceName       string
+	manualActivity  bool // Client marks speech with activityStart/activityEnd
+	transcribe      bool // Ask the server to transcribe spoken input and output
+	tools           []*ToolDefinition
+	thinkingBudget  *int32
+	includeThoughts bool
+
+	// Context window compression; see LiveContextWindowCompression
+	compression        bool
+	compressionTrigger int64
+	compressionTarget  int64
+
+	// Set by Interrupt; server content is dropped until the turn ends
+	discardTurn bool
+
+	// Outputs split from a single server message (e.g. parallel tool calls)
+	// waiting to be returned by ReceiveMessage
+	pending []*StreamOutput
+
+	// Session resumption: the server periodically issues a handle that lets a
+	// new connection pick up the same session, which we use to survive
+	// GoAway notices and dropped connections.
+	resumptionHandle string        // Latest resumable handle from sessionResumptionUpdate
+	goAwayPending    bool          // Server announced it will close; reconnect at the next turn boundary
+	turnActive       bool          // A user turn was sent and the model hasn't finished it
+	reconnecting     chan struct{} // Closed when the reconnect in progress ends
 
 	// Recording and replay support
 	recorder    *WSRecorder
@@ -56,20 +88,65 @@ type LiveSetupRequest struct {
 
 // LiveSetupConfig contains configuration for the Live API session
 type LiveSetupConfig struct {
-	Model             string               `json:"model"`
-	GenerationConfig  LiveGenerationConfig `json:"generationConfig,omitempty"`
-	SystemInstruction *LiveContent         `json:"systemInstruction,omitempty"`
-	Tools             []LiveTool           `json:"tools,omitempty"`
+	Model               string                       `json:"model"`
+	GenerationConfig    LiveGenerationConfig         `json:"generationConfig,omitempty"`
+	SystemInstruction   *LiveContent                 `json:"systemInstruction,omitempty"`
+	Tools               []LiveTool                   `json:"tools,omitempty"`
+	SessionResumption   *LiveSessionResumptionConfig `json:"sessionResumption,omitempty"`
+	RealtimeInputConfig *LiveRealtimeInputConfig     `json:"realtimeInputConfig,omitempty"`
+
+	ContextWindowCompression *LiveContextWindowCompression `json:"contextWindowCompression,omitempty"`
+
+	// Empty objects enable transcription of the user's and model's audio
+	InputAudioTranscription  *struct{} `json:"inputAudioTranscription,omitempty"`
+	OutputAudioTranscription *struct{} `json:"outputAudioTranscription,omitempty"`
+}
+
+// LiveContextWindowCompression lets the server drop the oldest turns once the
+// context reaches TriggerTokens, keeping sessions alive past the model's
+// context limit. Zero values use the server defaults.
+type LiveContextWindowCompression struct {
+	TriggerTokens int64              `json:"triggerTokens,omitempty"`
+	SlidingWindow *LiveSlidingWindow `json:"slidingWindow"`
+}
+
+// LiveSlidingWindow compresses by truncating to TargetTokens
+type LiveSlidingWindow struct {
+	TargetTokens int64 `json:"targetTokens,omitempty"`
+}
+
+// LiveRealtimeInputConfig configures how realtime input is turned into turns
+type LiveRealtimeInputConfig struct {
+	AutomaticActivityDetection *LiveAutomaticActivityDetection `json:"automaticActivityDetection,omitempty"`
+}
+
+// LiveAutomaticActivityDetection controls server-side voice activity detection.
+// When disabled, the client must bracket speech with activityStart/activityEnd.
+type LiveAutomaticActivityDetection struct {
+	Disabled bool `json:"disabled"`
+}
+
+// LiveSessionResumptionConfig requests resumption handles from the server.
+// An empty handle starts a new resumable session; a previous handle resumes it.
+type LiveSessionResumptionConfig struct {
+	Handle string `json:"handle,omitempty"`
 }
 
 // LiveGenerationConfig contains generation parameters for the Live API
 type LiveGenerationConfig struct {
-	Temperature        *float32          `json:"temperature,omitempty"`
-	TopP               *float32          `json:"topP,omitempty"`
-	TopK               *int32            `json:"topK,omitempty"`
-	MaxOutputTokens    *int32            `json:"maxOutputTokens,omitempty"`
-	ResponseModalities []string          `json:"responseModalities,omitempty"`
-	SpeechConfig       *LiveSpeechConfig `json:"speechConfig,omitempty"`
+	Temperature        *float32            `json:"temperature,omitempty"`
+	TopP               *float32            `json:"topP,omitempty"`
+	TopK               *int32              `json:"topK,omitempty"`
+	MaxOutputTokens    *int32              `json:"maxOutputTokens,omitempty"`
+	ResponseModalities []string            `json:"responseModalities,omitempty"`
+	SpeechConfig       *LiveSpeechConfig   `json:"speechConfig,omitempty"`
+	ThinkingConfig     *LiveThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// LiveThinkingConfig controls thinking for models that support it
+type LiveThinkingConfig struct {
+	ThinkingBudget  *int32 `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool   `json:"includeThoughts,omitempty"`
 }
 
 // LiveSpeechConfig configures speech output for audio responses
@@ -95,7 +172,8 @@ type LiveContent struct {
 
 // LivePart represents a part of a message
 type LivePart struct {
-	Text string `json:"text,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Thought bool   `json:"thought,omitempty"` // Text is a thought summary
 }
 
 // LiveTool represents a tool definition for the Live API
@@ -115,6 +193,45 @@ type LiveClientMessageRequest struct {
 	ClientContent *LiveClientContent `json:"clientContent,omitempty"`
 }
 
+// LiveRealtimeInputRequest streams realtime input to the Live API
+type LiveRealtimeInputRequest struct {
+	RealtimeInput *LiveRealtimeInput `json:"realtimeInput"`
+}
+
+// LiveRealtimeInput carries one piece of realtime input. Exactly one field is
+// set per message.
+type LiveRealtimeInput struct {
+	Audio         *LiveBlob `json:"audio,omitempty"`
+	Video         *LiveBlob `json:"video,omitempty"`
+	Text          string    `json:"text,omitempty"`
+	ActivityStart *struct{} `json:"activityStart,omitempty"`
+	ActivityEnd   *struct{} `json:"activityEnd,omitempty"`
+}
+
+// LiveBlob is inline media data; Data is base64-encoded on the wire
+type LiveBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// LiveToolResponseRequest returns function results to the Live API
+type LiveToolResponseRequest struct {
+	ToolResponse *LiveToolResponse `json:"toolResponse"`
+}
+
+// LiveToolResponse carries the results of one or more tool calls
+type LiveToolResponse struct {
+	FunctionResponses []LiveFunctionResponse `json:"functionResponses"`
+}
+
+// LiveFunctionResponse is the result of a single function call, matched to
+// the call by ID
+type LiveFunctionResponse struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
 // LiveClientContent contains the content of a client message
 type LiveClientContent struct {
 	Turns        []LiveContent `json:"turns"`
@@ -123,9 +240,42 @@ type LiveClientContent struct {
 
 // LiveServerResponse represents a response from the Live API
 type LiveServerResponse struct {
-	ServerContent *LiveServerContent `json:"serverContent,omitempty"`
-	SetupComplete *struct{}          `json:"setupComplete,omitempty"`
-	UsageMetadata *LiveUsageMetadata `json:"usageMetadata,omitempty"`
+	ServerContent           *LiveServerContent           `json:"serverContent,omitempty"`
+	SetupComplete           *struct{}                    `json:"setupComplete,omitempty"`
+	UsageMetadata           *LiveUsageMetadata           `json:"usageMetadata,omitempty"`
+	GoAway                  *LiveGoAway                  `json:"goAway,omitempty"`
+	SessionResumptionUpdate *LiveSessionResumptionUpdate `json:"sessionResumptionUpdate,omitempty"`
+	ToolCall                *LiveToolCall                `json:"toolCall,omitempty"`
+	ToolCallCancellation    *LiveToolCallCancellation    `json:"toolCallCancellation,omitempty"`
+}
+
+// LiveToolCall asks the client to run one or more functions
+type LiveToolCall struct {
+	FunctionCalls []LiveFunctionCall `json:"functionCalls"`
+}
+
+// LiveFunctionCall is a single function call requested by the model
+type LiveFunctionCall struct {
+	ID   string         `json:"id"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// LiveToolCallCancellation withdraws earlier tool calls, for example when the
+// user interrupts the model while tools are still running
+type LiveToolCallCancellation struct {
+	IDs []string `json:"ids"`
+}
+
+// LiveGoAway warns that the server will close the connection soon
+type LiveGoAway struct {
+	TimeLeft string `json:"timeLeft,omitempty"` // Duration such as "10s"
+}
+
+// LiveSessionResumptionUpdate carries a new handle for resuming the session
+type LiveSessionResumptionUpdate struct {
+	NewHandle string `json:"newHandle,omitempty"`
+	Resumable bool   `json:"resumable"`
 }
 
 // LiveServerContent contains the content of a server response
@@ -134,6 +284,14 @@ type LiveServerContent struct {
 	TurnComplete       bool         `json:"turnComplete"`
 	GenerationComplete bool         `json:"generationComplete"`
 	Interrupted        bool         `json:"interrupted"`
+
+	InputTranscription  *LiveTranscription `json:"inputTranscription,omitempty"`
+	OutputTranscription *LiveTranscription `json:"outputTranscription,omitempty"`
+}
+
+// LiveTranscription is a fragment of transcribed speech
+type LiveTranscription struct {
+	Text string `json:"text"`
 }
 
 // LiveUsageMetadata contains usage information
@@ -141,6 +299,7 @@ type LiveUsageMetadata struct {
 	PromptTokenCount   int32 `json:"promptTokenCount"`
 	ResponseTokenCount int32 `json:"responseTokenCount"`
 	TotalTokenCount    int32 `json:"totalTokenCount"`
+	ThoughtsTokenCount int32 `json:"thoughtsTokenCount,omitempty"`
 }
 
 // NewLiveClient creates a new client for the Gemini Live API
@@ -148,6 +307,7 @@ func NewLiveClient(ctx context.Context, apiKey string, config *StreamClientConfi
 	if apiKey == "" {
 		return nil, fmt.Errorf("API key is required for Live API")
 	}
+	RegisterSecret(apiKey)
 
 	if config.ModelName == "" {
 		return nil, fmt.Errorf("model name is required")
@@ -166,6 +326,7 @@ func NewLiveClient(ctx context.Context, apiKey string, config *StreamClientConfi
 	client := &LiveClient{
 		apiKey:          apiKey,
 		model:           modelName,
+		endpoint:        LiveModelEndpoint,
 		ctx:             ctxWithCancel,
 		cancel:          cancel,
 		temperature:     config.Temperature,
@@ -175,7 +336,16 @@ func NewLiveClient(ctx context.Context, apiKey string, config *StreamClientConfi
 		systemPrompt:    config.SystemPrompt,
 		enableAudio:     config.EnableAudio,
 		voiceName:       config.VoiceName,
+		manualActivity:  config.ManualActivityDetection,
+		transcribe:      config.AudioTranscription,
+		tools:           config.ToolDefinitions,
+		thinkingBudget:  config.ThinkingBudget,
+		includeThoughts: config.IncludeThoughts,
 		recorder:        recorder,
+
+		compression:        config.ContextWindowCompression,
+		compressionTrigger: config.CompressionTriggerTokens,
+		compressionTarget:  config.CompressionTargetTokens,
 	}
 
 	// If recorder is provided and in replay mode, set replay mode
@@ -206,8 +376,21 @@ func (c *LiveClient) Initialize() error {
 		return nil
 	}
 
-	// Connect to the WebSocket endpoint
-	log.Printf("Connecting to Live API endpoint: %s", LiveModelEndpoint)
+	conn, err := c.connect(c.resumptionHandle)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+
+	c.initialized = true
+	log.Printf("Live API session established successfully for model: %s", c.model)
+	return nil
+}
+
+// connect dials the WebSocket endpoint and completes setup, resuming the
+// session with handle if it is set. It leaves c.conn to the caller.
+func (c *LiveClient) connect(handle string) (*websocket.Conn, error) {
+	log.Printf("Connecting to Live API endpoint: %s", c.endpoint)
 	header := http.Header{}
 	header.Add("x-goog-api-key", c.apiKey)
 
@@ -215,34 +398,111 @@ func (c *LiveClient) Initialize() error {
 		HandshakeTimeout: 30 * time.Second,
 	}
 
-	conn, resp, err := dialer.DialContext(c.ctx, LiveModelEndpoint, header)
+	conn, resp, err := dialer.DialContext(c.ctx, c.endpoint, header)
 	if err != nil {
 		if resp != nil {
-			return fmt.Errorf("failed to connect to Live API: %v (HTTP status: %d)", err, resp.StatusCode)
+			return nil, fmt.Errorf("failed to connect to Live API: %v (HTTP status: %d)", err, resp.StatusCode)
 		}
-		return fmt.Errorf("failed to connect to Live API: %v", err)
+		return nil, fmt.Errorf("failed to connect to Live API: %v", err)
 	}
-	c.conn = conn
 
 	// Send setup message
-	if err := c.sendSetupMessage(); err != nil {
-		c.conn.Close()
-		return fmt.Errorf("failed to send setup message: %v", err)
+	if err := c.sendSetupMessage(conn, handle); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send setup message: %v", err)
 	}
 
 	// Wait for setup complete message
-	if err := c.waitForSetupComplete(); err != nil {
-		c.conn.Close()
-		return fmt.Errorf("setup failed: %v", err)
+	if err := c.waitForSetupComplete(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("setup failed: %v", err)
+	}
+	return conn, nil
+}
+
+// reconnect replaces the failed connection with a new one that resumes the
+// session. It retries with backoff so a brief network outage doesn't end the
+// session. The dial and backoff run without connMutex, so senders are not
+// held up; when another caller already replaced the failed connection, or is
+// replacing it, reconnect waits for that instead of replacing it again.
+func (c *LiveClient) reconnect(failed *websocket.Conn, reason string) error {
+	c.connMutex.Lock()
+	for c.reconnecting != nil {
+		done := c.reconnecting
+		c.connMutex.Unlock()
+		<-done
+		c.connMutex.Lock()
+	}
+	if c.closed {
+		c.connMutex.Unlock()
+		return fmt.Errorf("client has been closed")
+	}
+	if c.conn != failed {
+		c.connMutex.Unlock()
+		return nil // Already replaced
 	}
+	done := make(chan struct{})
+	c.reconnecting = done
+	c.goAwayPending = false
+	handle := c.resumptionHandle
+	c.connMutex.Unlock()
 
-	c.initialized = true
-	log.Printf("Live API session established successfully for model: %s", c.model)
+	conn, err := c.redial(failed, handle, reason)
+
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	c.reconnecting = nil
+	close(done)
+	if err != nil {
+		return err
+	}
+	if c.closed {
+		conn.Close()
+		return fmt.Errorf("client has been closed")
+	}
+	c.conn = conn
 	return nil
 }
 
-// sendSetupMessage sends the initial setup message to the server
-func (c *LiveClient) sendSetupMessage() error {
+// redial closes the failed connection and dials a new one, with backoff.
+func (c *LiveClient) redial(failed *websocket.Conn, handle, reason string) (*websocket.Conn, error) {
+	if failed != nil {
+		failed.Close()
+	}
+	if handle == "" {
+		log.Printf("Reconnecting to Live API (%s) without a resumption handle; session context will be lost", reason)
+	} else {
+		log.Printf("Reconnecting to Live API (%s), resuming session", reason)
+	}
+
+	backoff := 500 * time.Millisecond
+	var err error
+	for attempt := 1; attempt <= maxLiveReconnectAttempts; attempt++ {
+		var conn *websocket.Conn
+		if conn, err = c.connect(handle); err == nil {
+			log.Printf("Live API session resumed after %d attempt(s)", attempt)
+			return conn, nil
+		}
+		log.Printf("Live API reconnect attempt %d failed: %v", attempt, err)
+		select {
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("failed to reconnect to Live API: %w", err)
+}
+
+// ResumptionHandle returns the latest handle that can resume this session.
+func (c *LiveClient) ResumptionHandle() string {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	return c.resumptionHandle
+}
+
+// sendSetupMessage sends the initial setup message to the server over conn
+func (c *LiveClient) sendSetupMessage(conn *websocket.Conn, handle string) error {
 	// Skip if in replay mode
 	if c.replayMode && c.recorder != nil {
 		// We'll get the setup complete from the recording
@@ -269,6 +529,13 @@ func (c *LiveClient) sendSetupMessage() error {
 		genConfig.MaxOutputTokens = &c.maxOutputTokens
 	}
 
+	if c.thinkingBudget != nil || c.includeThoughts {
+		genConfig.ThinkingConfig = &LiveThinkingConfig{
+			ThinkingBudget:  c.thinkingBudget,
+			IncludeThoughts: c.includeThoughts,
+		}
+	}
+
 	// Set response modalities
 	genConfig.ResponseModalities = []string{"TEXT"}
 
@@ -284,10 +551,47 @@ func (c *LiveClient) sendSetupMessage() error {
 		}
 	}
 
-	// Create setup config
+	// Create setup config, always asking for resumption handles
 	setupConfig := LiveSetupConfig{
-		Model:            c.model,
-		GenerationConfig: genConfig,
+		Model:             c.model,
+		GenerationConfig:  genConfig,
+		SessionResumption: &LiveSessionResumptionConfig{Handle: handle},
+	}
+
+	// Declare tools so the model can call them over the WebSocket
+	if len(c.tools) > 0 {
+		var decls []LiveFunctionDeclaration
+		for _, td := range c.tools {
+			decl := LiveFunctionDeclaration{Name: td.Name, Description: td.Description}
+			if td.Parameters != nil {
+				params, err := protojson.Marshal(td.Parameters)
+				if err != nil {
+					return fmt.Errorf("failed to marshal parameters for tool %s: %v", td.Name, err)
+				}
+				decl.Parameters = params
+			}
+			decls = append(decls, decl)
+		}
+		setupConfig.Tools = []LiveTool{{FunctionDeclarations: decls}}
+	}
+
+	if c.compression {
+		setupConfig.ContextWindowCompression = &LiveContextWindowCompression{
+			TriggerTokens: c.compressionTrigger,
+			SlidingWindow: &LiveSlidingWindow{TargetTokens: c.compressionTarget},
+		}
+	}
+
+	if c.transcribe {
+		setupConfig.InputAudioTranscription = &struct{}{}
+		setupConfig.OutputAudioTranscription = &struct{}{}
+	}
+
+	// Push-to-talk: turn off server voice activity detection
+	if c.manualActivity {
+		setupConfig.RealtimeInputConfig = &LiveRealtimeInputConfig{
+			AutomaticActivityDetection: &LiveAutomaticActivityDetection{Disabled: true},
+		}
 	}
 
 	// Add system instruction if provided
@@ -321,11 +625,11 @@ func (c *LiveClient) sendSetupMessage() error {
 		}
 	}
 
-	return c.conn.WriteMessage(websocket.TextMessage, setupJSON)
+	return conn.WriteMessage(websocket.TextMessage, setupJSON)
 }
 
-// waitForSetupComplete waits for the setup complete message from the server
-func (c *LiveClient) waitForSetupComplete() error {
+// waitForSetupComplete waits for the setup complete message from the server on conn
+func (c *LiveClient) waitForSetupComplete(conn *websocket.Conn) error {
 	// Skip in replay mode - we'll get responses from the recording
 	if c.replayMode && c.recorder != nil {
 		return nil
@@ -354,7 +658,7 @@ func (c *LiveClient) waitForSetupComplete() error {
 				// Continue reading
 			}
 
-			messageType, message, err := c.conn.ReadMessage()
+			messageType, message, err := conn.ReadMessage()
 			if err != nil {
 				errorCh <- fmt.Errorf("failed to read from WebSocket: %v", err)
 				return
@@ -401,6 +705,11 @@ func (c *LiveClient) SendMessage(message string) error {
 		return err
 	}
 
+	// Starting a new turn is a safe point to leave a connection the server is closing
+	if err := c.switchIfGoingAway(); err != nil {
+		return err
+	}
+
 	c.connMutex.Lock()
 	defer c.connMutex.Unlock()
 
@@ -441,15 +750,149 @@ func (c *LiveClient) SendMessage(message string) error {
 		return nil
 	}
 
-	return c.conn.WriteMessage(websocket.TextMessage, msgJSON)
+	if err := c.conn.WriteMessage(websocket.TextMessage, msgJSON); err != nil {
+		return err
+	}
+	c.turnActive = true
+	return nil
+}
+
+// switchIfGoingAway reconnects when the server has announced it will close
+// the connection and no turn is in flight.
+func (c *LiveClient) switchIfGoingAway() error {
+	c.connMutex.Lock()
+	switchNow := c.goAwayPending && !c.turnActive && !c.replayMode
+	conn := c.conn
+	c.connMutex.Unlock()
+	if switchNow {
+		return c.reconnect(conn, "server sent GoAway")
+	}
+	return nil
+}
+
+// SendRealtimeAudio streams a chunk of audio, e.g. "audio/pcm;rate=16000".
+func (c *LiveClient) SendRealtimeAudio(data []byte, mimeType string) error {
+	return c.writeClientMessage(LiveRealtimeInputRequest{RealtimeInput: &LiveRealtimeInput{Audio: &LiveBlob{MimeType: mimeType, Data: data}}}, false)
+}
+
+// SendRealtimeVideo streams a single video frame, e.g. "image/jpeg".
+func (c *LiveClient) SendRealtimeVideo(data []byte, mimeType string) error {
+	return c.writeClientMessage(LiveRealtimeInputRequest{RealtimeInput: &LiveRealtimeInput{Video: &LiveBlob{MimeType: mimeType, Data: data}}}, false)
+}
+
+// SendRealtimeText sends text as realtime input. Unlike SendMessage it is
+// interleaved with audio and video rather than forming a separate turn.
+func (c *LiveClient) SendRealtimeText(text string) error {
+	return c.writeClientMessage(LiveRealtimeInputRequest{RealtimeInput: &LiveRealtimeInput{Text: text}}, !c.manualActivity)
+}
+
+// ActivityStart marks the beginning of user speech. It is only valid when
+// automatic activity detection is disabled (push-to-talk).
+func (c *LiveClient) ActivityStart() error {
+	if !c.manualActivity {
+		return fmt.Errorf("activityStart requires manual activity detection")
+	}
+	// A new utterance is a safe point to leave a connection the server is closing
+	if err := c.switchIfGoingAway(); err != nil {
+		return err
+	}
+	return c.writeClientMessage(LiveRealtimeInputRequest{RealtimeInput: &LiveRealtimeInput{ActivityStart: &struct{}{}}}, false)
+}
+
+// ActivityEnd marks the end of user speech, prompting the model to respond.
+func (c *LiveClient) ActivityEnd() error {
+	if !c.manualActivity {
+		return fmt.Errorf("activityEnd requires manual activity detection")
+	}
+	return c.writeClientMessage(LiveRealtimeInputRequest{RealtimeInput: &LiveRealtimeInput{ActivityEnd: &struct{}{}}}, true)
+}
+
+// ManualActivityDetection reports whether the session uses push-to-talk.
+func (c *LiveClient) ManualActivityDetection() bool {
+	return c.manualActivity
 }
 
-// ReceiveMessage receives a message from the server
+// SendToolResponse returns the results of tool calls to the model. Each
+// response must carry the ID and name of the call it answers.
+func (c *LiveClient) SendToolResponse(responses ...*ToolResponse) error {
+	toolResponse := &LiveToolResponse{}
+	for _, r := range responses {
+		result := json.RawMessage("{}")
+		if r.Response != nil {
+			data, err := protojson.Marshal(r.Response)
+			if err != nil {
+				return fmt.Errorf("failed to marshal response for tool %s: %v", r.Name, err)
+			}
+			result = data
+		}
+		toolResponse.FunctionResponses = append(toolResponse.FunctionResponses, LiveFunctionResponse{
+			ID:       r.Id,
+			Name:     r.Name,
+			Response: result,
+		})
+	}
+	return c.writeClientMessage(LiveToolResponseRequest{ToolResponse: toolResponse}, false)
+}
+
+// writeClientMessage marshals and writes a client message. startsTurn marks
+// input the model will answer, so reconnects wait for that turn to finish.
+func (c *LiveClient) writeClientMessage(msg any, startsTurn bool) error {
+	if err := c.ensureInitialized(); err != nil {
+		return err
+	}
+
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+
+	// Record the message if recording is enabled
+	if c.recorder != nil && c.recorder.RecordMode {
+		if err := c.recorder.RecordSend(msgJSON, websocket.TextMessage); err != nil {
+			log.Printf("Warning: Failed to record message: %v", err)
+		}
+	}
+
+	// In replay mode, don't actually send
+	if c.replayMode && c.recorder != nil {
+		return nil
+	}
+
+	if err := c.conn.WriteMessage(websocket.TextMessage, msgJSON); err != nil {
+		return err
+	}
+	if startsTurn {
+		c.turnActive = true
+	}
+	return nil
+}
+
+// ReceiveMessage receives a message from the server. GoAway notices and
+// dropped connections are handled by reconnecting with the latest session
+// resumption handle, so callers only see errors once reconnecting fails.
 func (c *LiveClient) ReceiveMessage() (*StreamOutput, error) {
 	if err := c.ensureInitialized(); err != nil {
 		return nil, err
 	}
 
+	// Return outputs split from an earlier server message first
+	c.connMutex.Lock()
+	if len(c.pending) > 0 {
+		output := c.pending[0]
+		c.pending = c.pending[1:]
+		c.connMutex.Unlock()
+		return output, nil
+	}
+	c.connMutex.Unlock()
+
+	// Move to a fresh connection before the server closes this one
+	if err := c.switchIfGoingAway(); err != nil {
+		return nil, err
+	}
+
 	var message []byte
 	var messageType int
 	var err error
@@ -470,12 +913,30 @@ func (c *LiveClient) ReceiveMessage() (*StreamOutput, error) {
 		messageType = msgType
 	} else {
 		// Normal live API - read message from WebSocket
-		messageType, message, err = c.conn.ReadMessage()
+		c.connMutex.Lock()
+		conn := c.conn
+		c.connMutex.Unlock()
+		messageType, message, err = conn.ReadMessage()
 		if err != nil {
-			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			c.connMutex.Lock()
+			closed := c.closed
+			wasActive := c.turnActive
+			c.turnActive = false
+			c.connMutex.Unlock()
+			if closed || c.ctx.Err() != nil {
 				return nil, fmt.Errorf("connection closed")
 			}
-			return nil, fmt.Errorf("failed to read from WebSocket: %v", err)
+			if rerr := c.reconnect(conn, fmt.Sprintf("connection lost: %v", err)); rerr != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					return nil, fmt.Errorf("connection closed")
+				}
+				return nil, fmt.Errorf("failed to read from WebSocket: %v (%v)", err, rerr)
+			}
+			if wasActive {
+				// The interrupted turn can't be continued; end it so callers stop waiting
+				return &StreamOutput{TurnComplete: true}, nil
+			}
+			return c.ReceiveMessage()
 		}
 
 		// Record the message if recording is enabled
@@ -492,30 +953,118 @@ func (c *LiveClient) ReceiveMessage() (*StreamOutput, error) {
 		return nil, fmt.Errorf("failed to parse server message: %v", err)
 	}
 
+	// Track session resumption state
+	c.connMutex.Lock()
+	if u := response.SessionResumptionUpdate; u != nil && u.Resumable && u.NewHandle != "" {
+		c.resumptionHandle = u.NewHandle
+	}
+	if response.GoAway != nil {
+		log.Printf("Live API server sent GoAway (time left: %s)", response.GoAway.TimeLeft)
+		c.goAwayPending = true
+	}
+	if sc := response.ServerContent; sc != nil && (sc.TurnComplete || sc.Interrupted) {
+		c.turnActive = false
+	}
+	c.connMutex.Unlock()
+
 	// Convert to StreamOutput
 	output := &StreamOutput{}
 
+	// Drop the remainder of an interrupted turn, passing only its end through
+	c.connMutex.Lock()
+	discarding := c.discardTurn
+	if discarding && response.ServerContent != nil &&
+		(response.ServerContent.TurnComplete || response.ServerContent.Interrupted) {
+		c.discardTurn = false
+	}
+	c.connMutex.Unlock()
+	if discarding {
+		// The model waits on tool calls, so answer them to let the turn end
+		if tc := response.ToolCall; tc != nil {
+			var responses []*ToolResponse
+			for _, fc := range tc.FunctionCalls {
+				result, _ := structpb.NewStruct(map[string]any{"error": "cancelled by user"})
+				responses = append(responses, &ToolResponse{Id: fc.ID, Name: fc.Name, Response: result})
+			}
+			if err := c.SendToolResponse(responses...); err != nil {
+				log.Printf("Warning: Failed to decline tool calls of interrupted turn: %v", err)
+			}
+		}
+		output.TurnComplete = response.ServerContent != nil &&
+			(response.ServerContent.TurnComplete || response.ServerContent.Interrupted)
+		return output, nil
+	}
+
 	if response.ServerContent != nil {
 		if response.ServerContent.ModelTurn != nil && len(response.ServerContent.ModelTurn.Parts) > 0 {
 			// Get text from parts
 			for _, part := range response.ServerContent.ModelTurn.Parts {
-				output.Text += part.Text
+				if part.Thought {
+					output.Thought += part.Text
+				} else {
+					output.Text += part.Text
+				}
 			}
 		}
 
+		if t := response.ServerContent.InputTranscription; t != nil {
+			output.InputTranscription = t.Text
+		}
+		if t := response.ServerContent.OutputTranscription; t != nil {
+			output.OutputTranscription = t.Text
+		}
+
 		output.TurnComplete = response.ServerContent.TurnComplete
 	}
 
+	// Tool calls are returned one per output, in order
+	if tc := response.ToolCall; tc != nil {
+		for _, fc := range tc.FunctionCalls {
+			args, err := structpb.NewStruct(fc.Args)
+			if err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %s: %v", fc.Name, err)
+			}
+			call := &generativelanguagepb.FunctionCall{Id: fc.ID, Name: fc.Name, Args: args}
+			if output.FunctionCall == nil {
+				output.FunctionCall = call
+				continue
+			}
+			c.connMutex.Lock()
+			c.pending = append(c.pending, &StreamOutput{FunctionCall: call})
+			c.connMutex.Unlock()
+		}
+	}
+	if tcc := response.ToolCallCancellation; tcc != nil {
+		output.CancelledToolCallIDs = tcc.IDs
+	}
+
 	// Add usage information if available
 	if response.UsageMetadata != nil {
 		output.PromptTokenCount = response.UsageMetadata.PromptTokenCount
 		output.CandidateTokenCount = response.UsageMetadata.ResponseTokenCount
 		output.TotalTokenCount = response.UsageMetadata.TotalTokenCount
+		output.ThoughtsTokenCount = response.UsageMetadata.ThoughtsTokenCount
 	}
 
 	return output, nil
 }
 
+// Interrupt abandons the model turn in progress. Client content interrupts
+// the server's generation, so an empty, incomplete turn is sent, which the
+// model does not answer. Whatever of the turn still arrives is discarded
+// until the server reports it complete or interrupted.
+func (c *LiveClient) Interrupt() error {
+	c.connMutex.Lock()
+	if c.closed {
+		c.connMutex.Unlock()
+		return fmt.Errorf("client has been closed")
+	}
+	c.discardTurn = true
+	c.connMutex.Unlock()
+
+	return c.writeClientMessage(LiveClientMessageRequest{ClientContent: &LiveClientContent{Turns: []LiveContent{}}}, false)
+}
+
 // Close closes the WebSocket connection
 func (c *LiveClient) Close() error {
 	c.connMutex.Lock()
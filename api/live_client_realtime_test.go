@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestLiveClientPushToTalk tests realtime audio bracketed by activity markers
+func TestLiveClientPushToTalk(t *testing.T) {
+	audio := []byte{0x01, 0x02, 0x03, 0x04}
+	received := make(chan []LiveRealtimeInput, 1)
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(conn *websocket.Conn){
+		func(conn *websocket.Conn) {
+			var inputs []LiveRealtimeInput
+			for range 3 {
+				var req LiveRealtimeInputRequest
+				if err := conn.ReadJSON(&req); err != nil {
+					t.Errorf("failed to read realtime input: %v", err)
+					return
+				}
+				inputs = append(inputs, *req.RealtimeInput)
+			}
+			received <- inputs
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"heard you"}]},"turnComplete":true}}`)
+			conn.ReadMessage() // Wait for the client to close
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewLiveClient(context.Background(), "test-live-api-key", &StreamClientConfig{
+		ModelName:               "gemini-2.0-flash-live-001",
+		ManualActivityDetection: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewLiveClient failed: %v", err)
+	}
+	client.endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.ActivityStart(); err != nil {
+		t.Fatalf("ActivityStart failed: %v", err)
+	}
+	if err := client.SendRealtimeAudio(audio, "audio/pcm;rate=16000"); err != nil {
+		t.Fatalf("SendRealtimeAudio failed: %v", err)
+	}
+	if err := client.ActivityEnd(); err != nil {
+		t.Fatalf("ActivityEnd failed: %v", err)
+	}
+
+	inputs := <-received
+	if inputs[0].ActivityStart == nil {
+		t.Errorf("Expected activityStart first, got %+v", inputs[0])
+	}
+	if inputs[1].Audio == nil || inputs[1].Audio.MimeType != "audio/pcm;rate=16000" || !bytes.Equal(inputs[1].Audio.Data, audio) {
+		t.Errorf("Expected audio chunk second, got %+v", inputs[1])
+	}
+	if inputs[2].ActivityEnd == nil {
+		t.Errorf("Expected activityEnd last, got %+v", inputs[2])
+	}
+	if got := receiveTurn(t, client); got != "heard you" {
+		t.Errorf("Expected model reply, got %q", got)
+	}
+
+	setups := fake.setupConfigs()
+	if len(setups) != 1 || setups[0].RealtimeInputConfig == nil ||
+		setups[0].RealtimeInputConfig.AutomaticActivityDetection == nil ||
+		!setups[0].RealtimeInputConfig.AutomaticActivityDetection.Disabled {
+		t.Errorf("Expected setup to disable automatic activity detection, got %+v", setups)
+	}
+}
+
+// TestLiveClientActivityRequiresManualMode tests activity markers are rejected with server VAD
+func TestLiveClientActivityRequiresManualMode(t *testing.T) {
+	server := httptest.NewServer(&fakeLiveServer{t: t})
+	defer server.Close()
+	client := newTestLiveClient(t, server)
+
+	if err := client.ActivityStart(); err == nil {
+		t.Error("Expected ActivityStart to fail with automatic activity detection")
+	}
+	if err := client.ActivityEnd(); err == nil {
+		t.Error("Expected ActivityEnd to fail with automatic activity detection")
+	}
+}
+
+// TestLiveClientTranscriptions tests transcription setup and parsing
+func TestLiveClientTranscriptions(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(conn *websocket.Conn){
+		func(conn *websocket.Conn) {
+			conn.ReadMessage() // User turn
+			sendJSON(t, conn, `{"serverContent":{"inputTranscription":{"text":"hello there"}}}`)
+			sendJSON(t, conn, `{"serverContent":{"outputTranscription":{"text":"hi"}}}`)
+			sendJSON(t, conn, `{"serverContent":{"turnComplete":true}}`)
+			conn.ReadMessage() // Wait for the client to close
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewLiveClient(context.Background(), "test-live-api-key", &StreamClientConfig{
+		ModelName:          "gemini-2.0-flash-live-001",
+		AudioTranscription: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewLiveClient failed: %v", err)
+	}
+	client.endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.SendMessage("hello there"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	var input, output string
+	for {
+		out, err := client.ReceiveMessage()
+		if err != nil {
+			t.Fatalf("ReceiveMessage failed: %v", err)
+		}
+		input += out.InputTranscription
+		output += out.OutputTranscription
+		if out.TurnComplete {
+			break
+		}
+	}
+	if input != "hello there" || output != "hi" {
+		t.Errorf("Expected transcriptions %q/%q, got %q/%q", "hello there", "hi", input, output)
+	}
+
+	setups := fake.setupConfigs()
+	if len(setups) != 1 || setups[0].InputAudioTranscription == nil || setups[0].OutputAudioTranscription == nil {
+		t.Errorf("Expected setup to request transcriptions, got %+v", setups)
+	}
+}
+
+// TestLiveClientInterrupt tests an interrupt is sent to the server and the
+// rest of the interrupted turn is discarded
+func TestLiveClientInterrupt(t *testing.T) {
+	interrupt := make(chan LiveClientMessageRequest, 1)
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(conn *websocket.Conn){
+		func(conn *websocket.Conn) {
+			conn.ReadMessage() // User turn
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"Once upon"}]}}}`)
+			var req LiveClientMessageRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				t.Errorf("failed to read interrupt: %v", err)
+				return
+			}
+			interrupt <- req
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":" a time"}]}}}`)
+			sendJSON(t, conn, `{"serverContent":{"interrupted":true}}`)
+			conn.ReadMessage() // Wait for the client to close
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newTestLiveClient(t, server)
+
+	if err := client.SendMessage("Tell me a story"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	out, err := client.ReceiveMessage()
+	if err != nil || out.Text != "Once upon" {
+		t.Fatalf("Expected the start of the turn, got %+v, %v", out, err)
+	}
+	if err := client.Interrupt(); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+	req := <-interrupt
+	if req.ClientContent == nil || req.ClientContent.TurnComplete || len(req.ClientContent.Turns) != 0 {
+		t.Errorf("Expected empty incomplete client content, got %+v", req.ClientContent)
+	}
+	for {
+		out, err := client.ReceiveMessage()
+		if err != nil {
+			t.Fatalf("ReceiveMessage failed: %v", err)
+		}
+		if out.Text != "" {
+			t.Errorf("Expected the rest of the turn discarded, got %q", out.Text)
+		}
+		if out.TurnComplete {
+			break
+		}
+	}
+}
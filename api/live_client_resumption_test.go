@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeLiveServer is a minimal Live API endpoint. Each connection completes
+// setup and then runs the next scripted handler.
+type fakeLiveServer struct {
+	t        *testing.T
+	mu       sync.Mutex
+	handles  []string // Resumption handle sent in each setup message
+	setups   []LiveSetupConfig
+	handlers []func(conn *websocket.Conn)
+}
+
+func (s *fakeLiveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.t.Errorf("upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var setup LiveSetupRequest
+	if err := conn.ReadJSON(&setup); err != nil {
+		s.t.Errorf("failed to read setup: %v", err)
+		return
+	}
+	s.mu.Lock()
+	handle := "<none>"
+	if setup.Setup.SessionResumption != nil {
+		handle = setup.Setup.SessionResumption.Handle
+	}
+	s.handles = append(s.handles, handle)
+	s.setups = append(s.setups, setup.Setup)
+	n := len(s.handles)
+	s.mu.Unlock()
+
+	conn.WriteJSON(map[string]any{"setupComplete": map[string]any{}})
+	if n <= len(s.handlers) {
+		s.handlers[n-1](conn)
+	}
+}
+
+func (s *fakeLiveServer) setupHandles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.handles...)
+}
+
+func (s *fakeLiveServer) setupConfigs() []LiveSetupConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]LiveSetupConfig(nil), s.setups...)
+}
+
+func sendJSON(t *testing.T, conn *websocket.Conn, v string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(v)); err != nil {
+		t.Errorf("write failed: %v", err)
+	}
+}
+
+func newTestLiveClient(t *testing.T, server *httptest.Server) *LiveClient {
+	t.Helper()
+	client, err := NewLiveClient(context.Background(), "test-live-api-key", &StreamClientConfig{ModelName: "gemini-2.0-flash-live-001"}, nil)
+	if err != nil {
+		t.Fatalf("NewLiveClient failed: %v", err)
+	}
+	client.endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// receiveTurn reads until the turn completes and returns the text
+func receiveTurn(t *testing.T, c *LiveClient) string {
+	t.Helper()
+	var text strings.Builder
+	for {
+		out, err := c.ReceiveMessage()
+		if err != nil {
+			t.Fatalf("ReceiveMessage failed: %v", err)
+		}
+		text.WriteString(out.Text)
+		if out.TurnComplete {
+			return text.String()
+		}
+	}
+}
+
+// TestLiveClientResumesAfterGoAway tests that GoAway moves the session to a new connection
+func TestLiveClientResumesAfterGoAway(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(*websocket.Conn){
+		func(conn *websocket.Conn) {
+			conn.ReadMessage() // First user turn
+			sendJSON(t, conn, `{"sessionResumptionUpdate":{"newHandle":"handle-1","resumable":true}}`)
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"first"}]},"turnComplete":true}}`)
+			sendJSON(t, conn, `{"goAway":{"timeLeft":"5s"}}`)
+			conn.ReadMessage() // Held open until the client leaves
+		},
+		func(conn *websocket.Conn) {
+			conn.ReadMessage()
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"second"}]},"turnComplete":true}}`)
+			conn.ReadMessage()
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := newTestLiveClient(t, server)
+	if err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if got := receiveTurn(t, client); got != "first" {
+		t.Errorf("Expected first turn text, got %q", got)
+	}
+	if _, err := client.ReceiveMessage(); err != nil { // GoAway
+		t.Fatalf("ReceiveMessage failed on GoAway: %v", err)
+	}
+
+	if err := client.SendMessage("again"); err != nil {
+		t.Fatalf("SendMessage after GoAway failed: %v", err)
+	}
+	if got := receiveTurn(t, client); got != "second" {
+		t.Errorf("Expected second turn text, got %q", got)
+	}
+
+	handles := fake.setupHandles()
+	if len(handles) != 2 || handles[0] != "" || handles[1] != "handle-1" {
+		t.Errorf("Expected a fresh setup then a resumed one, got %q", handles)
+	}
+}
+
+// TestLiveClientResumesAfterDrop tests transparent reconnect when the connection drops
+func TestLiveClientResumesAfterDrop(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(*websocket.Conn){
+		func(conn *websocket.Conn) {
+			sendJSON(t, conn, `{"sessionResumptionUpdate":{"newHandle":"handle-7","resumable":true}}`)
+			// Returning drops the connection without a close frame
+		},
+		func(conn *websocket.Conn) {
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"still here"}]},"turnComplete":true}}`)
+			conn.ReadMessage()
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := newTestLiveClient(t, server)
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if got := receiveTurn(t, client); got != "still here" {
+		t.Errorf("Expected text from the resumed connection, got %q", got)
+	}
+	if handles := fake.setupHandles(); len(handles) != 2 || handles[1] != "handle-7" {
+		t.Errorf("Expected reconnect to resume with handle-7, got %q", handles)
+	}
+	if h := client.ResumptionHandle(); h != "handle-7" {
+		t.Errorf("Expected handle-7, got %q", h)
+	}
+}
+
+// TestLiveClientReconnectsOnce tests receivers that see the same connection
+// drop replace it once, without holding up other callers during the dial
+func TestLiveClientReconnectsOnce(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	release := make(chan struct{})
+	var dials atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dials.Add(1) > 1 {
+			<-release
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client := newTestLiveClient(t, server)
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	client.connMutex.Lock()
+	failed := client.conn
+	client.connMutex.Unlock()
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- client.reconnect(failed, "test") }()
+	}
+	for dials.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	locked := make(chan struct{})
+	go func() {
+		client.ResumptionHandle()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("Expected connMutex free while reconnecting")
+	}
+	close(release)
+
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("reconnect failed: %v", err)
+		}
+	}
+	if n := len(fake.setupHandles()); n != 2 {
+		t.Errorf("Expected one reconnect, got %d setups", n)
+	}
+}
+
+// TestLiveSetupRequestsResumption tests the setup message asks for resumption handles
+func TestLiveSetupRequestsResumption(t *testing.T) {
+	data, err := json.Marshal(LiveSetupRequest{Setup: LiveSetupConfig{Model: "models/x", SessionResumption: &LiveSessionResumptionConfig{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"sessionResumption":{}`) {
+		t.Errorf("Expected sessionResumption in setup, got %s", data)
+	}
+}
+
+// TestLiveSetupContextCompression tests the sliding window compression setup
+func TestLiveSetupContextCompression(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewLiveClient(context.Background(), "test-live-api-key", &StreamClientConfig{
+		ModelName:                "gemini-2.0-flash-live-001",
+		ContextWindowCompression: true,
+		CompressionTriggerTokens: 25600,
+		CompressionTargetTokens:  12800,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewLiveClient failed: %v", err)
+	}
+	client.endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { client.Close() })
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	setups := fake.setupConfigs()
+	if len(setups) != 1 || setups[0].ContextWindowCompression == nil {
+		t.Fatalf("Expected context window compression in setup, got %+v", setups)
+	}
+	cwc := setups[0].ContextWindowCompression
+	if cwc.TriggerTokens != 25600 || cwc.SlidingWindow == nil || cwc.SlidingWindow.TargetTokens != 12800 {
+		t.Errorf("Unexpected compression config: %+v", cwc)
+	}
+}
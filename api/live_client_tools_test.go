@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TestLiveClientToolCalls tests tool calls, responses and cancellations over the WebSocket
+func TestLiveClientToolCalls(t *testing.T) {
+	toolResponses := make(chan LiveToolResponseRequest, 1)
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(conn *websocket.Conn){
+		func(conn *websocket.Conn) {
+			conn.ReadMessage() // User turn
+			sendJSON(t, conn, `{"toolCall":{"functionCalls":[
+				{"id":"call-1","name":"get_weather","args":{"city":"Paris"}},
+				{"id":"call-2","name":"get_time","args":{}}]}}`)
+			var req LiveToolResponseRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				t.Errorf("failed to read tool response: %v", err)
+				return
+			}
+			toolResponses <- req
+			sendJSON(t, conn, `{"toolCallCancellation":{"ids":["call-2"]}}`)
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"Sunny"}]},"turnComplete":true}}`)
+			conn.ReadMessage() // Wait for the client to close
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewLiveClient(context.Background(), "test-live-api-key", &StreamClientConfig{
+		ModelName: "gemini-2.0-flash-live-001",
+		ToolDefinitions: []*ToolDefinition{{
+			Name:        "get_weather",
+			Description: "Current weather for a city",
+			Parameters: &generativelanguagepb.Schema{
+				Type:       generativelanguagepb.Type_OBJECT,
+				Properties: map[string]*generativelanguagepb.Schema{"city": {Type: generativelanguagepb.Type_STRING}},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewLiveClient failed: %v", err)
+	}
+	client.endpoint = "ws" + strings.TrimPrefix(server.URL, "http")
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.SendMessage("weather in Paris?"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	// Parallel calls arrive as separate outputs, in order
+	var calls []*generativelanguagepb.FunctionCall
+	for range 2 {
+		out, err := client.ReceiveMessage()
+		if err != nil {
+			t.Fatalf("ReceiveMessage failed: %v", err)
+		}
+		if out.FunctionCall == nil {
+			t.Fatalf("Expected a function call, got %+v", out)
+		}
+		calls = append(calls, out.FunctionCall)
+	}
+	if calls[0].Id != "call-1" || calls[0].Name != "get_weather" || calls[0].Args.Fields["city"].GetStringValue() != "Paris" {
+		t.Errorf("Unexpected first call: %v", calls[0])
+	}
+	if calls[1].Id != "call-2" || calls[1].Name != "get_time" {
+		t.Errorf("Unexpected second call: %v", calls[1])
+	}
+
+	result, _ := structpb.NewStruct(map[string]any{"result": "sunny"})
+	if err := client.SendToolResponse(&ToolResponse{Id: "call-1", Name: "get_weather", Response: result}); err != nil {
+		t.Fatalf("SendToolResponse failed: %v", err)
+	}
+	req := <-toolResponses
+	if len(req.ToolResponse.FunctionResponses) != 1 {
+		t.Fatalf("Expected 1 function response, got %+v", req.ToolResponse)
+	}
+	fr := req.ToolResponse.FunctionResponses[0]
+	var got map[string]any
+	if err := json.Unmarshal(fr.Response, &got); err != nil {
+		t.Fatalf("Invalid response JSON %s: %v", fr.Response, err)
+	}
+	if fr.ID != "call-1" || fr.Name != "get_weather" || got["result"] != "sunny" {
+		t.Errorf("Unexpected function response: %+v (%s)", fr, fr.Response)
+	}
+
+	out, err := client.ReceiveMessage()
+	if err != nil {
+		t.Fatalf("ReceiveMessage failed: %v", err)
+	}
+	if len(out.CancelledToolCallIDs) != 1 || out.CancelledToolCallIDs[0] != "call-2" {
+		t.Errorf("Expected call-2 to be cancelled, got %v", out.CancelledToolCallIDs)
+	}
+	if got := receiveTurn(t, client); got != "Sunny" {
+		t.Errorf("Expected model reply, got %q", got)
+	}
+
+	setups := fake.setupConfigs()
+	if len(setups) != 1 || len(setups[0].Tools) != 1 || len(setups[0].Tools[0].FunctionDeclarations) != 1 {
+		t.Fatalf("Expected one declared tool, got %+v", setups)
+	}
+	decl := setups[0].Tools[0].FunctionDeclarations[0]
+	if decl.Name != "get_weather" || !strings.Contains(string(decl.Parameters), `"OBJECT"`) {
+		t.Errorf("Unexpected declaration: %+v (%s)", decl, decl.Parameters)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sync/atomic"
 
 	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
 	"google.golang.org/grpc/metadata"
@@ -28,7 +29,8 @@ type LiveStreamAdapter struct {
 	errorCh      chan error
 	responses    []*generativelanguagepb.GenerateContentResponse
 	currentIndex int
-	closed       bool
+	closed       atomic.Bool   // Set by CloseSend, which may run during a Recv
+	lastOutput   *StreamOutput // Raw output behind the most recent Recv
 }
 
 // NewLiveStreamAdapter creates a new adapter for the LiveClient.
@@ -53,7 +55,7 @@ func NewLiveStreamAdapter(ctx context.Context, client LiveClientInterface) *Live
 // Recv implements the StreamGenerateContentClient interface.
 func (a *LiveStreamAdapter) Recv() (*generativelanguagepb.GenerateContentResponse, error) {
 	// Check if we've already closed
-	if a.closed {
+	if a.closed.Load() {
 		return nil, io.EOF
 	}
 
@@ -79,6 +81,8 @@ func (a *LiveStreamAdapter) Recv() (*generativelanguagepb.GenerateContentRespons
 		return nil, err
 	}
 
+	a.lastOutput = output
+
 	// Convert the output to a GenerateContentResponse
 	resp := convertOutputToResponse(output)
 	a.responses = append(a.responses, resp)
@@ -88,6 +92,12 @@ func (a *LiveStreamAdapter) Recv() (*generativelanguagepb.GenerateContentRespons
 	return resp, nil
 }
 
+// LastOutput returns the live output behind the most recent Recv. It carries
+// fields with no GenerateContentResponse equivalent, such as transcriptions.
+func (a *LiveStreamAdapter) LastOutput() *StreamOutput {
+	return a.lastOutput
+}
+
 // Header returns the header metadata for this stream.
 func (a *LiveStreamAdapter) Header() (metadata.MD, error) {
 	return metadata.MD{}, nil
@@ -105,21 +115,46 @@ func (a *LiveStreamAdapter) Context() context.Context {
 
 // CloseSend closes the sending side of the stream.
 func (a *LiveStreamAdapter) CloseSend() error {
-	if a.closed {
+	if a.closed.Swap(true) {
 		return nil
 	}
-	a.closed = true
 	return a.client.Close()
 }
 
 // SendMessage sends a message through the LiveClient.
 func (a *LiveStreamAdapter) SendMessage(message string) error {
-	if a.closed {
+	if a.closed.Load() {
 		return fmt.Errorf("stream is closed")
 	}
 	return a.client.SendMessage(message)
 }
 
+// Interrupt abandons the current model turn if the underlying client supports it.
+func (a *LiveStreamAdapter) Interrupt() error {
+	if a.closed.Load() {
+		return fmt.Errorf("stream is closed")
+	}
+	interrupter, ok := a.client.(interface{ Interrupt() error })
+	if !ok {
+		return fmt.Errorf("live client does not support interruption")
+	}
+	return interrupter.Interrupt()
+}
+
+// SendToolResponse returns tool results if the underlying client supports it.
+func (a *LiveStreamAdapter) SendToolResponse(responses ...*ToolResponse) error {
+	if a.closed.Load() {
+		return fmt.Errorf("stream is closed")
+	}
+	sender, ok := a.client.(interface {
+		SendToolResponse(...*ToolResponse) error
+	})
+	if !ok {
+		return fmt.Errorf("live client does not support tool responses")
+	}
+	return sender.SendToolResponse(responses...)
+}
+
 // RecvMsg receives a message and stores it into m.
 func (a *LiveStreamAdapter) RecvMsg(m interface{}) error {
 	resp, err := a.Recv()
@@ -175,6 +210,17 @@ func convertOutputToResponse(output *StreamOutput) *generativelanguagepb.Generat
 		Role:  "model",
 		Parts: []*generativelanguagepb.Part{part},
 	}
+	if output.Thought != "" {
+		content.Parts = append([]*generativelanguagepb.Part{{
+			Data:    &generativelanguagepb.Part_Text{Text: output.Thought},
+			Thought: true,
+		}}, content.Parts...)
+	}
+	if output.FunctionCall != nil {
+		content.Parts = append(content.Parts, &generativelanguagepb.Part{
+			Data: &generativelanguagepb.Part_FunctionCall{FunctionCall: output.FunctionCall},
+		})
+	}
 
 	// Determine finish reason
 	var finishReason generativelanguagepb.Candidate_FinishReason
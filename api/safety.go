@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	generativelanguagealphapb "cloud.google.com/go/ai/generativelanguage/apiv1alpha/generativelanguagepb"
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+)
+
+// harmCategoryAliases maps short category names to their enum values.
+var harmCategoryAliases = map[string]generativelanguagepb.HarmCategory{
+	"harassment":        generativelanguagepb.HarmCategory_HARM_CATEGORY_HARASSMENT,
+	"hate":              generativelanguagepb.HarmCategory_HARM_CATEGORY_HATE_SPEECH,
+	"hate_speech":       generativelanguagepb.HarmCategory_HARM_CATEGORY_HATE_SPEECH,
+	"sexual":            generativelanguagepb.HarmCategory_HARM_CATEGORY_SEXUALLY_EXPLICIT,
+	"sexually_explicit": generativelanguagepb.HarmCategory_HARM_CATEGORY_SEXUALLY_EXPLICIT,
+	"dangerous":         generativelanguagepb.HarmCategory_HARM_CATEGORY_DANGEROUS_CONTENT,
+	"dangerous_content": generativelanguagepb.HarmCategory_HARM_CATEGORY_DANGEROUS_CONTENT,
+	"civic":             generativelanguagepb.HarmCategory_HARM_CATEGORY_CIVIC_INTEGRITY,
+	"civic_integrity":   generativelanguagepb.HarmCategory_HARM_CATEGORY_CIVIC_INTEGRITY,
+}
+
+// blockThresholdAliases maps short threshold names to their enum values.
+var blockThresholdAliases = map[string]generativelanguagepb.SafetySetting_HarmBlockThreshold{
+	"none":   generativelanguagepb.SafetySetting_BLOCK_NONE,
+	"high":   generativelanguagepb.SafetySetting_BLOCK_ONLY_HIGH,
+	"medium": generativelanguagepb.SafetySetting_BLOCK_MEDIUM_AND_ABOVE,
+	"low":    generativelanguagepb.SafetySetting_BLOCK_LOW_AND_ABOVE,
+	"off":    generativelanguagepb.SafetySetting_OFF,
+}
+
+// ParseHarmCategory parses a harm category given either by its enum name
+// (HARM_CATEGORY_HARASSMENT) or a short alias (harassment, hate, sexual,
+// dangerous, civic).
+func ParseHarmCategory(s string) (generativelanguagepb.HarmCategory, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	if c, ok := harmCategoryAliases[strings.TrimPrefix(key, "harm_category_")]; ok {
+		return c, nil
+	}
+	if v, ok := generativelanguagepb.HarmCategory_value[strings.ToUpper(key)]; ok && v != 0 {
+		return generativelanguagepb.HarmCategory(v), nil
+	}
+	return 0, fmt.Errorf("unknown harm category %q", s)
+}
+
+// ParseHarmBlockThreshold parses a block threshold given either by its enum
+// name (BLOCK_ONLY_HIGH) or a short alias (none, high, medium, low, off).
+func ParseHarmBlockThreshold(s string) (generativelanguagepb.SafetySetting_HarmBlockThreshold, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	if t, ok := blockThresholdAliases[key]; ok {
+		return t, nil
+	}
+	if v, ok := generativelanguagepb.SafetySetting_HarmBlockThreshold_value[strings.ToUpper(key)]; ok && v != 0 {
+		return generativelanguagepb.SafetySetting_HarmBlockThreshold(v), nil
+	}
+	return 0, fmt.Errorf("unknown block threshold %q", s)
+}
+
+// ParseSafetySetting parses a "category=threshold" pair such as
+// "harassment=none" or "HARM_CATEGORY_DANGEROUS_CONTENT=BLOCK_ONLY_HIGH".
+func ParseSafetySetting(s string) (*generativelanguagepb.SafetySetting, error) {
+	category, threshold, ok := strings.Cut(s, "=")
+	if !ok {
+		return nil, fmt.Errorf("safety setting %q must be category=threshold", s)
+	}
+	return newSafetySetting(category, threshold)
+}
+
+// LoadSafetySettings reads safety settings from a JSON file mapping harm
+// categories to block thresholds, e.g. {"harassment": "none"}.
+func LoadSafetySettings(path string) ([]*generativelanguagepb.SafetySetting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read safety settings file: %w", err)
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse safety settings file %s: %w", path, err)
+	}
+	var settings []*generativelanguagepb.SafetySetting
+	for category, threshold := range entries {
+		setting, err := newSafetySetting(category, threshold)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Category < settings[j].Category })
+	return settings, nil
+}
+
+func newSafetySetting(category, threshold string) (*generativelanguagepb.SafetySetting, error) {
+	c, err := ParseHarmCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseHarmBlockThreshold(threshold)
+	if err != nil {
+		return nil, err
+	}
+	return &generativelanguagepb.SafetySetting{Category: c, Threshold: t}, nil
+}
+
+// toAlphaSafetySettings converts safety settings for the v1alpha API. The
+// enum values are shared between the API versions.
+func toAlphaSafetySettings(settings []*generativelanguagepb.SafetySetting) []*generativelanguagealphapb.SafetySetting {
+	var alpha []*generativelanguagealphapb.SafetySetting
+	for _, s := range settings {
+		alpha = append(alpha, &generativelanguagealphapb.SafetySetting{
+			Category:  generativelanguagealphapb.HarmCategory(s.Category),
+			Threshold: generativelanguagealphapb.SafetySetting_HarmBlockThreshold(s.Threshold),
+		})
+	}
+	return alpha
+}
+
+// BlockedReason explains why the prompt or candidate was blocked, or returns
+// "" if it was not.
+func (o StreamOutput) BlockedReason() string {
+	if o.BlockReason != generativelanguagepb.GenerateContentResponse_PromptFeedback_BLOCK_REASON_UNSPECIFIED {
+		return "prompt blocked: " + o.BlockReason.String()
+	}
+	switch o.FinishReason {
+	case generativelanguagepb.Candidate_SAFETY,
+		generativelanguagepb.Candidate_RECITATION,
+		generativelanguagepb.Candidate_BLOCKLIST,
+		generativelanguagepb.Candidate_PROHIBITED_CONTENT,
+		generativelanguagepb.Candidate_SPII,
+		generativelanguagepb.Candidate_IMAGE_SAFETY:
+		return "response blocked: " + o.FinishReason.String()
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+)
+
+func TestParseSafetySetting(t *testing.T) {
+	tests := []struct {
+		input     string
+		category  generativelanguagepb.HarmCategory
+		threshold generativelanguagepb.SafetySetting_HarmBlockThreshold
+		wantErr   bool
+	}{
+		{"harassment=none", generativelanguagepb.HarmCategory_HARM_CATEGORY_HARASSMENT, generativelanguagepb.SafetySetting_BLOCK_NONE, false},
+		{"dangerous=high", generativelanguagepb.HarmCategory_HARM_CATEGORY_DANGEROUS_CONTENT, generativelanguagepb.SafetySetting_BLOCK_ONLY_HIGH, false},
+		{"HARM_CATEGORY_HATE_SPEECH=BLOCK_LOW_AND_ABOVE", generativelanguagepb.HarmCategory_HARM_CATEGORY_HATE_SPEECH, generativelanguagepb.SafetySetting_BLOCK_LOW_AND_ABOVE, false},
+		{"harm_category_sexually_explicit=medium", generativelanguagepb.HarmCategory_HARM_CATEGORY_SEXUALLY_EXPLICIT, generativelanguagepb.SafetySetting_BLOCK_MEDIUM_AND_ABOVE, false},
+		{"harassment", 0, 0, true},
+		{"weather=none", 0, 0, true},
+		{"harassment=sometimes", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSafetySetting(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSafetySetting(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Category != tt.category || got.Threshold != tt.threshold {
+				t.Errorf("ParseSafetySetting(%q) = %v/%v, want %v/%v", tt.input, got.Category, got.Threshold, tt.category, tt.threshold)
+			}
+		})
+	}
+}
+
+func TestLoadSafetySettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "safety.json")
+	if err := os.WriteFile(path, []byte(`{"dangerous": "high", "harassment": "none"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := LoadSafetySettings(path)
+	if err != nil {
+		t.Fatalf("LoadSafetySettings failed: %v", err)
+	}
+	if len(settings) != 2 {
+		t.Fatalf("Expected 2 settings, got %d", len(settings))
+	}
+	if settings[0].Category != generativelanguagepb.HarmCategory_HARM_CATEGORY_HARASSMENT ||
+		settings[0].Threshold != generativelanguagepb.SafetySetting_BLOCK_NONE {
+		t.Errorf("Unexpected first setting: %v", settings[0])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"dangerous": "sometimes"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSafetySettings(path); err == nil {
+		t.Error("Expected an error for an unknown threshold")
+	}
+}
+
+func TestExtractOutputBlockedCandidate(t *testing.T) {
+	resp := &generativelanguagepb.GenerateContentResponse{
+		Candidates: []*generativelanguagepb.Candidate{{
+			FinishReason: generativelanguagepb.Candidate_SAFETY,
+			SafetyRatings: []*generativelanguagepb.SafetyRating{{
+				Category:    generativelanguagepb.HarmCategory_HARM_CATEGORY_DANGEROUS_CONTENT,
+				Probability: generativelanguagepb.SafetyRating_HIGH,
+				Blocked:     true,
+			}},
+		}},
+	}
+
+	output := ExtractOutput(resp)
+	if got := output.BlockedReason(); got != "response blocked: SAFETY" {
+		t.Errorf("BlockedReason() = %q", got)
+	}
+	if !output.TurnComplete {
+		t.Error("A blocked candidate should complete the turn")
+	}
+	if len(output.SafetyRatings) != 1 {
+		t.Errorf("Expected the candidate's safety ratings, got %d", len(output.SafetyRatings))
+	}
+
+	if got := ExtractOutput(&generativelanguagepb.GenerateContentResponse{
+		Candidates: []*generativelanguagepb.Candidate{{FinishReason: generativelanguagepb.Candidate_STOP}},
+	}).BlockedReason(); got != "" {
+		t.Errorf("BlockedReason() for a normal stop = %q, want empty", got)
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// LoadResponseSchema reads a response schema file, as passed with
+// --response-schema-file. The file may use the API's Schema JSON form
+// ("type": "OBJECT") or plain JSON Schema ("type": "object").
+func LoadResponseSchema(path string) (*generativelanguagepb.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response schema file: %w", err)
+	}
+	schema := &generativelanguagepb.Schema{}
+	protoErr := protojson.Unmarshal(data, schema)
+	if protoErr == nil {
+		return schema, nil
+	}
+	var js JSONSchema
+	if err := json.Unmarshal(data, &js); err != nil {
+		return nil, fmt.Errorf("failed to parse response schema: %w", protoErr)
+	}
+	if schema, err = ConvertJSONSchema(&js); err != nil {
+		return nil, fmt.Errorf("failed to parse response schema: %w", err)
+	}
+	return schema, nil
+}
+
+// ValidateJSON checks that data is a JSON value matching schema. It returns
+// one description per mismatch, prefixed with the location of the offending
+// value ($ is the document root), or nil if data is valid.
+func ValidateJSON(data []byte, schema *generativelanguagepb.Schema) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []string{fmt.Sprintf("$: invalid JSON: %v", err)}
+	}
+	if dec.More() {
+		return []string{"$: invalid JSON: unexpected data after the top-level value"}
+	}
+	var problems []string
+	validateValue("$", v, schema, &problems)
+	return problems
+}
+
+// ApplyDefaults fills in the properties data leaves out that have a default
+// in schema, at any depth, and returns the result. Data that is not a JSON
+// object is returned unchanged.
+func ApplyDefaults(data []byte, schema *generativelanguagepb.Schema) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if !applyDefaults(v, schema) {
+		return data, nil
+	}
+	return json.Marshal(v)
+}
+
+// applyDefaults fills in defaults in v and reports whether it changed v.
+func applyDefaults(v any, s *generativelanguagepb.Schema) bool {
+	if s == nil {
+		return false
+	}
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for name, prop := range s.Properties {
+			if value, ok := v[name]; ok {
+				changed = applyDefaults(value, prop) || changed
+			} else if prop.GetDefault() != nil {
+				v[name] = prop.Default.AsInterface()
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			changed = applyDefaults(item, s.Items) || changed
+		}
+	}
+	return changed
+}
+
+// validateValue appends a problem for each way v fails to match s.
+func validateValue(path string, v any, s *generativelanguagepb.Schema, problems *[]string) {
+	if s == nil {
+		return
+	}
+	addf := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.AnyOf) > 0 {
+		for _, alt := range s.AnyOf {
+			var altProblems []string
+			validateValue(path, v, alt, &altProblems)
+			if len(altProblems) == 0 {
+				return
+			}
+		}
+		addf("does not match any of the %d allowed schemas", len(s.AnyOf))
+		return
+	}
+
+	if v == nil {
+		if !s.Nullable && s.Type != generativelanguagepb.Type_TYPE_UNSPECIFIED {
+			addf("expected %s, got null", typeName(s.Type))
+		}
+		return
+	}
+
+	switch s.Type {
+	case generativelanguagepb.Type_STRING:
+		str, ok := v.(string)
+		if !ok {
+			addf("expected string, got %s", jsonTypeName(v))
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			addf("%q is not one of %q", str, s.Enum)
+		}
+
+	case generativelanguagepb.Type_INTEGER, generativelanguagepb.Type_NUMBER:
+		n, ok := v.(json.Number)
+		if !ok {
+			addf("expected %s, got %s", typeName(s.Type), jsonTypeName(v))
+			return
+		}
+		if s.Type == generativelanguagepb.Type_INTEGER {
+			if _, err := n.Int64(); err != nil {
+				addf("expected integer, got %s", n)
+				return
+			}
+		}
+		f, _ := n.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			addf("%s is less than the minimum %v", n, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			addf("%s is greater than the maximum %v", n, *s.Maximum)
+		}
+
+	case generativelanguagepb.Type_BOOLEAN:
+		if _, ok := v.(bool); !ok {
+			addf("expected boolean, got %s", jsonTypeName(v))
+		}
+
+	case generativelanguagepb.Type_ARRAY:
+		items, ok := v.([]any)
+		if !ok {
+			addf("expected array, got %s", jsonTypeName(v))
+			return
+		}
+		if s.MinItems > 0 && int64(len(items)) < s.MinItems {
+			addf("has %d items, expected at least %d", len(items), s.MinItems)
+		}
+		if s.MaxItems > 0 && int64(len(items)) > s.MaxItems {
+			addf("has %d items, expected at most %d", len(items), s.MaxItems)
+		}
+		for i, item := range items {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), item, s.Items, problems)
+		}
+
+	case generativelanguagepb.Type_OBJECT:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			addf("expected object, got %s", jsonTypeName(v))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				addf("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := obj[name]; ok {
+				validateValue(path+"."+name, value, s.Properties[name], problems)
+			}
+		}
+	}
+}
+
+// typeName returns the JSON Schema spelling of a schema type.
+func typeName(t generativelanguagepb.Type) string {
+	switch t {
+	case generativelanguagepb.Type_STRING:
+		return "string"
+	case generativelanguagepb.Type_INTEGER:
+		return "integer"
+	case generativelanguagepb.Type_NUMBER:
+		return "number"
+	case generativelanguagepb.Type_BOOLEAN:
+		return "boolean"
+	case generativelanguagepb.Type_ARRAY:
+		return "array"
+	case generativelanguagepb.Type_OBJECT:
+		return "object"
+	}
+	return t.String()
+}
+
+// jsonTypeName describes the type of a decoded JSON value.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+)
+
+func TestValidateJSON(t *testing.T) {
+	minScore := 0.0
+	schema := &generativelanguagepb.Schema{
+		Type:     generativelanguagepb.Type_OBJECT,
+		Required: []string{"name", "tags"},
+		Properties: map[string]*generativelanguagepb.Schema{
+			"name":  {Type: generativelanguagepb.Type_STRING},
+			"kind":  {Type: generativelanguagepb.Type_STRING, Enum: []string{"fruit", "vegetable"}},
+			"count": {Type: generativelanguagepb.Type_INTEGER},
+			"score": {Type: generativelanguagepb.Type_NUMBER, Minimum: &minScore},
+			"note":  {Type: generativelanguagepb.Type_STRING, Nullable: true},
+			"tags": {
+				Type:     generativelanguagepb.Type_ARRAY,
+				MinItems: 1,
+				Items:    &generativelanguagepb.Schema{Type: generativelanguagepb.Type_STRING},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"valid", `{"name":"apple","kind":"fruit","count":3,"score":0.5,"note":null,"tags":["red"]}`, nil},
+		{"missing required", `{"tags":["red"]}`, []string{`$: missing required property "name"`}},
+		{"wrong types", `{"name":7,"count":1.5,"tags":[true]}`, []string{
+			"$.count: expected integer, got 1.5",
+			"$.name: expected string, got number",
+			"$.tags[0]: expected string, got boolean",
+		}},
+		{"enum and bounds", `{"name":"x","kind":"mineral","score":-1,"tags":[]}`, []string{
+			`$.kind: "mineral" is not one of ["fruit" "vegetable"]`,
+			"$.score: -1 is less than the minimum 0",
+			"$.tags: has 0 items, expected at least 1",
+		}},
+		{"null not allowed", `{"name":null,"tags":["a"]}`, []string{"$.name: expected string, got null"}},
+		{"not json", `{"name":`, []string{"$: invalid JSON: unexpected EOF"}},
+		{"trailing data", `{"name":"a","tags":["b"]} {}`, []string{"$: invalid JSON: unexpected data after the top-level value"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateJSON([]byte(tt.data), schema)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	var js JSONSchema
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"path": {"type": "string"},
+			"limit": {"type": "integer", "minimum": 1, "default": 100},
+			"sort": {"type": "string", "enum": ["name", "size"], "default": "name"},
+			"filters": {
+				"type": "array",
+				"items": {"type": "object", "properties": {"hidden": {"type": "boolean", "default": false}}}
+			}
+		}
+	}`), &js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := ConvertJSONSchema(&js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Properties["limit"].GetMinimum() != 1 {
+		t.Errorf("Expected the minimum to be converted, got %v", schema.Properties["limit"])
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"fills missing", `{"path":"."}`, `{"limit":100,"path":".","sort":"name"}`},
+		{"keeps given", `{"limit":5,"sort":"size"}`, `{"limit":5,"sort":"size"}`},
+		{"nested", `{"limit":1,"sort":"name","filters":[{}]}`, `{"filters":[{"hidden":false}],"limit":1,"sort":"name"}`},
+		{"not an object", `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyDefaults([]byte(tt.data), schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ApplyDefaults() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadResponseSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type":"OBJECT","properties":{"answer":{"type":"STRING"}},"required":["answer"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadResponseSchema(path)
+	if err != nil {
+		t.Fatalf("LoadResponseSchema failed: %v", err)
+	}
+	if schema.Type != generativelanguagepb.Type_OBJECT || schema.Properties["answer"].GetType() != generativelanguagepb.Type_STRING {
+		t.Errorf("Unexpected schema: %v", schema)
+	}
+
+	// Plain JSON Schema spelling, as in testdata/structured-output-sample1.json
+	schema, err = LoadResponseSchema("../testdata/structured-output-sample1.json")
+	if err != nil {
+		t.Fatalf("LoadResponseSchema failed on JSON Schema: %v", err)
+	}
+	if schema.Type != generativelanguagepb.Type_OBJECT || schema.Properties["thinking"].GetItems().GetType() != generativelanguagepb.Type_STRING {
+		t.Errorf("Unexpected schema: %v", schema)
+	}
+
+	if _, err := LoadResponseSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing schema file")
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"github.com/gorilla/websocket"
+)
+
+func TestThinkingConfig(t *testing.T) {
+	if tc := ThinkingConfig(&StreamClientConfig{}); tc != nil {
+		t.Errorf("Expected no thinking config by default, got %v", tc)
+	}
+
+	budget := int32(0)
+	tc := ThinkingConfig(&StreamClientConfig{ThinkingBudget: &budget})
+	if tc == nil || tc.ThinkingBudget == nil || *tc.ThinkingBudget != 0 || tc.IncludeThoughts != nil {
+		t.Errorf("Expected a zero budget that disables thinking, got %v", tc)
+	}
+
+	tc = ThinkingConfig(&StreamClientConfig{IncludeThoughts: true})
+	if tc == nil || tc.ThinkingBudget != nil || !tc.GetIncludeThoughts() {
+		t.Errorf("Expected thought summaries at the default budget, got %v", tc)
+	}
+}
+
+func TestExtractOutputThoughts(t *testing.T) {
+	resp := &generativelanguagepb.GenerateContentResponse{
+		Candidates: []*generativelanguagepb.Candidate{{
+			Content: &generativelanguagepb.Content{Parts: []*generativelanguagepb.Part{
+				{Data: &generativelanguagepb.Part_Text{Text: "Weighing the options."}, Thought: true},
+				{Data: &generativelanguagepb.Part_Text{Text: "Use a map."}},
+			}},
+			FinishReason: generativelanguagepb.Candidate_STOP,
+		}},
+		UsageMetadata: &generativelanguagepb.GenerateContentResponse_UsageMetadata{
+			PromptTokenCount:     10,
+			CandidatesTokenCount: 4,
+			ThoughtsTokenCount:   120,
+			TotalTokenCount:      134,
+		},
+	}
+
+	output := ExtractOutput(resp)
+	if output.Text != "Use a map." {
+		t.Errorf("Expected only the answer in Text, got %q", output.Text)
+	}
+	if output.Thought != "Weighing the options." {
+		t.Errorf("Expected the thought summary, got %q", output.Thought)
+	}
+	if output.ThoughtsTokenCount != 120 || output.TotalTokenCount != 134 {
+		t.Errorf("Unexpected usage: thoughts=%d total=%d", output.ThoughtsTokenCount, output.TotalTokenCount)
+	}
+}
+
+// TestLiveClientThoughts tests thinking setup and thought parts over the Live API
+func TestLiveClientThoughts(t *testing.T) {
+	fake := &fakeLiveServer{t: t}
+	fake.handlers = []func(*websocket.Conn){
+		func(conn *websocket.Conn) {
+			conn.ReadMessage()
+			sendJSON(t, conn, `{"serverContent":{"modelTurn":{"parts":[{"text":"Hmm.","thought":true},{"text":"Hi"}]},"turnComplete":true},"usageMetadata":{"totalTokenCount":30,"thoughtsTokenCount":12}}`)
+			conn.ReadMessage()
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := newTestLiveClient(t, server)
+	client.includeThoughts = true
+	if err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	out, err := client.ReceiveMessage()
+	if err != nil {
+		t.Fatalf("ReceiveMessage failed: %v", err)
+	}
+	if out.Text != "Hi" || out.Thought != "Hmm." || out.ThoughtsTokenCount != 12 {
+		t.Errorf("Unexpected output: text=%q thought=%q thoughts=%d", out.Text, out.Thought, out.ThoughtsTokenCount)
+	}
+
+	setups := fake.setupConfigs()
+	if len(setups) != 1 || setups[0].GenerationConfig.ThinkingConfig == nil || !setups[0].GenerationConfig.ThinkingConfig.IncludeThoughts {
+		t.Errorf("Expected thought summaries requested in setup, got %+v", setups)
+	}
+}
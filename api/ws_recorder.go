@@ -81,7 +81,7 @@ func (r *WSRecorder) RecordSend(msg []byte, messageType int) error {
 
 	r.Recording = append(r.Recording, WSMessage{
 		Direction:   "send",
-		Payload:     json.RawMessage(msg),
+		Payload:     json.RawMessage(RedactBytes(msg)), // Never persist credentials
 		Timestamp:   now / 1000, // Convert to seconds
 		ElapsedMs:   elapsedMs,
 		MessageType: messageType,
@@ -109,7 +109,7 @@ func (r *WSRecorder) RecordReceive(msg []byte, messageType int) error {
 
 	r.Recording = append(r.Recording, WSMessage{
 		Direction:   "receive",
-		Payload:     json.RawMessage(msg),
+		Payload:     json.RawMessage(RedactBytes(msg)), // Never persist credentials
 		Timestamp:   now / 1000, // Convert to seconds
 		ElapsedMs:   elapsedMs,
 		MessageType: messageType,
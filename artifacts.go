@@ -0,0 +1,336 @@
+package aistudio
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Defaults for storing large tool results as artifacts.
+const (
+	DefaultToolResultLimit = 32 << 10 // Largest result sent to the model whole, in bytes
+	artifactPreviewBytes   = 4 << 10  // Longest preview sent in place of a stored result
+	maxArtifactReadLines   = 500      // Most lines read_artifact returns at once
+	artifactLineOverhead   = 16       // Bytes of line number and ellipsis around a line read
+	artifactMaxAge         = 7 * 24 * time.Hour
+)
+
+// artifactIDPattern matches artifact IDs, which name files in the store.
+var artifactIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ArtifactStore keeps tool results too large to send to the model whole, as
+// text files in Dir.
+type ArtifactStore struct {
+	Dir string
+}
+
+// NewArtifactStore returns a store keeping artifacts in dir.
+func NewArtifactStore(dir string) *ArtifactStore {
+	return &ArtifactStore{Dir: dir}
+}
+
+// DefaultArtifactDir returns the directory artifacts are stored in by
+// default, or "" if there is no user cache directory.
+func DefaultArtifactDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "aistudio", "artifacts")
+}
+
+// Save stores the result of the named tool and returns its artifact ID.
+func (s *ArtifactStore) Save(tool, content string) (string, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	prefix := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, tool)
+	id := prefix + "-" + hex.EncodeToString(b[:])
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, id+".txt"), []byte(content), 0o644); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Path returns the file holding the artifact id.
+func (s *ArtifactStore) Path(id string) (string, error) {
+	if !artifactIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid artifact ID %q", id)
+	}
+	path := filepath.Join(s.Dir, id+".txt")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("artifact %s not found", id)
+	}
+	return path, nil
+}
+
+// Prune removes artifacts older than maxAge.
+func (s *ArtifactStore) Prune(maxAge time.Duration) error {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			os.Remove(filepath.Join(s.Dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// StoreLargeResults stores tool results larger than limit bytes in store,
+// sending the model a preview and the artifact ID instead, and registers the
+// read_artifact tool for reading them. The limit must leave room for a
+// preview.
+func (tm *ToolManager) StoreLargeResults(store *ArtifactStore, limit int) error {
+	if limit < artifactPreviewBytes {
+		return fmt.Errorf("tool result limit must be at least %d bytes, got %d", artifactPreviewBytes, limit)
+	}
+	tm.artifacts, tm.resultLimit = store, limit
+	parameters := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"artifact_id": {"type": "string", "description": "The artifact_id of a truncated tool result"},
+			"start_line": {"type": "integer", "description": "The first line to read, counting from 1 (default 1)"},
+			"end_line": {"type": "integer", "description": "The last line to read (default: as many as fit in one result)"}
+		},
+		"required": ["artifact_id"]
+	}`)
+	return tm.RegisterStreamingTool("read_artifact", "Read part of a tool result that was too large to return whole, by line number. Lines are numbered in the output.", parameters, tm.readArtifactTool)
+}
+
+// storeLargeResult replaces the result in resp, from a call to the named
+// tool, with a preview when it is larger than the limit, storing the whole
+// result as an artifact.
+func (tm *ToolManager) storeLargeResult(name string, resp map[string]any) {
+	if tm.artifacts == nil || tm.resultLimit <= 0 || name == "read_artifact" || resp["result"] == nil {
+		return
+	}
+	text, ok := resp["result"].(string)
+	if !ok {
+		data, err := json.MarshalIndent(resp["result"], "", "  ")
+		if err != nil {
+			return
+		}
+		text = string(data)
+	}
+	if len(text) <= tm.resultLimit {
+		return
+	}
+	fields, field := artifactField(resp["result"])
+	if field != "" {
+		text = fields[field].(string)
+	}
+
+	preview := text[:min(artifactPreviewBytes, tm.resultLimit)]
+	if i := strings.LastIndexByte(preview, '\n'); i > 0 {
+		preview = preview[:i+1]
+	}
+	preview = strings.ToValidUTF8(preview, "")
+	what := "The result was"
+	if field != "" {
+		// Keep the other fields, such as the URL of fetched content
+		fields = maps.Clone(fields)
+		fields[field] = preview
+		resp["result"] = fields
+		what = fmt.Sprintf("The %s field of the result was", field)
+	} else {
+		resp["result"] = preview
+	}
+	resp["truncated"] = true
+	resp["total_bytes"] = len(text)
+	resp["total_lines"] = strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+	id, err := tm.artifacts.Save(name, text)
+	if err != nil {
+		log.Printf("Warning: failed to store the result of %s: %v", name, err)
+		resp["note"] = what + " too large to return whole; only its start is shown."
+		return
+	}
+	resp["artifact_id"] = id
+	resp["note"] = fmt.Sprintf("%s too large to return whole; only its start is shown. Call read_artifact with artifact_id %q to read the rest.", what, id)
+}
+
+// artifactField returns the string field holding most of a map result, such
+// as the content of a read file, or "" if there is none. The field is stored
+// as is rather than as JSON, which would put all its lines on one line.
+func artifactField(result any) (map[string]any, string) {
+	fields, ok := result.(map[string]any)
+	if !ok {
+		return nil, ""
+	}
+	field := ""
+	for name, v := range fields {
+		if s, ok := v.(string); ok && (field == "" || len(s) > len(fields[field].(string))) {
+			field = name
+		}
+	}
+	if field == "" {
+		return nil, ""
+	}
+	rest := maps.Clone(fields)
+	delete(rest, field)
+	if data, err := json.Marshal(rest); err != nil || len(data) > artifactPreviewBytes {
+		return nil, ""
+	}
+	return fields, field
+}
+
+// readArtifactTool handles read_artifact calls, returning the requested
+// lines of an artifact up to the result limit.
+func (tm *ToolManager) readArtifactTool(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+	var params struct {
+		ArtifactID string `json:"artifact_id"`
+		StartLine  int    `json:"start_line"`
+		EndLine    int    `json:"end_line"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if tm.artifacts == nil {
+		return nil, fmt.Errorf("artifact %s not found", params.ArtifactID)
+	}
+	path, err := tm.artifacts.Path(params.ArtifactID)
+	if err != nil {
+		return nil, err
+	}
+	start := max(params.StartLine, 1)
+	end := start + maxArtifactReadLines - 1
+	if params.EndLine > 0 {
+		if params.EndLine < start {
+			return nil, fmt.Errorf("end_line %d is before start_line %d", params.EndLine, start)
+		}
+		end = min(params.EndLine, end)
+	}
+	limit := tm.resultLimit
+	if limit < artifactPreviewBytes {
+		limit = DefaultToolResultLimit
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var content strings.Builder
+	r := bufio.NewReader(f)
+	n, last := 0, 0
+	for {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if err != io.EOF {
+				return nil, fmt.Errorf("failed to read artifact %s: %w", params.ArtifactID, err)
+			}
+			break
+		}
+		n++
+		if n < start || n > end {
+			continue
+		}
+		// Long lines are returned whole when they fit in a page
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) > limit-artifactLineOverhead {
+			line = strings.ToValidUTF8(line[:limit-artifactLineOverhead], "") + "…"
+		}
+		// Stop short of the limit, so the page is returned whole
+		numbered := fmt.Sprintf("%6d\t%s\n", n, line)
+		if content.Len()+len(numbered) > limit && last > 0 {
+			end = last
+			continue
+		}
+		content.WriteString(numbered)
+		last = n
+	}
+	if start > n {
+		return nil, fmt.Errorf("start_line %d is past the end of the artifact (%d lines)", start, n)
+	}
+	return map[string]any{
+		"artifact_id": params.ArtifactID,
+		"content":     content.String(),
+		"start_line":  start,
+		"end_line":    last,
+		"total_lines": n,
+	}, nil
+}
+
+// pagerFinishedMsg is sent when the pager exits.
+type pagerFinishedMsg struct {
+	err error
+}
+
+// pagerCommand returns the user's preferred pager from PAGER. The value may
+// include arguments.
+func pagerCommand() []string {
+	if fields := strings.Fields(os.Getenv("PAGER")); len(fields) > 0 {
+		return fields
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"more"}
+	}
+	return []string{"less"}
+}
+
+// latestArtifact returns the ID of the most recent tool result stored as an
+// artifact, or "" if there is none.
+func (m *Model) latestArtifact() string {
+	if m.toolManager == nil || m.toolManager.artifacts == nil {
+		return ""
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if resp := m.messages[i].ToolResponse; resp != nil {
+			if id := resp.GetResponse().GetFields()["artifact_id"].GetStringValue(); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// openPagerCmd suspends the TUI and shows the most recent tool result stored
+// as an artifact in $PAGER. It returns nil when there is none.
+func (m *Model) openPagerCmd() tea.Cmd {
+	id := m.latestArtifact()
+	if id == "" {
+		return nil
+	}
+	path, err := m.toolManager.artifacts.Path(id)
+	if err != nil {
+		return func() tea.Msg { return pagerFinishedMsg{err: err} }
+	}
+	pager := pagerCommand()
+	log.Printf("Opening in pager: %s %s", strings.Join(pager, " "), path)
+	cmd := exec.Command(pager[0], append(pager[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return pagerFinishedMsg{err: err}
+	})
+}
@@ -0,0 +1,180 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStoreLargeResults(t *testing.T) {
+	tm := NewToolManager()
+	store := NewArtifactStore(t.TempDir())
+	if err := tm.StoreLargeResults(store, 4096); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for i := 1; i <= 1000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	output := strings.Join(lines, "\n") + "\n"
+	tm.RegisterTool("big", "Returns a lot", nil, func(json.RawMessage) (any, error) { return output, nil })
+	tm.RegisterTool("small", "Returns a little", nil, func(json.RawMessage) (any, error) { return map[string]any{"ok": true}, nil })
+
+	call := func(name, args string) map[string]any {
+		t.Helper()
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: name, Arguments: json.RawMessage(args)})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return resp.Response.AsMap()
+	}
+
+	if got := call("small", `{}`); got["artifact_id"] != nil || got["result"].(map[string]any)["ok"] != true {
+		t.Errorf("Expected a small result sent whole, got %v", got)
+	}
+
+	got := call("big", `{}`)
+	id, _ := got["artifact_id"].(string)
+	if !strings.HasPrefix(id, "big-") || got["truncated"] != true || got["total_lines"] != float64(1000) {
+		t.Fatalf("Expected the result stored as an artifact, got %v", got)
+	}
+	if preview := got["result"].(string); len(preview) > 4096 || !strings.HasPrefix(preview, "line 1\n") || !strings.HasSuffix(preview, "\n") {
+		t.Errorf("Expected a preview of whole lines within the limit, got %q", preview)
+	}
+	path, err := store.Path(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != output {
+		t.Errorf("Expected the whole result in the artifact, got %d bytes", len(data))
+	}
+
+	// Paging through the artifact returns every line once
+	var read []string
+	for start := 1; start <= 1000; {
+		page := call("read_artifact", fmt.Sprintf(`{"artifact_id": %q, "start_line": %d}`, id, start))["result"].(map[string]any)
+		content := page["content"].(string)
+		if len(content) > 4096 {
+			t.Fatalf("Expected a page within the limit, got %d bytes", len(content))
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			_, text, _ := strings.Cut(line, "\t")
+			read = append(read, text)
+		}
+		start = int(page["end_line"].(float64)) + 1
+	}
+	if strings.Join(read, "\n") != strings.Join(lines, "\n") {
+		t.Errorf("Paging returned %d lines, want 1000", len(read))
+	}
+
+	if err := tm.StoreLargeResults(store, 15); err == nil {
+		t.Error("Expected an error for a limit too small to hold a preview")
+	}
+
+	for _, bad := range []string{"../secret", "missing-00000000"} {
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "2", Name: "read_artifact", Arguments: json.RawMessage(fmt.Sprintf(`{"artifact_id": %q}`, bad))})
+		if err == nil {
+			t.Errorf("Expected an error reading artifact %q, got %v", bad, resp.Response.AsMap())
+		}
+	}
+}
+
+// TestStoreLargeMapResult tests the large field of a map result, such as
+// fetched content, is stored with its lines and can be paged back whole
+func TestStoreLargeMapResult(t *testing.T) {
+	tm := NewToolManager()
+	if err := tm.StoreLargeResults(NewArtifactStore(t.TempDir()), DefaultToolResultLimit); err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	for i := 1; content.Len() < 78000; i++ {
+		fmt.Fprintf(&content, "Paragraph %d of the fetched page, with some text to read.\n", i)
+	}
+	tm.RegisterTool("fetch", "Fetches a page", nil, func(json.RawMessage) (any, error) {
+		return map[string]any{"url": "https://example.com/", "content": content.String()}, nil
+	})
+	call := func(name, args string) map[string]any {
+		t.Helper()
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: name, Arguments: json.RawMessage(args)})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return resp.Response.AsMap()
+	}
+
+	got := call("fetch", `{}`)
+	id, _ := got["artifact_id"].(string)
+	result, _ := got["result"].(map[string]any)
+	if id == "" || result["url"] != "https://example.com/" || !strings.HasPrefix(content.String(), result["content"].(string)) {
+		t.Fatalf("Expected the content field stored as an artifact with the other fields kept, got %v", got)
+	}
+
+	var read strings.Builder
+	for start, total := 1, 1; start <= total; {
+		page := call("read_artifact", fmt.Sprintf(`{"artifact_id": %q, "start_line": %d}`, id, start))["result"].(map[string]any)
+		for _, line := range strings.SplitAfter(page["content"].(string), "\n") {
+			if _, text, ok := strings.Cut(line, "\t"); ok {
+				read.WriteString(text)
+			}
+		}
+		start, total = int(page["end_line"].(float64))+1, int(page["total_lines"].(float64))
+	}
+	if read.String() != content.String() {
+		t.Errorf("Paging returned %d bytes, want %d", read.Len(), content.Len())
+	}
+}
+
+// TestToolResultArtifactView tests a result stored as an artifact is shown
+// collapsed, with the pager opening it
+func TestToolResultArtifactView(t *testing.T) {
+	tm := NewToolManager()
+	if err := tm.StoreLargeResults(NewArtifactStore(t.TempDir()), 4096); err != nil {
+		t.Fatal(err)
+	}
+	tm.RegisterTool("big", "Returns a lot", nil, func(json.RawMessage) (any, error) { return strings.Repeat("data\n", 1000), nil })
+	resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: "big", Arguments: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := ToolCallViewModel{ID: "1", Name: "big", Status: ToolCallStatusCompleted, Result: resp.Response}
+	msg := formatToolResultMessageFromViewModel(vm)
+	if !strings.Contains(msg.Content, "5000 bytes in 1000 lines, stored as artifact big-") || strings.Contains(msg.Content, "data") {
+		t.Errorf("Expected a collapsed result, got:\n%s", msg.Content)
+	}
+
+	m := &Model{toolManager: tm, messages: []Message{msg}}
+	t.Setenv("PAGER", "cat")
+	if m.latestArtifact() != resp.Response.AsMap()["artifact_id"] || m.openPagerCmd() == nil {
+		t.Errorf("Expected the pager to open the artifact")
+	}
+	m.messages = nil
+	if m.openPagerCmd() != nil {
+		t.Errorf("Expected no pager without an artifact")
+	}
+}
+
+// TestPagerKeyWithoutArtifact tests ctrl+f moves the cursor forward once
+// when there is no stored result to page
+func TestPagerKeyWithoutArtifact(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	m := New(WithTools(true))
+	m.focusedComponent = "input"
+	m.textarea.Focus()
+	m.textarea.SetValue("abc")
+	m.textarea.CursorStart()
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlF})
+	m = updated.(*Model)
+	m.textarea.InsertString("X")
+	if got := m.textarea.Value(); got != "aXbc" {
+		t.Errorf("Expected the cursor moved forward once, got %q", got)
+	}
+}
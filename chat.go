@@ -0,0 +1,689 @@
+package aistudio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+
+	"github.com/tmc/aistudio/api"
+)
+
+// defaultChatMaxSteps bounds the model requests made for one Send, so a model
+// that keeps calling tools cannot loop forever.
+const defaultChatMaxSteps = 20
+
+// ChatEvent is an event in the response to Chat.Send. It is one of
+// TextDeltaEvent, ThoughtEvent, AudioEvent, ToolCallEvent, ToolStartEvent,
+// ToolProgressEvent, ToolResultEvent, UsageEvent or TurnCompleteEvent.
+type ChatEvent interface {
+	chatEvent()
+}
+
+// TextDeltaEvent is the next fragment of the answer text.
+type TextDeltaEvent struct {
+	Text string
+}
+
+// ThoughtEvent is the next fragment of the thought summary.
+type ThoughtEvent struct {
+	Text string
+}
+
+// AudioEvent is a chunk of generated audio (PCM S16LE, 24kHz).
+type AudioEvent struct {
+	Data []byte
+}
+
+// ToolCallEvent reports a tool call requested by the model, before it runs.
+// Its arguments include the defaults of the tool's parameters.
+type ToolCallEvent struct {
+	Call ToolCall
+}
+
+// ToolStartEvent reports that an approved tool call started running. Calls
+// made together run concurrently, so a call may wait for others to finish
+// before it starts. Events for concurrent calls interleave.
+type ToolStartEvent struct {
+	Call ToolCall
+}
+
+// ToolProgressEvent is a line of progress reported by a running tool call.
+type ToolProgressEvent struct {
+	Call ToolCall
+	Line string
+}
+
+// ToolResultEvent reports the outcome of a tool call. Approved is false when
+// ApproveToolCall denied the call; Err is set when the tool failed, is
+// ErrToolCancelled when the call was cancelled with CancelToolCall, and is a
+// *ToolArgumentsError, without asking for approval, when the arguments did
+// not match the tool's parameters.
+type ToolResultEvent struct {
+	Call     ToolCall
+	Result   *ToolResponse
+	Approved bool
+	Err      error
+}
+
+// UsageEvent reports the token usage of a model response.
+type UsageEvent struct {
+	TokenCounts TokenCounts
+}
+
+// TurnCompleteEvent ends the response to a Send. Text is the answer of the
+// final model response, after any tool calls.
+type TurnCompleteEvent struct {
+	Text              string
+	GroundingMetadata *generativelanguagepb.GroundingMetadata
+	BlockedReason     string // Why the prompt or answer was blocked, if it was
+	SafetyRatings     []*generativelanguagepb.SafetyRating
+}
+
+func (TextDeltaEvent) chatEvent()    {}
+func (ThoughtEvent) chatEvent()      {}
+func (AudioEvent) chatEvent()        {}
+func (ToolCallEvent) chatEvent()     {}
+func (ToolStartEvent) chatEvent()    {}
+func (ToolProgressEvent) chatEvent() {}
+func (ToolResultEvent) chatEvent()   {}
+func (UsageEvent) chatEvent()        {}
+func (TurnCompleteEvent) chatEvent() {}
+
+// TextPart returns a text part for Chat.Send.
+func TextPart(text string) *generativelanguagepb.Part {
+	return &generativelanguagepb.Part{Data: &generativelanguagepb.Part_Text{Text: text}}
+}
+
+// Chat is a conversation with a model that runs without the TUI. It keeps the
+// conversation history, sends each message with that history and runs the
+// tool calls the model makes through its ToolManager, sending the results
+// back until the model answers. Live models keep the history server side and
+// are used over a single WebSocket session.
+//
+// A Chat handles one Send at a time; a Send waits for the turn in flight to
+// end.
+type Chat struct {
+	// ApproveToolCall decides whether a tool call may run, returning the call
+	// to run, which may have different arguments, such as file content the
+	// user edited. Denied calls are answered with an error. When nil, every
+	// call runs.
+	ApproveToolCall func(ctx context.Context, call ToolCall) (ToolCall, bool)
+
+	// ResponseSchema constrains answers to JSON matching the schema. It
+	// defaults to the schema in the config's ResponseSchemaFile.
+	ResponseSchema *generativelanguagepb.Schema
+
+	// MaxSteps limits the model requests made for one Send (default 20).
+	MaxSteps int
+
+	client *api.Client
+	config api.StreamClientConfig
+	tools  *ToolManager
+
+	mu         sync.Mutex // Guards the fields below; not held during a turn
+	history    []*generativelanguagepb.Content
+	live       generativelanguagepb.GenerativeService_StreamGenerateContentClient
+	liveCancel context.CancelFunc
+	turnDone   chan struct{} // Closed when the turn in flight ends
+
+	runningMu sync.Mutex // Guards running, which CancelToolCall uses during a turn
+	running   map[string]context.CancelCauseFunc
+}
+
+// NewChat returns a chat using an initialized client. tools may be nil to
+// disable tool calling.
+func NewChat(client *api.Client, config api.StreamClientConfig, tools *ToolManager) *Chat {
+	return &Chat{
+		client: client,
+		config: config,
+		tools:  tools,
+	}
+}
+
+// CancelToolCall cancels a running tool call by ID. The model is told the call
+// was cancelled and the turn continues. It reports whether the call was running.
+func (c *Chat) CancelToolCall(id string) bool {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	cancel, ok := c.running[id]
+	if ok {
+		cancel(ErrToolCancelled)
+	}
+	return ok
+}
+
+// History returns the conversation so far.
+func (c *Chat) History() []*generativelanguagepb.Content {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*generativelanguagepb.Content(nil), c.history...)
+}
+
+// dropLastTurn removes the last user message and the responses to it from
+// the history and returns the message, or nil if there is none.
+func (c *Chat) dropLastTurn() []*generativelanguagepb.Part {
+	for i := len(c.history) - 1; i >= 0; i-- {
+		if c.history[i].Role != "user" {
+			continue
+		}
+		isToolResponse := false
+		for _, p := range c.history[i].Parts {
+			if p.GetFunctionResponse() != nil {
+				isToolResponse = true
+			}
+		}
+		if !isToolResponse {
+			parts := c.history[i].Parts
+			c.history = c.history[:i]
+			return parts
+		}
+	}
+	return nil
+}
+
+// appendHistory adds content to the conversation.
+func (c *Chat) appendHistory(content *generativelanguagepb.Content) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = append(c.history, content)
+}
+
+// Close ends the Live session, if one was opened.
+func (c *Chat) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeLive(c.live)
+}
+
+// closeLive ends the Live session live, unless it was already replaced. The
+// caller holds c.mu.
+func (c *Chat) closeLive(live generativelanguagepb.GenerativeService_StreamGenerateContentClient) error {
+	if c.live == nil || c.live != live {
+		return nil
+	}
+	err := c.live.CloseSend()
+	c.liveCancel()
+	c.live = nil
+	return err
+}
+
+// ChatStream delivers the events of one Send.
+type ChatStream struct {
+	events chan ChatEvent
+	err    error // Set before events is closed
+}
+
+// Recv returns the next event. It returns io.EOF after the TurnCompleteEvent,
+// or the error that ended the turn.
+func (s *ChatStream) Recv() (ChatEvent, error) {
+	if ev, ok := <-s.events; ok {
+		return ev, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return nil, io.EOF
+}
+
+// emit delivers an event, giving up if the caller has gone away.
+func (s *ChatStream) emit(ctx context.Context, ev ChatEvent) bool {
+	select {
+	case s.events <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Send adds a user message to the conversation and starts generating the
+// response. Cancelling ctx stops the turn, ending a Live session, which the
+// next Send starts again; otherwise the stream must be read until Recv
+// returns an error before the next Send. A turn that fails or is cancelled
+// leaves the history as it was before the Send.
+func (c *Chat) Send(ctx context.Context, parts ...*generativelanguagepb.Part) (*ChatStream, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no message parts to send")
+	}
+	return c.start(ctx, parts)
+}
+
+// Regenerate discards the response to the last user message and generates
+// it again. Live sessions keep the discarded answer in their server-side
+// history.
+func (c *Chat) Regenerate(ctx context.Context) (*ChatStream, error) {
+	return c.start(ctx, nil)
+}
+
+// start runs a turn for parts, or for the last user message again when parts
+// is nil.
+func (c *Chat) start(ctx context.Context, parts []*generativelanguagepb.Part) (*ChatStream, error) {
+	if c.ResponseSchema == nil && c.config.ResponseSchemaFile != "" {
+		schema, err := api.LoadResponseSchema(c.config.ResponseSchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		c.ResponseSchema = schema
+	}
+
+	c.mu.Lock()
+	for c.turnDone != nil {
+		done := c.turnDone
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	defer c.mu.Unlock()
+	saved := slices.Clone(c.history)
+	if parts == nil {
+		if parts = c.dropLastTurn(); parts == nil {
+			return nil, fmt.Errorf("no message to regenerate")
+		}
+	}
+	done := make(chan struct{})
+	c.turnDone = done
+
+	stream := &ChatStream{events: make(chan ChatEvent)}
+	go func() {
+		defer close(stream.events)
+		if c.isLive() {
+			stream.err = c.runLive(ctx, parts, stream)
+		} else {
+			stream.err = c.run(ctx, parts, stream)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if stream.err != nil {
+			// A failed or cancelled turn may leave a tool call without its
+			// response, which the API rejects in every later request
+			c.history = saved
+		}
+		c.turnDone = nil
+		close(done)
+	}()
+	return stream, nil
+}
+
+func (c *Chat) isLive() bool {
+	return c.config.EnableWebSocket && api.IsLiveModel(c.config.ModelName)
+}
+
+func (c *Chat) maxSteps() int {
+	if c.MaxSteps > 0 {
+		return c.MaxSteps
+	}
+	return defaultChatMaxSteps
+}
+
+// turnState accumulates one model response.
+type turnState struct {
+	text      strings.Builder
+	parts     []*generativelanguagepb.Part
+	calls     []*generativelanguagepb.FunctionCall
+	grounding *generativelanguagepb.GroundingMetadata
+	blocked   api.StreamOutput
+}
+
+// handleOutput emits the events for one response chunk and records it.
+func (t *turnState) handleOutput(ctx context.Context, s *ChatStream, output api.StreamOutput) bool {
+	if output.Thought != "" && !s.emit(ctx, ThoughtEvent{Text: output.Thought}) {
+		return false
+	}
+	if output.Text != "" {
+		t.text.WriteString(output.Text)
+		if !s.emit(ctx, TextDeltaEvent{Text: output.Text}) {
+			return false
+		}
+	}
+	if len(output.Audio) > 0 && !s.emit(ctx, AudioEvent{Data: output.Audio}) {
+		return false
+	}
+	if output.GroundingMetadata != nil {
+		t.grounding = output.GroundingMetadata
+	}
+	if output.BlockedReason() != "" || (t.blocked.BlockedReason() == "" && len(output.SafetyRatings) > 0) {
+		t.blocked = output
+	}
+	if output.TotalTokenCount > 0 {
+		return s.emit(ctx, UsageEvent{TokenCounts: TokenCounts{
+			PromptTokenCount:   output.PromptTokenCount,
+			ResponseTokenCount: output.CandidateTokenCount,
+			TotalTokenCount:    output.TotalTokenCount,
+			ThoughtsTokenCount: output.ThoughtsTokenCount,
+		}})
+	}
+	return true
+}
+
+// complete emits the TurnCompleteEvent for the final response.
+func (t *turnState) complete(ctx context.Context, s *ChatStream) {
+	s.emit(ctx, TurnCompleteEvent{
+		Text:              t.text.String(),
+		GroundingMetadata: t.grounding,
+		BlockedReason:     t.blocked.BlockedReason(),
+		SafetyRatings:     t.blocked.SafetyRatings,
+	})
+}
+
+// run drives a turn over StreamGenerateContent, resending the history with
+// the tool results after each response that calls tools.
+func (c *Chat) run(ctx context.Context, parts []*generativelanguagepb.Part, s *ChatStream) error {
+	if c.client == nil || c.client.GenerativeClient == nil {
+		return fmt.Errorf("chat requires an initialized v1beta client")
+	}
+	c.appendHistory(&generativelanguagepb.Content{Role: "user", Parts: parts})
+
+	for step := 0; step < c.maxSteps(); step++ {
+		stream, err := c.client.GenerativeClient.StreamGenerateContent(ctx, c.request())
+		if err != nil {
+			return fmt.Errorf("failed to start stream: %w", err)
+		}
+
+		var turn turnState
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("stream error: %w", err)
+			}
+			output := api.ExtractOutput(resp)
+			var text strings.Builder
+			if len(resp.GetCandidates()) > 0 {
+				for _, part := range resp.Candidates[0].GetContent().GetParts() {
+					turn.parts = append(turn.parts, part)
+					if fc := part.GetFunctionCall(); fc != nil {
+						turn.calls = append(turn.calls, fc)
+					}
+					if !part.GetThought() {
+						text.WriteString(part.GetText())
+					}
+				}
+			}
+			// ExtractOutput trims each chunk, which would drop the spaces
+			// between streamed deltas
+			if text.Len() > 0 {
+				output.Text = text.String()
+			}
+			if !turn.handleOutput(ctx, s, output) {
+				return ctx.Err()
+			}
+		}
+		if c.tools == nil {
+			// Calls cannot be answered without tools, so keep only the answer
+			turn.parts = slices.DeleteFunc(turn.parts, func(p *generativelanguagepb.Part) bool {
+				return p.GetFunctionCall() != nil
+			})
+		}
+		if len(turn.parts) > 0 {
+			c.appendHistory(&generativelanguagepb.Content{Role: "model", Parts: turn.parts})
+		}
+
+		if len(turn.calls) == 0 || c.tools == nil {
+			turn.complete(ctx, s)
+			return ctx.Err()
+		}
+
+		responses, ok := c.runTools(ctx, step, turn.calls, s)
+		if !ok {
+			return ctx.Err()
+		}
+		var responseParts []*generativelanguagepb.Part
+		for _, r := range responses {
+			responseParts = append(responseParts, &generativelanguagepb.Part{
+				Data: &generativelanguagepb.Part_FunctionResponse{FunctionResponse: r},
+			})
+		}
+		c.appendHistory(&generativelanguagepb.Content{Role: "user", Parts: responseParts})
+	}
+	return fmt.Errorf("no answer after %d model requests", c.maxSteps())
+}
+
+// runLive drives a turn over the Live API session, opening it on first use.
+func (c *Chat) runLive(ctx context.Context, parts []*generativelanguagepb.Part, s *ChatStream) error {
+	var text []string
+	for _, p := range parts {
+		t, ok := p.Data.(*generativelanguagepb.Part_Text)
+		if !ok {
+			return fmt.Errorf("live chat only supports text parts")
+		}
+		text = append(text, t.Text)
+	}
+
+	c.mu.Lock()
+	live := c.live
+	c.mu.Unlock()
+	if live == nil {
+		// The session outlives the turn, so it is not started with ctx
+		liveCtx, cancel := context.WithCancel(context.Background())
+		config := c.config
+		if c.tools != nil {
+			config.ToolDefinitions = c.tools.GetAvailableTools()
+		}
+		var err error
+		if live, err = c.client.InitBidiStream(liveCtx, &config); err != nil {
+			cancel()
+			return fmt.Errorf("failed to start live session: %w", err)
+		}
+		c.mu.Lock()
+		c.live, c.liveCancel = live, cancel
+		c.mu.Unlock()
+	}
+
+	// Cancelling the turn ends the session, the only way to stop a Recv
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.closeLive(live)
+	})
+	defer stop()
+
+	c.appendHistory(&generativelanguagepb.Content{Role: "user", Parts: parts})
+	if err := c.client.SendMessageToBidiStream(live, strings.Join(text, "\n")); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	var turn turnState
+	for step := 0; ; {
+		resp, err := live.Recv()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("stream error: %w", err)
+		}
+		output := api.ExtractOutput(resp)
+		if adapter, ok := live.(*api.LiveStreamAdapter); ok {
+			if live := adapter.LastOutput(); live != nil {
+				output.PromptTokenCount = live.PromptTokenCount
+				output.CandidateTokenCount = live.CandidateTokenCount
+				output.TotalTokenCount = live.TotalTokenCount
+				output.ThoughtsTokenCount = live.ThoughtsTokenCount
+			}
+		}
+		if !turn.handleOutput(ctx, s, output) {
+			return ctx.Err()
+		}
+		if output.Text != "" {
+			turn.parts = append(turn.parts, TextPart(output.Text))
+		}
+
+		if output.FunctionCall != nil && c.tools != nil {
+			if step++; step >= c.maxSteps() {
+				return fmt.Errorf("no answer after %d tool calls", step)
+			}
+			responses, ok := c.runTools(ctx, step, []*generativelanguagepb.FunctionCall{output.FunctionCall}, s)
+			if !ok {
+				return ctx.Err()
+			}
+			if err := c.client.SendToolResultsToBidiStream(live, responses...); err != nil {
+				return fmt.Errorf("failed to send tool results: %w", err)
+			}
+		}
+
+		if output.TurnComplete {
+			if len(turn.parts) > 0 {
+				c.appendHistory(&generativelanguagepb.Content{Role: "model", Parts: turn.parts})
+			}
+			turn.complete(ctx, s)
+			return ctx.Err()
+		}
+	}
+}
+
+// runTools runs the tool calls of one response and returns the responses to
+// send back to the model, in the order the model made the calls. Approval is
+// asked for every call first; the approved calls then run concurrently, as
+// the ToolManager's concurrency limit allows.
+func (c *Chat) runTools(ctx context.Context, step int, calls []*generativelanguagepb.FunctionCall, s *ChatStream) ([]*generativelanguagepb.FunctionResponse, bool) {
+	results := make([]ToolResultEvent, len(calls))
+	var wg sync.WaitGroup
+	for i, fc := range calls {
+		call := ExtractToolCalls(&api.StreamOutput{FunctionCall: fc})[0]
+		if call.ID == "" {
+			// The Gemini API leaves IDs out; events still need to tell calls apart
+			call.ID = fmt.Sprintf("chat-%d-%d-%d", time.Now().UnixNano(), step, i)
+		}
+		call, argsErr := c.tools.PrepareToolCall(call)
+		if !s.emit(ctx, ToolCallEvent{Call: call}) {
+			return nil, false
+		}
+		if argsErr != nil {
+			// Let the model correct the call without bothering the user
+			log.Printf("Tool call rejected: %v", argsErr)
+			results[i] = ToolResultEvent{Call: call, Result: toolArgumentsResponse(call, argsErr), Approved: true, Err: argsErr}
+			if !s.emit(ctx, results[i]) {
+				wg.Wait()
+				return nil, false
+			}
+			continue
+		}
+
+		ev := ToolResultEvent{Call: call, Approved: true}
+		if c.ApproveToolCall != nil {
+			ev.Call, ev.Approved = c.ApproveToolCall(ctx, call)
+			call = ev.Call
+		}
+		if !ev.Approved {
+			log.Printf("Tool call denied: %s", call.Name)
+			ev.Err = fmt.Errorf("tool call denied by user")
+			ev.Result = &ToolResponse{Id: call.ID, Name: call.Name, Response: mkErrorResponseStruct(ev.Err)}
+			results[i] = ev
+			if ctx.Err() != nil || !s.emit(ctx, ev) {
+				wg.Wait()
+				return nil, false
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ev.Result, ev.Err = c.executeTool(ctx, call, s)
+			results[i] = ev
+			s.emit(ctx, ev)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, false
+	}
+
+	var responses []*generativelanguagepb.FunctionResponse
+	for i, fc := range calls {
+		// Answer with the ID the model used, which may be empty
+		responses = append(responses, &generativelanguagepb.FunctionResponse{
+			Id:       fc.Id,
+			Name:     results[i].Result.Name,
+			Response: results[i].Result.Response,
+		})
+	}
+	return responses, true
+}
+
+// executeTool runs an approved tool call when its turn comes, reporting its
+// start and progress, so that CancelToolCall can stop it.
+func (c *Chat) executeTool(ctx context.Context, call ToolCall, s *ChatStream) (*ToolResponse, error) {
+	callCtx, cancel := context.WithCancelCause(ctx)
+	c.runningMu.Lock()
+	if c.running == nil {
+		c.running = make(map[string]context.CancelCauseFunc)
+	}
+	c.running[call.ID] = cancel
+	c.runningMu.Unlock()
+	defer func() {
+		c.runningMu.Lock()
+		delete(c.running, call.ID)
+		c.runningMu.Unlock()
+		cancel(nil)
+	}()
+
+	// A handler abandoned on cancellation may report progress after the
+	// call returns, when the stream may be closed
+	var progressMu sync.Mutex
+	finished := false
+	defer func() {
+		progressMu.Lock()
+		finished = true
+		progressMu.Unlock()
+	}()
+	started := func() { s.emit(callCtx, ToolStartEvent{Call: call}) }
+	return c.tools.ExecuteQueued(callCtx, call, started, func(line string) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		if !finished {
+			s.emit(callCtx, ToolProgressEvent{Call: call, Line: line})
+		}
+	})
+}
+
+// request builds the StreamGenerateContent request for the history so far.
+func (c *Chat) request() *generativelanguagepb.GenerateContentRequest {
+	config := &c.config
+	genConfig := &generativelanguagepb.GenerationConfig{}
+	genConfig.Temperature = &config.Temperature
+	if config.TopP > 0 {
+		genConfig.TopP = &config.TopP
+	}
+	if config.TopK > 0 {
+		genConfig.TopK = &config.TopK
+	}
+	if config.MaxOutputTokens > 0 {
+		genConfig.MaxOutputTokens = &config.MaxOutputTokens
+	}
+	genConfig.ThinkingConfig = api.ThinkingConfig(config)
+	if config.ResponseMimeType != "" {
+		genConfig.ResponseMimeType = config.ResponseMimeType
+	}
+	if c.ResponseSchema != nil {
+		genConfig.ResponseMimeType = "application/json"
+		genConfig.ResponseSchema = c.ResponseSchema
+	}
+
+	request := &generativelanguagepb.GenerateContentRequest{
+		Model:            config.ModelName,
+		Contents:         c.History(),
+		GenerationConfig: genConfig,
+		SafetySettings:   config.SafetySettings,
+	}
+	if config.SystemPrompt != "" {
+		request.SystemInstruction = &generativelanguagepb.Content{Parts: []*generativelanguagepb.Part{TextPart(config.SystemPrompt)}}
+	}
+	if c.tools != nil {
+		if defs := c.tools.GetAvailableTools(); len(defs) > 0 {
+			request.Tools = []*generativelanguagepb.Tool{{FunctionDeclarations: defs}}
+		}
+	}
+	return request
+}
@@ -0,0 +1,563 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/tmc/aistudio/api"
+)
+
+// fakeChatService answers each StreamGenerateContent request with the next
+// scripted response and records the requests.
+type fakeChatService struct {
+	generativelanguagepb.UnimplementedGenerativeServiceServer
+	mu        sync.Mutex
+	responses [][]*generativelanguagepb.Part
+	requests  []*generativelanguagepb.GenerateContentRequest
+}
+
+func (s *fakeChatService) StreamGenerateContent(req *generativelanguagepb.GenerateContentRequest, stream generativelanguagepb.GenerativeService_StreamGenerateContentServer) error {
+	s.mu.Lock()
+	n := len(s.requests)
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+	if n >= len(s.responses) {
+		return errors.New("unexpected request")
+	}
+	for i, part := range s.responses[n] {
+		resp := &generativelanguagepb.GenerateContentResponse{
+			Candidates: []*generativelanguagepb.Candidate{{
+				Content: &generativelanguagepb.Content{Role: "model", Parts: []*generativelanguagepb.Part{part}},
+			}},
+		}
+		if i == len(s.responses[n])-1 {
+			resp.Candidates[0].FinishReason = generativelanguagepb.Candidate_STOP
+			resp.UsageMetadata = &generativelanguagepb.GenerateContentResponse_UsageMetadata{PromptTokenCount: 3, CandidatesTokenCount: 2, TotalTokenCount: 5}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeChatService) request(i int) *generativelanguagepb.GenerateContentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[i]
+}
+
+func newFakeChatClient(t *testing.T, svc *fakeChatService) *api.Client {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	generativelanguagepb.RegisterGenerativeServiceServer(server, svc)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &api.Client{}
+	if err := client.InitWithGRPCConn(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func functionCallPart(name string, args map[string]any) *generativelanguagepb.Part {
+	s, _ := structpb.NewStruct(args)
+	return &generativelanguagepb.Part{Data: &generativelanguagepb.Part_FunctionCall{
+		FunctionCall: &generativelanguagepb.FunctionCall{Name: name, Args: s},
+	}}
+}
+
+// collectChatEvents reads a turn to the end
+func collectChatEvents(t *testing.T, stream *ChatStream) []ChatEvent {
+	t.Helper()
+	return collectChatEventsWith(t, stream, func(ChatEvent) {})
+}
+
+// collectChatEventsWith reads a turn to the end, passing each event to f as it arrives
+func collectChatEventsWith(t *testing.T, stream *ChatStream, f func(ChatEvent)) []ChatEvent {
+	t.Helper()
+	var events []ChatEvent
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		f(ev)
+		events = append(events, ev)
+	}
+}
+
+func newEchoTools(t *testing.T, calls *int) *ToolManager {
+	t.Helper()
+	tm := NewToolManager()
+	params := json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)
+	err := tm.RegisterTool("echo", "Echo the text", params, func(args json.RawMessage) (any, error) {
+		*calls++
+		var in struct{ Text string }
+		json.Unmarshal(args, &in)
+		return in.Text, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+// TestChatToolLoop tests a tool call is run and its result sent back with the history
+func TestChatToolLoop(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("echo", map[string]any{"text": "ping"})},
+		{TextPart("The tool said "), TextPart("ping.")},
+	}}
+	calls := 0
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test", SystemPrompt: "Be brief"}, newEchoTools(t, &calls))
+
+	stream, err := chat.Send(context.Background(), TextPart("Echo ping"))
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	events := collectChatEvents(t, stream)
+
+	var kinds []string
+	var done TurnCompleteEvent
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case ToolCallEvent:
+			kinds = append(kinds, "call:"+ev.Call.Name)
+		case ToolResultEvent:
+			if !ev.Approved || ev.Err != nil {
+				t.Errorf("Unexpected tool result: %+v", ev)
+			}
+			kinds = append(kinds, "result:"+ev.Result.Response.Fields["result"].GetStringValue())
+		case TextDeltaEvent:
+			kinds = append(kinds, "text")
+		case UsageEvent:
+			kinds = append(kinds, "usage")
+		case TurnCompleteEvent:
+			done = ev
+			kinds = append(kinds, "done")
+		}
+	}
+	want := []string{"usage", "call:echo", "result:ping", "text", "text", "usage", "done"}
+	if len(kinds) != len(want) {
+		t.Fatalf("events = %q, want %q", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("events = %q, want %q", kinds, want)
+		}
+	}
+	if done.Text != "The tool said ping." || calls != 1 {
+		t.Errorf("Unexpected answer %q after %d tool calls", done.Text, calls)
+	}
+
+	second := svc.request(1)
+	if len(second.Contents) != 3 || second.Contents[2].Parts[0].GetFunctionResponse().GetName() != "echo" {
+		t.Errorf("Expected the tool result in the second request, got %v", second.Contents)
+	}
+	if second.GetSystemInstruction() == nil || len(second.Tools) != 1 {
+		t.Errorf("Expected system instruction and tools in the request")
+	}
+	if got := len(chat.History()); got != 4 {
+		t.Errorf("Expected 4 history entries, got %d", got)
+	}
+}
+
+// TestChatDeniedToolCall tests a denied call is answered with an error and not run
+func TestChatDeniedToolCall(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("echo", map[string]any{"text": "ping"})},
+		{TextPart("Okay.")},
+	}}
+	calls := 0
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, newEchoTools(t, &calls))
+	chat.ApproveToolCall = func(ctx context.Context, call ToolCall) (ToolCall, bool) { return call, false }
+
+	stream, err := chat.Send(context.Background(), TextPart("Echo ping"))
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	collectChatEvents(t, stream)
+
+	if calls != 0 {
+		t.Errorf("Denied tool ran %d times", calls)
+	}
+	resp := svc.request(1).Contents[2].Parts[0].GetFunctionResponse()
+	if resp.GetResponse().Fields["error"].GetStringValue() != "tool call denied by user" {
+		t.Errorf("Expected a denial error, got %v", resp)
+	}
+}
+
+// TestChatRegenerate tests regenerating resends the last message without its answer
+func TestChatRegenerate(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{TextPart("First.")},
+		{TextPart("Second.")},
+	}}
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, nil)
+
+	stream, err := chat.Send(context.Background(), TextPart("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectChatEvents(t, stream)
+	if stream, err = chat.Regenerate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	collectChatEvents(t, stream)
+
+	if contents := svc.request(1).Contents; len(contents) != 1 || contents[0].Parts[0].GetText() != "Hello" {
+		t.Errorf("Expected only the user message in the regenerate request, got %v", contents)
+	}
+	if history := chat.History(); len(history) != 2 || history[1].Parts[0].GetText() != "Second." {
+		t.Errorf("Unexpected history after regenerate: %v", history)
+	}
+}
+
+// TestHandleChatEvents tests chat events are shown in the conversation
+func TestHandleChatEvents(t *testing.T) {
+	m := &Model{messages: []Message{formatMessage(senderNameUser, "Echo ping")}}
+	call := ToolCall{ID: "call-1", Name: "echo", Arguments: json.RawMessage(`{"text":"ping"}`)}
+	result := &ToolResponse{Id: "call-1", Name: "echo", Response: mkErrorResponseStruct(errors.New("tool call denied by user"))}
+
+	for _, ev := range []ChatEvent{
+		ToolCallEvent{Call: call},
+		ToolResultEvent{Call: call, Result: result, Approved: false},
+		TextDeltaEvent{Text: "Not "},
+		TextDeltaEvent{Text: "allowed."},
+		UsageEvent{TokenCounts: TokenCounts{TotalTokenCount: 9}},
+		TurnCompleteEvent{Text: "Not allowed."},
+	} {
+		m.handleChatEvent(chatEventMsg{event: ev})
+	}
+
+	if len(m.messages) != 4 {
+		t.Fatalf("Expected user, tool call, tool result and answer messages, got %d", len(m.messages))
+	}
+	if vm := m.toolCallCache["call-1"]; vm == nil || vm.Status != ToolCallStatusRejected {
+		t.Errorf("Expected the tool call to be shown as rejected, got %+v", vm)
+	}
+	answer := m.messages[3]
+	if answer.Content != "Not allowed." || answer.TokenCounts == nil || answer.TokenCounts.TotalTokenCount != 9 {
+		t.Errorf("Unexpected answer message: %+v", answer)
+	}
+	if m.currentState != AppStateReady {
+		t.Errorf("Expected the ready state after the turn, got %v", m.currentState)
+	}
+}
+
+// TestChatApproval tests tool calls from a chat turn go through the approval modal
+func TestChatApproval(t *testing.T) {
+	m := &Model{requireApproval: true, approvedToolTypes: map[string]bool{"list": true}}
+
+	auto := make(chan *ToolCall, 1)
+	m.handleChatApproval(chatApprovalMsg{call: ToolCall{ID: "1", Name: "list"}, reply: auto})
+	if <-auto == nil || m.showToolApproval {
+		t.Fatal("Expected a pre-approved tool type to be approved without the modal")
+	}
+
+	reply := make(chan *ToolCall, 1)
+	call := ToolCall{ID: "2", Name: "write"}
+	m.handleChatApproval(chatApprovalMsg{call: call, reply: reply})
+	if !m.showToolApproval || len(m.pendingToolCalls) != 1 {
+		t.Fatal("Expected the call to wait in the approval modal")
+	}
+	if !m.answerChatApproval(call, false) || <-reply != nil {
+		t.Error("Expected the denial to reach the chat")
+	}
+	if m.answerChatApproval(ToolCall{ID: "3"}, true) {
+		t.Error("Expected calls outside the chat to be left to the modal")
+	}
+}
+
+// TestChatCancelToolCall tests a cancelled tool call is answered as cancelled
+// and the turn continues
+func TestChatCancelToolCall(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("build", nil)},
+		{TextPart("Build cancelled.")},
+	}}
+	tm := NewToolManager()
+	err := tm.RegisterStreamingTool("build", "Runs the build", nil, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		progress("compiling")
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, tm)
+
+	stream, err := chat.Send(context.Background(), TextPart("Build it"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ToolResultEvent
+	var done TurnCompleteEvent
+	for _, ev := range collectChatEventsWith(t, stream, func(ev ChatEvent) {
+		if p, ok := ev.(ToolProgressEvent); ok && p.Line == "compiling" {
+			if !chat.CancelToolCall(p.Call.ID) {
+				t.Error("Expected the call to be running")
+			}
+		}
+	}) {
+		switch ev := ev.(type) {
+		case ToolResultEvent:
+			result = ev
+		case TurnCompleteEvent:
+			done = ev
+		}
+	}
+
+	if !errors.Is(result.Err, ErrToolCancelled) {
+		t.Errorf("Expected ErrToolCancelled, got %v", result.Err)
+	}
+	resp := svc.request(1).Contents[2].Parts[0].GetFunctionResponse().GetResponse()
+	if !resp.Fields["cancelled"].GetBoolValue() {
+		t.Errorf("Expected a cancelled response sent to the model, got %v", resp)
+	}
+	if done.Text != "Build cancelled." {
+		t.Errorf("Expected the turn to continue, got %q", done.Text)
+	}
+}
+
+func TestChatParallelToolCalls(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("slow", nil), functionCallPart("fast", nil)},
+		{TextPart("Both done.")},
+	}}
+	tm := NewToolManager()
+	for name, delay := range map[string]time.Duration{"slow": 50 * time.Millisecond, "fast": 0} {
+		err := tm.RegisterTool(name, "Waits a while", nil, func(args json.RawMessage) (any, error) {
+			time.Sleep(delay)
+			return name, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, tm)
+
+	stream, err := chat.Send(context.Background(), TextPart("Go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started, finished []string
+	for _, ev := range collectChatEvents(t, stream) {
+		switch ev := ev.(type) {
+		case ToolStartEvent:
+			started = append(started, ev.Call.Name)
+		case ToolResultEvent:
+			finished = append(finished, ev.Call.Name)
+		}
+	}
+
+	if len(started) != 2 {
+		t.Errorf("Expected both calls to start, got %q", started)
+	}
+	if got := strings.Join(finished, ","); got != "fast,slow" {
+		t.Errorf("Expected the fast call to finish first, got %q", got)
+	}
+	parts := svc.request(1).Contents[2].Parts
+	if len(parts) != 2 || parts[0].GetFunctionResponse().GetName() != "slow" || parts[1].GetFunctionResponse().GetName() != "fast" {
+		t.Errorf("Expected responses in call order, got %v", parts)
+	}
+}
+
+func TestChatInvalidToolArguments(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("echo", map[string]any{"text": 42})},
+		{TextPart("Let me fix that.")},
+	}}
+	tm := NewToolManager()
+	params := json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`)
+	err := tm.RegisterTool("echo", "Echoes text", params, func(args json.RawMessage) (any, error) {
+		t.Error("Expected the handler not to run")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, tm)
+	chat.ApproveToolCall = func(_ context.Context, call ToolCall) (ToolCall, bool) {
+		t.Error("Expected no approval to be asked")
+		return call, false
+	}
+
+	stream, err := chat.Send(context.Background(), TextPart("Echo 42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ToolResultEvent
+	for _, ev := range collectChatEvents(t, stream) {
+		if ev, ok := ev.(ToolResultEvent); ok {
+			result = ev
+		}
+	}
+
+	var argsErr *ToolArgumentsError
+	if !errors.As(result.Err, &argsErr) {
+		t.Fatalf("Expected a ToolArgumentsError, got %v", result.Err)
+	}
+	resp := svc.request(1).Contents[2].Parts[0].GetFunctionResponse().GetResponse()
+	problems := resp.Fields["validation_errors"].GetListValue().GetValues()
+	if len(problems) != 1 || problems[0].GetStringValue() != "$.text: expected string, got number" {
+		t.Errorf("Expected the validation error sent to the model, got %v", resp)
+	}
+}
+
+// TestChatCancelledTurn tests a turn cancelled while a tool runs is dropped
+// from the history, so the next Send is a valid request
+func TestChatCancelledTurn(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("build", nil)},
+		{TextPart("Hello.")},
+	}}
+	tm := NewToolManager()
+	err := tm.RegisterStreamingTool("build", "Runs the build", nil, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		progress("compiling")
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, tm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := chat.Send(ctx, TextPart("Build it"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got %v", err)
+			}
+			break
+		}
+		if _, ok := ev.(ToolProgressEvent); ok {
+			cancel()
+		}
+	}
+	if history := chat.History(); len(history) != 0 {
+		t.Errorf("Expected the cancelled turn dropped from the history, got %v", history)
+	}
+
+	if stream, err = chat.Send(context.Background(), TextPart("Hi")); err != nil {
+		t.Fatal(err)
+	}
+	collectChatEvents(t, stream)
+	contents := svc.request(1).Contents
+	if len(contents) != 1 || contents[0].Parts[0].GetText() != "Hi" {
+		t.Errorf("Expected only the new message in the next request, got %v", contents)
+	}
+}
+
+// TestChatHistoryDuringTurn tests the history can be read while a turn runs,
+// such as from ApproveToolCall
+func TestChatHistoryDuringTurn(t *testing.T) {
+	svc := &fakeChatService{responses: [][]*generativelanguagepb.Part{
+		{functionCallPart("echo", map[string]any{"text": "hi"})},
+		{TextPart("Done.")},
+	}}
+	var calls int
+	chat := NewChat(newFakeChatClient(t, svc), api.StreamClientConfig{ModelName: "models/test"}, newEchoTools(t, &calls))
+	var seen []*generativelanguagepb.Content
+	chat.ApproveToolCall = func(ctx context.Context, call ToolCall) (ToolCall, bool) {
+		got := make(chan []*generativelanguagepb.Content)
+		go func() { got <- chat.History() }()
+		select {
+		case seen = <-got:
+		case <-time.After(time.Second):
+			t.Error("History blocked during the turn")
+		}
+		return call, true
+	}
+
+	stream, err := chat.Send(context.Background(), TextPart("Echo hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectChatEvents(t, stream)
+	if len(seen) != 2 || seen[0].Parts[0].GetText() != "Echo hi" || seen[1].Parts[0].GetFunctionCall() == nil {
+		t.Errorf("Expected the message and the tool call in the history, got %v", seen)
+	}
+}
+
+// blockingLiveStream is a Live session whose Recv waits until it is closed.
+type blockingLiveStream struct {
+	grpc.ClientStream
+	closed chan struct{}
+}
+
+func (s *blockingLiveStream) Recv() (*generativelanguagepb.GenerateContentResponse, error) {
+	<-s.closed
+	return nil, io.EOF
+}
+
+func (s *blockingLiveStream) CloseSend() error {
+	close(s.closed)
+	return nil
+}
+
+// TestChatLiveCancelledTurn tests cancelling a Live turn stops a Recv
+// waiting for the server, ending the session
+func TestChatLiveCancelledTurn(t *testing.T) {
+	chat := NewChat(&api.Client{}, api.StreamClientConfig{ModelName: "models/gemini-2.0-flash-live-001", EnableWebSocket: true}, nil)
+	live := &blockingLiveStream{closed: make(chan struct{})}
+	chat.live, chat.liveCancel = live, func() {}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := chat.Send(ctx, TextPart("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	done := make(chan error)
+	go func() {
+		_, err := stream.Recv()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Cancelling the turn did not stop it")
+	}
+	if chat.live != nil {
+		t.Error("Expected the Live session ended")
+	}
+	if history := chat.History(); len(history) != 0 {
+		t.Errorf("Expected the cancelled turn dropped from the history, got %v", history)
+	}
+}
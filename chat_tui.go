@@ -0,0 +1,322 @@
+package aistudio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/tmc/aistudio/api"
+)
+
+// chatEventMsg carries the next event of a chat turn to the TUI.
+type chatEventMsg struct {
+	event ChatEvent
+}
+
+// chatDoneMsg ends a chat turn; err is nil when the turn completed.
+type chatDoneMsg struct {
+	err error
+}
+
+// chatApprovalMsg asks the TUI to approve a tool call made during a chat turn.
+// The reply is the call to run, which the user may have edited, or nil when
+// the call was denied.
+type chatApprovalMsg struct {
+	call  ToolCall
+	reply chan *ToolCall
+}
+
+// usesChat reports whether conversation turns go through the chat. Live
+// sessions do not: their audio, transcriptions and realtime input are handled
+// by the bidi stream receive loop.
+func (m *Model) usesChat() bool {
+	if !m.useBidi || m.bidiStream == nil {
+		return false
+	}
+	_, live := m.bidiStream.(*api.LiveStreamAdapter)
+	return !live
+}
+
+// chatConfig returns the stream configuration for the model's settings.
+func (m *Model) chatConfig() api.StreamClientConfig {
+	return api.StreamClientConfig{
+		ModelName:    m.modelName,
+		EnableAudio:  m.enableAudio,
+		VoiceName:    m.voiceName,
+		SystemPrompt: m.systemPrompt,
+		// Add generation parameters
+		Temperature:     m.temperature,
+		TopP:            m.topP,
+		TopK:            m.topK,
+		MaxOutputTokens: m.maxOutputTokens,
+		// Feature flags
+		EnableWebSocket:    m.enableWebSocket,
+		ResponseMimeType:   m.responseMimeType,
+		ResponseSchemaFile: m.responseSchemaFile,
+		SafetySettings:     m.safetySettings,
+		ThinkingBudget:     m.thinkingBudget,
+		IncludeThoughts:    m.includeThoughts,
+	}
+}
+
+// sendChatCmd sends text as the next chat turn and returns its first event.
+func (m *Model) sendChatCmd(text string) tea.Cmd {
+	log.Printf("Sending chat message: %s", text)
+	return m.chatTurnCmd(func(ctx context.Context) (*ChatStream, error) {
+		return m.chat.Send(ctx, TextPart(text))
+	})
+}
+
+// chatTurnCmd starts a chat turn with start and returns its first event.
+func (m *Model) chatTurnCmd(start func(ctx context.Context) (*ChatStream, error)) tea.Cmd {
+	return func() tea.Msg {
+		// Stop any currently playing audio
+		m.StopCurrentAudio()
+
+		// A new turn replaces one still in flight
+		if m.streamCtxCancel != nil {
+			m.streamCtxCancel()
+		}
+		if m.rootCtx == nil {
+			m.rootCtx, m.rootCtxCancel = context.WithCancel(context.Background())
+		}
+		m.streamCtx, m.streamCtxCancel = context.WithCancel(m.rootCtx)
+
+		if m.chat == nil {
+			var tools *ToolManager
+			if m.enableTools {
+				tools = m.toolManager
+			}
+			m.chat = NewChat(m.client, m.chatConfig(), tools)
+			m.chat.ResponseSchema = m.responseSchema
+			m.chat.ApproveToolCall = m.approveChatToolCall
+		}
+		m.chat.client = m.client // Replaced when reconnecting
+
+		stream, err := start(m.streamCtx)
+		if err != nil {
+			return sendErrorMsg{err: err}
+		}
+		m.chatStream = stream
+		return m.receiveChatCmd()()
+	}
+}
+
+// receiveChatCmd waits for the next event of the current chat turn.
+func (m *Model) receiveChatCmd() tea.Cmd {
+	stream := m.chatStream
+	return func() tea.Msg {
+		if stream == nil {
+			return chatDoneMsg{}
+		}
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return chatDoneMsg{}
+		}
+		if err != nil {
+			return chatDoneMsg{err: err}
+		}
+		return chatEventMsg{event: ev}
+	}
+}
+
+// approveChatToolCall asks the TUI to approve a tool call and waits for the
+// answer. It runs on the chat's goroutine.
+func (m *Model) approveChatToolCall(ctx context.Context, call ToolCall) (ToolCall, bool) {
+	reply := make(chan *ToolCall, 1)
+	select {
+	case m.uiUpdateChan <- chatApprovalMsg{call: call, reply: reply}:
+	case <-ctx.Done():
+		return call, false
+	}
+	select {
+	case approved := <-reply:
+		if approved == nil {
+			return call, false
+		}
+		return *approved, true
+	case <-ctx.Done():
+		return call, false
+	}
+}
+
+// handleChatApproval answers a chat approval request at once when no approval
+// is needed, and otherwise queues it in the tool approval modal.
+func (m *Model) handleChatApproval(msg chatApprovalMsg) {
+	if !m.requireApproval || m.approvedToolTypes[msg.call.Name] {
+		msg.reply <- &msg.call
+		return
+	}
+	if m.chatApprovals == nil {
+		m.chatApprovals = make(map[string]chan *ToolCall)
+	}
+	m.chatApprovals[msg.call.ID] = msg.reply
+	m.pendingToolCalls = append(m.pendingToolCalls, msg.call)
+	m.showToolApproval = true
+}
+
+// answerChatApproval delivers the user's decision on a tool call queued by
+// handleChatApproval, along with the call as the user left it. It returns
+// false for calls that did not come from the chat, which the approval modal
+// runs itself.
+func (m *Model) answerChatApproval(call ToolCall, approved bool) bool {
+	reply, ok := m.chatApprovals[call.ID]
+	if !ok {
+		return false
+	}
+	delete(m.chatApprovals, call.ID)
+	if approved {
+		reply <- &call
+	} else {
+		reply <- nil
+	}
+	return true
+}
+
+// advanceToolApproval moves the approval modal to the next pending call,
+// closing it after the last one and running the calls approved meanwhile.
+func (m *Model) advanceToolApproval() {
+	m.approvalIndex++
+	if m.approvalIndex >= len(m.pendingToolCalls) {
+		m.showToolApproval = false
+		m.pendingToolCalls = nil
+		m.approvalIndex = 0
+		m.runApprovedToolCalls() // tools.go
+	}
+}
+
+// currentChatAnswer returns the index of the model answer being streamed,
+// adding one if the turn has none since its last tool call.
+func (m *Model) currentChatAnswer() int {
+	if idx := len(m.messages) - 1; idx >= 0 && isAnswerMessage(m.messages[idx]) &&
+		m.messages[idx].BlockReason == "" {
+		return idx
+	}
+	m.messages = append(m.messages, formatMessage(senderNameModel, ""))
+	return len(m.messages) - 1
+}
+
+// handleChatEvent shows a chat event in the conversation and waits for the next.
+func (m *Model) handleChatEvent(msg chatEventMsg) tea.Cmd {
+	cmds := []tea.Cmd{m.receiveChatCmd()}
+
+	switch ev := msg.event.(type) {
+	case TextDeltaEvent:
+		m.currentState = AppStateResponding
+		idx := m.currentChatAnswer()
+		m.messages[idx].Content += ev.Text
+		m.messages[idx].Timestamp = time.Now()
+		m.attachRegeneratedAnswer() // generation_controls.go
+
+	case ThoughtEvent:
+		m.currentState = AppStateResponding
+		m.appendThought(ev.Text) // thoughts.go
+
+	case AudioEvent:
+		idx := m.currentChatAnswer()
+		m.messages[idx].HasAudio = true
+		m.messages[idx].AudioData = append(m.messages[idx].AudioData, ev.Data...)
+
+	case ToolCallEvent:
+		vm := m.getOrCreateToolVM(ev.Call.ID, func(vm *ToolCallViewModel) {
+			vm.Name = ev.Call.Name
+			vm.Arguments = ev.Call.Arguments
+			vm.Status = ToolCallStatusPending
+		})
+		m.toolFileEdit(ev.Call) // tool_diff.go
+		m.messages = append(m.messages, formatToolCallMessageFromViewModel(*vm))
+
+	case ToolStartEvent:
+		m.processingTool = true
+		vm := m.getOrCreateToolVM(ev.Call.ID, func(vm *ToolCallViewModel) {
+			vm.Status = ToolCallStatusRunning
+			vm.StartedAt = time.Now()
+		})
+		m.replaceToolCallMessage(*vm)
+
+	case ToolProgressEvent:
+		m.addToolProgress(ev.Call.ID, ev.Line)
+
+	case ToolResultEvent:
+		status := toolResultStatus(ev.Err)
+		if !ev.Approved {
+			status = ToolCallStatusRejected
+		}
+		vm := m.getOrCreateToolVM(ev.Call.ID, func(vm *ToolCallViewModel) {
+			vm.Status = status
+			vm.Result = ev.Result.Response
+			vm.Error = ev.Err
+		})
+		m.replaceToolCallMessage(*vm)
+		m.messages = append(m.messages, m.toolResultMessage(ev.Result, status)) // tool_diff.go
+		m.processingTool = m.hasRunningToolCalls()
+
+	case UsageEvent:
+		if idx := m.latestAnswerIndex(); idx >= 0 {
+			counts := ev.TokenCounts
+			m.messages[idx].TokenCounts = &counts
+			m.messages[idx].HasTokenInfo = true
+		}
+
+	case TurnCompleteEvent:
+		m.currentState = AppStateReady
+		if ev.BlockedReason != "" {
+			cmds = append(cmds, m.markBlocked(ev.BlockedReason, ev.SafetyRatings)) // safety.go
+		}
+		if idx := m.latestAnswerIndex(); idx >= 0 { // structured_output.go
+			if ev.GroundingMetadata != nil {
+				m.messages[idx].HasGroundingMetadata = true
+				m.messages[idx].GroundingMetadata = m.convertGroundingMetadata(ev.GroundingMetadata)
+			}
+			if len(ev.SafetyRatings) > 0 {
+				m.messages[idx].SafetyRatings = NewAPIFormatter().ConvertSafetyRatings(ev.SafetyRatings)
+			}
+			if m.historyEnabled && m.historyManager != nil {
+				m.historyManager.AddMessage(m.messages[idx])
+				cmds = append(cmds, m.saveSessionCmd())
+			}
+		}
+		cmds = append(cmds, m.checkStructuredOutput()) // structured_output.go
+	}
+
+	m.viewport.GotoBottom()
+	return tea.Batch(cmds...)
+}
+
+// handleChatDone ends a chat turn, reporting the error that ended it unless
+// the user stopped it.
+func (m *Model) handleChatDone(msg chatDoneMsg) {
+	m.chatStream = nil
+	m.processingTool = false
+	m.currentState = AppStateReady
+
+	// Approvals still queued for the turn can no longer be answered
+	if len(m.chatApprovals) > 0 {
+		var pending []ToolCall
+		for _, call := range m.pendingToolCalls {
+			if _, ok := m.chatApprovals[call.ID]; !ok {
+				pending = append(pending, call)
+			}
+		}
+		m.chatApprovals = nil
+		m.pendingToolCalls = pending
+		m.approvalIndex = 0
+		m.showToolApproval = len(pending) > 0
+	}
+
+	if msg.err == nil {
+		return
+	}
+	if m.stopRequested {
+		log.Printf("Chat turn stopped by the user: %v", msg.err)
+		m.stopRequested = false
+		return
+	}
+	m.err = fmt.Errorf("chat error: %w", msg.err)
+	m.messages = append(m.messages, formatError(m.err))
+	m.viewport.GotoBottom()
+}
@@ -0,0 +1,89 @@
+package aistudio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// citationInsert is a footnote marker to be placed at a byte offset.
+type citationInsert struct {
+	offset  int
+	markers string
+}
+
+// annotateCitations inserts numbered footnote markers such as "[1][3]" after
+// each text span that a grounding support cites. Numbers follow the order of
+// the grounding chunks so they match the sources list. When a segment's
+// offsets don't line up with text (for example after edits to the content),
+// the segment text is located by search instead; unmatched segments are
+// skipped.
+func annotateCitations(text string, gm *GroundingMetadata) string {
+	if gm == nil || len(gm.Supports) == 0 || text == "" {
+		return text
+	}
+
+	var inserts []citationInsert
+	for _, support := range gm.Supports {
+		var nums []string
+		for _, idx := range support.ChunkIndices {
+			if idx >= 0 && idx < len(gm.Chunks) {
+				nums = append(nums, fmt.Sprintf("[%d]", idx+1))
+			}
+		}
+		if len(nums) == 0 {
+			continue
+		}
+
+		end := -1
+		switch {
+		case support.EndIndex > support.StartIndex && support.EndIndex <= len(text) &&
+			(support.Text == "" || text[support.StartIndex:support.EndIndex] == support.Text):
+			end = support.EndIndex
+		case support.Text != "":
+			if i := strings.Index(text, support.Text); i >= 0 {
+				end = i + len(support.Text)
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		// Byte offsets from the API may fall inside a character
+		for end < len(text) && end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		inserts = append(inserts, citationInsert{offset: end, markers: strings.Join(nums, "")})
+	}
+	if len(inserts) == 0 {
+		return text
+	}
+
+	// Insert from the end so earlier offsets stay valid
+	sort.SliceStable(inserts, func(i, j int) bool { return inserts[i].offset > inserts[j].offset })
+	for _, ins := range inserts {
+		text = text[:ins.offset] + ins.markers + text[ins.offset:]
+	}
+	return text
+}
+
+// citationSources returns one line per grounding chunk, numbered to match the
+// footnote markers, e.g. "[1] Go Blog - https://go.dev/blog".
+func citationSources(gm *GroundingMetadata) []string {
+	if gm == nil {
+		return nil
+	}
+	var lines []string
+	for i, chunk := range gm.Chunks {
+		title := chunk.Title
+		if title == "" {
+			title = chunk.URI
+		}
+		line := fmt.Sprintf("[%d] %s", i+1, title)
+		if chunk.URI != "" && chunk.URI != title {
+			line += " - " + chunk.URI
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testGroundingMetadata() *GroundingMetadata {
+	return &GroundingMetadata{
+		Chunks: []*GroundingChunk{
+			{Title: "go.dev", URI: "https://go.dev/doc", IsWeb: true},
+			{Title: "", URI: "https://example.com/spec", IsWeb: true},
+		},
+		Supports: []*GroundingSupport{
+			{Text: "Go 1.22 changed loop variables.", StartIndex: 0, EndIndex: 31, ChunkIndices: []int{0}},
+			{Text: "Each iteration has its own variable.", ChunkIndices: []int{0, 1}},
+		},
+		WebSearchQueries: []string{"go 1.22 loopvar"},
+	}
+}
+
+// TestAnnotateCitations tests footnote markers at cited spans
+func TestAnnotateCitations(t *testing.T) {
+	text := "Go 1.22 changed loop variables. Each iteration has its own variable. Done."
+	got := annotateCitations(text, testGroundingMetadata())
+	want := "Go 1.22 changed loop variables.[1] Each iteration has its own variable.[1][2] Done."
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := annotateCitations("unrelated text", testGroundingMetadata()); got != "unrelated text" {
+		t.Errorf("Expected unmatched segments to be skipped, got %q", got)
+	}
+
+	// An offset inside a character moves back to the character's start
+	gm := &GroundingMetadata{
+		Chunks:   []*GroundingChunk{{URI: "https://example.com/cafe"}},
+		Supports: []*GroundingSupport{{StartIndex: 0, EndIndex: 4, ChunkIndices: []int{0}}},
+	}
+	if got := annotateCitations("café au lait", gm); got != "caf[1]é au lait" {
+		t.Errorf("Expected the marker before the split character, got %q", got)
+	}
+
+	sources := citationSources(testGroundingMetadata())
+	if len(sources) != 2 || sources[0] != "[1] go.dev - https://go.dev/doc" || sources[1] != "[2] https://example.com/spec" {
+		t.Errorf("Unexpected sources: %q", sources)
+	}
+}
+
+// TestExportSessionGrounding tests that citations survive saving and exporting
+func TestExportSessionGrounding(t *testing.T) {
+	answer := formatMessage(senderNameModel, "Go 1.22 changed loop variables.")
+	answer.HasGroundingMetadata = true
+	answer.GroundingMetadata = testGroundingMetadata()
+	session := &ChatSession{
+		ID:        "session_test",
+		Title:     "Loop vars",
+		CreatedAt: time.Now(),
+		Messages:  []Message{formatMessage(senderNameUser, "What changed?"), answer},
+	}
+
+	data, err := ExportSession(session, "json")
+	if err != nil {
+		t.Fatalf("JSON export failed: %v", err)
+	}
+	var loaded ChatSession
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Failed to reload session: %v", err)
+	}
+	gm := loaded.Messages[1].GroundingMetadata
+	if gm == nil || len(gm.Supports) != 2 || gm.Supports[0].EndIndex != 31 {
+		t.Fatalf("Grounding metadata not persisted: %+v", gm)
+	}
+
+	md, err := ExportSession(&loaded, "markdown")
+	if err != nil {
+		t.Fatalf("Markdown export failed: %v", err)
+	}
+	for _, want := range []string{"loop variables.[1]", "- [1] go.dev - https://go.dev/doc", "*Searched: go 1.22 loopvar*"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Markdown export missing %q:\n%s", want, md)
+		}
+	}
+	if n := strings.Count(string(md), "go.dev/doc"); n != 1 {
+		t.Errorf("Expected the source listed once, got %d times:\n%s", n, md)
+	}
+}
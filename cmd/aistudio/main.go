@@ -8,6 +8,7 @@ import (
 	"net/http"
 	_ "net/http/pprof" // Register pprof handlers
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
@@ -16,6 +17,8 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/tmc/aistudio" // Adjust import path if necessary
+	"github.com/tmc/aistudio/api"
+	"github.com/tmc/aistudio/prompts"
 )
 
 // setupLogging directs log output to a file for easier debugging.
@@ -31,10 +34,88 @@ func setupLogging() *os.File {
 	// fmt print a line clear ansi code:
 	//fmt.Fprintf(os.Stderr, "Logging enabled: %s\n", logFilePath) // Inform user where logs are
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile) // Add timestamp and file info to logs
-	log.SetOutput(f)                                     // Redirect standard logger
+	log.SetOutput(api.NewRedactingWriter(f))             // Redirect standard logger, redacting API keys
 	return f
 }
 
+// apiKeySourceOptions returns options that obtain API keys from a command or
+// the OS keyring instead of flags or environment variables.
+func apiKeySourceOptions(geminiCommand, grokCommand string, keyring bool) []aistudio.Option {
+	var opts []aistudio.Option
+	if geminiCommand != "" {
+		opts = append(opts,
+			aistudio.WithAPIKeyCommand(aistudio.BackendGeminiAPI, geminiCommand),
+			aistudio.WithAPIKeyCommand(aistudio.BackendVertexAI, geminiCommand))
+	}
+	if grokCommand != "" {
+		opts = append(opts, aistudio.WithAPIKeyCommand(aistudio.BackendGrok, grokCommand))
+	}
+	if keyring {
+		opts = append(opts,
+			aistudio.WithAPIKeyFromKeyring(aistudio.BackendGeminiAPI, "", ""),
+			aistudio.WithAPIKeyFromKeyring(aistudio.BackendVertexAI, "", "gemini"),
+			aistudio.WithAPIKeyFromKeyring(aistudio.BackendGrok, "", ""))
+	}
+	return opts
+}
+
+// stringSliceFlag collects the values of a repeatable flag.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// renderTemplate loads the named template from the prompt directories and
+// fills in its variables from name=value pairs.
+func renderTemplate(dirs []string, name string, pairs []string) (*prompts.Template, string, error) {
+	lib, err := prompts.LoadLibrary(dirs...)
+	if err != nil {
+		return nil, "", err
+	}
+	tmpl, ok := lib.Get(name)
+	if !ok {
+		return nil, "", fmt.Errorf("prompt template %q not found", name)
+	}
+	vars, err := prompts.ParseVars(pairs)
+	if err != nil {
+		return nil, "", err
+	}
+	text, err := tmpl.Render(vars)
+	if err != nil {
+		return nil, "", err
+	}
+	return tmpl, text, nil
+}
+
+// exportSession writes a saved chat session to stdout. ref is either a path
+// to a session file or a session ID in historyDir.
+func exportSession(historyDir, ref, format string) error {
+	path := ref
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(historyDir, strings.TrimSuffix(ref, ".json")+".json")
+	}
+	hm, err := aistudio.NewHistoryManager(historyDir)
+	if err != nil {
+		return err
+	}
+	session, err := hm.LoadSessionFromFile(path)
+	if err != nil {
+		return err
+	}
+	data, err := aistudio.ExportSession(session, format)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
 func main() {
 	// [DEBUG] Main function started
 
@@ -44,6 +125,8 @@ func main() {
 	voiceFlag := flag.String("voice", aistudio.DefaultVoice, "Voice for audio output (e.g., Puck, Amber).")
 	playerCmdFlag := flag.String("player", "", "Override command for audio playback (e.g., 'ffplay ...'). Auto-detected if empty.")
 	apiKeyFlag := flag.String("api-key", "", "Gemini API Key (overrides GEMINI_API_KEY env var).")
+	apiKeyCommandFlag := flag.String("api-key-command", "", "Command that prints the Gemini API key, e.g. 'pass show gemini' (overrides AISTUDIO_API_KEY_COMMAND env var).")
+	keyringFlag := flag.Bool("keyring", false, "Look up API keys in the OS keyring (service 'aistudio', account 'gemini' or 'grok').")
 
 	// Vertex AI flags
 	vertexFlag := flag.Bool("vertex", false, "Use Vertex AI instead of Gemini API.")
@@ -53,6 +136,7 @@ func main() {
 	// Grok AI flags
 	grokFlag := flag.Bool("grok", false, "Use xAI Grok API.")
 	grokAPIKeyFlag := flag.String("grok-api-key", "", "Grok API Key (overrides GROK_API_KEY env var).")
+	grokAPIKeyCommandFlag := flag.String("grok-api-key-command", "", "Command that prints the Grok API key (overrides AISTUDIO_GROK_API_KEY_COMMAND env var).")
 
 	// Gemini API version flag
 	geminiVersionFlag := flag.String("gemini-version", "v1beta", "Gemini API version to use: 'v1alpha' or 'v1beta'.")
@@ -60,13 +144,37 @@ func main() {
 	// WebSocket mode flag
 	webSocketFlag := flag.Bool("ws", false, "Enable WebSocket mode for live models (disabled by default, uses gRPC).")
 
+	// Live session context window compression
+	contextCompressionFlag := flag.Bool("context-compression", false, "Compress the context of long live sessions with a sliding window instead of ending them at the context limit.")
+	compressionTriggerFlag := flag.Int64("compression-trigger-tokens", 0, "Context size in tokens that triggers compression (0 = server default).")
+	compressionTargetFlag := flag.Int64("compression-target-tokens", 0, "Context size in tokens to compress down to (0 = server default).")
+
 	// New flags for history and tools
 	historyFlag := flag.Bool("history", true, "Enable chat history.")
 	historyDirFlag := flag.String("history-dir", "./history", "Directory for storing chat history.")
+	exportSessionFlag := flag.String("export-session", "", "Export a saved session (ID or file path) to stdout and exit.")
+	exportFormatFlag := flag.String("export-format", "markdown", "Format for --export-session: markdown or json.")
 	toolsFlag := flag.Bool("tools", true, "Enable tool calling support.")
 	toolsFileFlag := flag.String("tools-file", "", "JSON file containing tool definitions to load.")
+	discoverToolsFlag := flag.Bool("discover-tools", true, "Register aistudio-tool-* executables found in --tools-dir and on PATH.")
+	toolsDirFlag := flag.String("tools-dir", aistudio.DefaultToolsDir(), "Directory searched for aistudio-tool-* executables before PATH.")
+	toolConcurrencyFlag := flag.Int("tool-concurrency", 4, "Maximum number of tool calls from one turn run at once.")
+	toolResultLimitFlag := flag.Int("tool-result-limit", aistudio.DefaultToolResultLimit, "Tool results larger than this many bytes are stored on disk and the model sent a preview (0 to send results whole; otherwise at least 4096).")
+	workspaceFlag := flag.String("workspace", ".", "Directory the built-in file tools may read and write.")
+	webFetchFlag := flag.Bool("web-fetch", true, "Enable the web_fetch tool.")
+	var webFetchDomains stringSliceFlag
+	flag.Var(&webFetchDomains, "web-fetch-domain", "Domain web_fetch may fetch, with its subdomains (repeatable; default: any public host).")
+	agentFlag := flag.Bool("agent", true, "Enable the dispatch_agent tool, which hands tasks to a sub-agent with read-only tools.")
+	agentModelFlag := flag.String("agent-model", "", "Model used by dispatch_agent sub-agents (default: the conversation's model, or the default model if that is a Live model).")
+	agentMaxStepsFlag := flag.Int("agent-max-steps", 10, "Maximum model requests a dispatch_agent sub-agent may make.")
 	systemPromptFlag := flag.String("system-prompt", "", "System prompt to use for the conversation.")
 	systemPromptFileFlag := flag.String("system-prompt-file", "", "Load system prompt from a file.")
+	promptFileFlag := flag.String("prompt-file", "", "Load the initial message draft from a file.")
+	promptsDirFlag := flag.String("prompts-dir", "", "Directory of prompt templates, in addition to the user library in the config directory.")
+	templateFlag := flag.String("template", "", "Prompt template to fill the draft with (sent immediately by the run command).")
+	var templateVars stringSliceFlag
+	flag.Var(&templateVars, "var", "Template variable as name=value (repeatable).")
+	inputHistoryFlag := flag.String("input-history", "", "File for persisted input history recalled with up/down (default: input_history.jsonl in --history-dir).")
 	listModelsFlag := flag.Bool("list-models", false, "List available models and exit.")
 	filterModelsFlag := flag.String("filter-models", "", "Filter models list (used with --list-models)")
 	apiVersionsFlag := flag.String("api-versions", "beta", "API versions to query when listing models (comma-separated): alpha,beta,v1")
@@ -76,6 +184,8 @@ func main() {
 	topPFlag := flag.Float64("top-p", 0.95, "Top-p value for text generation (0.0-1.0).")
 	topKFlag := flag.Int("top-k", 40, "Top-k value for text generation.")
 	maxOutputTokensFlag := flag.Int("max-output-tokens", 8192, "Maximum number of tokens to generate.")
+	thinkingBudgetFlag := flag.Int("thinking-budget", -1, "Thinking token budget for 2.5 models; 0 disables thinking and -1 lets the model decide (default: the model's own setting).")
+	thoughtsFlag := flag.Bool("thoughts", false, "Show summaries of the model's thinking above each answer (Ctrl+Y expands them).")
 
 	// Feature flags
 	webSearchFlag := flag.Bool("web-search", false, "Enable web search capabilities.")
@@ -83,6 +193,10 @@ func main() {
 	displayTokensFlag := flag.Bool("display-tokens", false, "Display token counts in the UI.")
 	responseMimeTypeFlag := flag.String("response-mime-type", "", "Expected response MIME type (e.g., application/json).")
 	responseSchemaFileFlag := flag.String("response-schema-file", "", "Path to JSON schema file defining response structure.")
+	schemaRetriesFlag := flag.Int("schema-retries", 0, "Times to send a response that fails --response-schema-file validation back to the model to be fixed.")
+	var safetyFlags stringSliceFlag
+	flag.Var(&safetyFlags, "safety", "Block threshold for a harm category as category=threshold, e.g. harassment=none (repeatable). Thresholds: none, low, medium, high, off.")
+	safetyFileFlag := flag.String("safety-file", "", "JSON file mapping harm categories to block thresholds, e.g. {\"dangerous\": \"high\"}. --safety entries override it.")
 	globalTimeoutFlag := flag.Duration("global-timeout", 0, "Global timeout for all API requests (e.g., 30s, 1m). Zero means no timeout.")
 	autoSendFlag := flag.String("auto-send", "", "Auto-send a test message after specified delay (e.g., 3s, 5s). Useful for testing.")
 	toolApprovalFlag := flag.Bool("tool-approval", true, "Require user approval for tool calls.")
@@ -101,6 +215,7 @@ func main() {
 	audioCaptureDeviceFlag := flag.String("audio-device", "default", "Audio input device to use.")
 	screenCaptureIntervalFlag := flag.Duration("capture-interval", 2*time.Second, "Screen capture interval (e.g., 2s, 5s).")
 	audioVADFlag := flag.Bool("audio-vad", true, "Enable voice activity detection for audio input.")
+	pushToTalkFlag := flag.Bool("push-to-talk", false, "Send live audio only while talking; toggle with Ctrl+Space (disables server voice activity detection).")
 	captureQualityFlag := flag.Int("capture-quality", 80, "Screen capture quality (0-100).")
 
 	// Profiling flags (all with pprof- prefix)
@@ -115,6 +230,12 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nEnvironment Variables:\n")
 		fmt.Fprintf(os.Stderr, "  GEMINI_API_KEY: API Key (used if --api-key is not set and --vertex is not enabled).\n")
+		fmt.Fprintf(os.Stderr, "  AISTUDIO_API_KEY_COMMAND: Command that prints the Gemini API key (used if --api-key-command is not set).\n")
+		fmt.Fprintf(os.Stderr, "  AISTUDIO_GROK_API_KEY_COMMAND: Command that prints the Grok API key.\n")
+		fmt.Fprintf(os.Stderr, "\nAPI Key Examples:\n")
+		fmt.Fprintf(os.Stderr, "  From a password manager: %s --api-key-command='pass show gemini'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  From the OS keyring:     %s --keyring\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "                           # secret-tool store --label=aistudio service aistudio account gemini\n")
 		fmt.Fprintf(os.Stderr, "\nVertex AI Examples:\n")
 		fmt.Fprintf(os.Stderr, "  Using Vertex AI: %s --vertex --project-id=your-project-id\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  List Vertex models: %s --vertex --project-id=your-project-id --list-models\n", os.Args[0])
@@ -124,13 +245,35 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nAuto-send Examples:\n")
 		fmt.Fprintf(os.Stderr, "  Auto-send test message after 5s: %s --auto-send=5s\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Auto-send with debugging: %s --auto-send=3s --global-timeout=30s\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nComposing Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Start from a draft: %s --prompt-file=prompt.md\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Ctrl+X opens the current draft in $VISUAL or $EDITOR; Up/Down recall earlier prompts.\n")
+		fmt.Fprintf(os.Stderr, "\nPrompt Template Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Run a template: %s run --template code-review --var language=Go --var diff=\"$(git diff)\"\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Run a prompt:   %s run \"Explain goroutines\"\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Ctrl+L opens the template picker. Templates live in %s.\n", prompts.DefaultDir())
+		fmt.Fprintf(os.Stderr, "\nStructured Output Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Validated JSON to stdout: %s run --response-schema-file=schema.json --schema-retries=2 \"List three colors\" | jq .\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nSession Export Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Markdown with citations: %s --export-session=session_1745553340 > chat.md\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Full JSON:               %s --export-session=history/session_1745553340.json --export-format=json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nThinking Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Show thought summaries: %s --thoughts --thinking-budget=2048\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Answer without thinking: %s --thinking-budget=0\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Let the model decide how long to think: %s --thinking-budget=-1\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nSafety Settings Examples:\n")
+		fmt.Fprintf(os.Stderr, "  Relax one category: %s --safety=harassment=none --safety=dangerous=high\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  From a file:        %s --safety-file=safety.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Categories: harassment, hate, sexual, dangerous, civic (or the HARM_CATEGORY_* names).\n")
 		fmt.Fprintf(os.Stderr, "\nStdin Mode Examples:\n")
 		fmt.Fprintf(os.Stderr, "  Interactive: cat | %s --stdin\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Piped: echo \"Hello\" | %s --stdin\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nMultimodal Streaming Examples:\n")
 		fmt.Fprintf(os.Stderr, "  Full multimodal: %s --multimodal --model=models/gemini-2.0-flash-live-001\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Audio input only: %s --audio-input --audio-device=default\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Push-to-talk: %s --audio-input --push-to-talk  (Ctrl+Space starts and ends each utterance)\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Screen capture only: %s --screen-capture --capture-interval=5s\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Long sessions: %s --multimodal --context-compression --compression-trigger-tokens=25600\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Custom quality: %s --multimodal --capture-quality=60\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Capture specific window: %s --screen-capture --capture-window=\"Safari\"\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Capture by process: %s --multimodal --capture-process=\"Google Chrome\"\n", os.Args[0])
@@ -151,7 +294,32 @@ func main() {
 		fmt.Fprintf(os.Stderr, "                  # Then visit http://localhost:6060/debug/pprof/\n")
 	}
 
-	flag.Parse()
+	// The run subcommand sends a single prompt and exits
+	runCommand := len(os.Args) > 1 && os.Args[1] == "run"
+	if runCommand {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	// API key commands: Flag > Env Var
+	apiKeyCommand := *apiKeyCommandFlag
+	if apiKeyCommand == "" {
+		apiKeyCommand = os.Getenv(aistudio.EnvAPIKeyCommand)
+	}
+	grokAPIKeyCommand := *grokAPIKeyCommandFlag
+	if grokAPIKeyCommand == "" {
+		grokAPIKeyCommand = os.Getenv(aistudio.EnvGrokAPIKeyCommand)
+	}
+	keySourceOpts := apiKeySourceOptions(apiKeyCommand, grokAPIKeyCommand, *keyringFlag)
+
+	if *exportSessionFlag != "" {
+		if err := exportSession(*historyDirFlag, *exportSessionFlag, *exportFormatFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// --- Set up logging first ---
 	logFile := setupLogging()
@@ -172,16 +340,16 @@ func main() {
 
 		if !*vertexFlag && !*grokFlag && apiKey == "" {
 			apiKey = os.Getenv("GEMINI_API_KEY")
-			if apiKey == "" {
+			if apiKey == "" && apiKeyCommand == "" && !*keyringFlag {
 				fmt.Fprintln(os.Stderr, "Warning: No API key provided for listing models. Some models might not be visible.")
 			}
 		}
 
 		if *grokFlag && grokAPIKey == "" {
 			grokAPIKey = os.Getenv("GROK_API_KEY")
-			if grokAPIKey == "" {
+			if grokAPIKey == "" && grokAPIKeyCommand == "" && !*keyringFlag {
 				fmt.Fprintln(os.Stderr, "Error: Grok API key is required when using --grok.")
-				fmt.Fprintln(os.Stderr, "Specify with --grok-api-key flag or set GROK_API_KEY environment variable.")
+				fmt.Fprintln(os.Stderr, "Specify with --grok-api-key, --grok-api-key-command or --keyring, or set GROK_API_KEY environment variable.")
 				os.Exit(1)
 			}
 		}
@@ -199,7 +367,7 @@ func main() {
 		}
 
 		// Create options for the model
-		var opts []aistudio.Option
+		opts := append([]aistudio.Option{}, keySourceOpts...)
 
 		// Check environment variables for backend configuration
 		envUseVertexAI := os.Getenv(aistudio.EnvUseVertexAI) == "true"
@@ -355,7 +523,9 @@ func main() {
 	apiKey := *apiKeyFlag
 	if apiKey == "" {
 		apiKey = os.Getenv("GEMINI_API_KEY")
-		if apiKey == "" {
+		if apiKey == "" && (apiKeyCommand != "" || *keyringFlag) {
+			log.Println("API Key will be resolved from api-key-command or the OS keyring.")
+		} else if apiKey == "" {
 			fmt.Fprintln(os.Stderr, "INFO: No API key provided via --api-key flag or GEMINI_API_KEY env var. Attempting Application Default Credentials (ADC).")
 			log.Println("API Key not provided via flag or env, attempting ADC.")
 		} else {
@@ -389,7 +559,16 @@ func main() {
 		aistudio.WithAutoSend(*autoSendFlag),
 		aistudio.WithToolApproval(*toolApprovalFlag),
 		aistudio.WithBidiStreaming(*bidiStreamingFlag),
+		aistudio.WithPromptFile(*promptFileFlag),
+	}
+	opts = append(opts, keySourceOpts...)
+
+	// Input history is shared across sessions; keep it alongside chat history by default
+	inputHistoryPath := *inputHistoryFlag
+	if inputHistoryPath == "" && *historyFlag {
+		inputHistoryPath = filepath.Join(*historyDirFlag, "input_history.jsonl")
 	}
+	opts = append(opts, aistudio.WithInputHistory(inputHistoryPath))
 
 	// Configure based on environment variables first, then command-line flags
 	// Check environment variables for backend configuration
@@ -418,9 +597,9 @@ func main() {
 
 	// Configure based on the determined settings
 	if useGrok {
-		if grokAPIKey == "" {
+		if grokAPIKey == "" && grokAPIKeyCommand == "" && !*keyringFlag {
 			fmt.Fprintln(os.Stderr, "Error: Grok API key is required when using --grok.")
-			fmt.Fprintln(os.Stderr, "Specify with --grok-api-key flag or set GROK_API_KEY environment variable.")
+			fmt.Fprintln(os.Stderr, "Specify with --grok-api-key, --grok-api-key-command or --keyring, or set GROK_API_KEY environment variable.")
 			os.Exit(1)
 		}
 		log.Printf("Using Grok API")
@@ -456,11 +635,23 @@ func main() {
 		opts = append(opts, aistudio.WithSystemPrompt(systemPrompt))
 	}
 
+	// Register the built-in file tools before the tools file so its definitions win
+	opts = append(opts, aistudio.WithWorkspace(*workspaceFlag))
+	if *webFetchFlag {
+		opts = append(opts, aistudio.WithWebFetch(webFetchDomains))
+	}
 	// Add tools file if specified
 	if *toolsFileFlag != "" {
 		opts = append(opts, aistudio.WithToolsFile(*toolsFileFlag))
 	}
 
+	// Discover tool executables after the tools file so its definitions win
+	if *discoverToolsFlag {
+		opts = append(opts, aistudio.WithToolDiscovery(*toolsDirFlag))
+	}
+	opts = append(opts, aistudio.WithToolConcurrency(*toolConcurrencyFlag))
+	opts = append(opts, aistudio.WithToolResultLimit(*toolResultLimitFlag))
+
 	if *playerCmdFlag != "" {
 		opts = append(opts, aistudio.WithAudioPlayerCommand(*playerCmdFlag))
 	}
@@ -470,12 +661,21 @@ func main() {
 	opts = append(opts, aistudio.WithTopP(float32(*topPFlag)))
 	opts = append(opts, aistudio.WithTopK(int32(*topKFlag)))
 	opts = append(opts, aistudio.WithMaxOutputTokens(int32(*maxOutputTokensFlag)))
+	// The default of -1 is also a valid budget (dynamic thinking), so only
+	// a budget given on the command line is sent
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "thinking-budget" {
+			opts = append(opts, aistudio.WithThinkingBudget(int32(*thinkingBudgetFlag)))
+		}
+	})
+	opts = append(opts, aistudio.WithThoughts(*thoughtsFlag))
 
 	// Add feature flags
 	opts = append(opts, aistudio.WithWebSearch(*webSearchFlag))
 	opts = append(opts, aistudio.WithCodeExecution(*codeExecutionFlag))
 	opts = append(opts, aistudio.WithDisplayTokenCounts(*displayTokensFlag))
 	opts = append(opts, aistudio.WithWebSocket(*webSocketFlag))
+	opts = append(opts, aistudio.WithContextCompression(*contextCompressionFlag, *compressionTriggerFlag, *compressionTargetFlag))
 
 	// Add multimodal streaming configuration
 	if *multimodalFlag || *audioInputFlag || *screenCaptureFlag {
@@ -490,7 +690,7 @@ func main() {
 			multimodalConfig.EnableAudio = true
 			multimodalConfig.AudioConfig.InputDevice = *audioCaptureDeviceFlag
 			multimodalConfig.AudioConfig.EnableVAD = *audioVADFlag
-			log.Printf("Audio input enabled: device=%s, VAD=%v", *audioCaptureDeviceFlag, *audioVADFlag)
+			log.Printf("Audio input enabled: device=%s, VAD=%v, push-to-talk=%v", *audioCaptureDeviceFlag, *audioVADFlag, *pushToTalkFlag)
 		}
 		
 		// Configure screen capture
@@ -513,6 +713,7 @@ func main() {
 		
 		// Apply multimodal configuration
 		opts = append(opts, aistudio.WithMultimodalConfig(multimodalConfig))
+		opts = append(opts, aistudio.WithPushToTalk(*pushToTalkFlag))
 	}
 
 	// Add response configuration if specified
@@ -522,6 +723,58 @@ func main() {
 
 	if *responseSchemaFileFlag != "" {
 		opts = append(opts, aistudio.WithResponseSchema(*responseSchemaFileFlag))
+		opts = append(opts, aistudio.WithSchemaRetries(*schemaRetriesFlag))
+	}
+
+	// Safety settings from the file come first so --safety entries override them
+	if *safetyFileFlag != "" {
+		settings, err := api.LoadSafetySettings(*safetyFileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, aistudio.WithSafetySettings(settings...))
+	}
+	for _, entry := range safetyFlags {
+		setting, err := api.ParseSafetySetting(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, aistudio.WithSafetySettings(setting))
+	}
+
+	// Load prompt templates; a chosen template may override the model and temperature
+	promptDirs := []string{prompts.DefaultDir(), *promptsDirFlag}
+	opts = append(opts, aistudio.WithPromptLibrary(promptDirs...))
+	var prompt string
+	if *templateFlag != "" {
+		tmpl, text, err := renderTemplate(promptDirs, *templateFlag, templateVars)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if tmpl.Model != "" {
+			opts = append(opts, aistudio.WithModel(tmpl.Model))
+		}
+		if tmpl.Temperature != nil {
+			opts = append(opts, aistudio.WithTemperature(*tmpl.Temperature))
+		}
+		prompt = text
+		if !runCommand {
+			opts = append(opts, aistudio.WithInitialDraft(prompt))
+		}
+	} else if runCommand {
+		prompt = strings.Join(flag.Args(), " ")
+	}
+	if runCommand && strings.TrimSpace(prompt) == "" {
+		fmt.Fprintln(os.Stderr, "Error: run requires --template or a prompt argument.")
+		os.Exit(1)
+	}
+
+	// The sub-agent takes the settings above, so its tool is registered last
+	if *agentFlag {
+		opts = append(opts, aistudio.WithAgentTool(*agentModelFlag, *agentMaxStepsFlag))
 	}
 
 	// --- Initialize Component ---
@@ -537,8 +790,14 @@ func main() {
 		}
 	}()
 
-	// Handle stdin mode if flag is set
-	if *stdinModeFlag {
+	if runCommand {
+		// Send a single prompt and print the response
+		if err := component.RunPrompt(nil, prompt, os.Stdout); err != nil {
+			log.Printf("Error in run command: %v", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *stdinModeFlag {
 		// In stdin mode, audio is disabled
 		*audioFlag = false
 
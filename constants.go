@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tmc/aistudio/api"
 )
 
 // --- Config ---
@@ -49,6 +50,18 @@ func (b BackendType) String() string {
 	}
 }
 
+// apiBackend maps the backend type to its api package equivalent.
+func (b BackendType) apiBackend() api.Backend {
+	switch b {
+	case BackendVertexAI:
+		return api.BackendVertexAI
+	case BackendGrok:
+		return api.BackendGrok
+	default:
+		return api.BackendGeminiAPI
+	}
+}
+
 // Environment variable names for configuration.
 const (
 	EnvGeminiAPIKey     = "GEMINI_API_KEY"
@@ -59,6 +72,9 @@ const (
 	EnvVertexAILocation = "AISTUDIO_VERTEXAI_LOCATION"
 	EnvDefaultModel     = "AISTUDIO_DEFAULT_MODEL"
 	EnvDefaultVoice     = "AISTUDIO_DEFAULT_VOICE"
+	// API key command environment variables (e.g. "pass show gemini")
+	EnvAPIKeyCommand     = "AISTUDIO_API_KEY_COMMAND"
+	EnvGrokAPIKeyCommand = "AISTUDIO_GROK_API_KEY_COMMAND"
 	// Debug environment variables
 	EnvDebugConnection   = "AISTUDIO_DEBUG_CONNECTION"
 	EnvDebugStream       = "AISTUDIO_DEBUG_STREAM"
@@ -113,6 +129,10 @@ const (
 	senderNameUser   senderName = "You"
 	senderNameModel  senderName = "Gemini"
 	senderNameSystem senderName = "System"
+
+	// Transcripts of Live API audio
+	senderNameUserSpoken  senderName = "You (spoken)"
+	senderNameModelSpoken senderName = "Gemini (spoken)"
 )
 
 // DefaultModelType is the default model type (e.g., Chat, Audio, etc.)
@@ -193,3 +213,11 @@ var (
 				BorderForeground(lipgloss.Color("240")).
 				Padding(0, 1)
 )
+
+// Styles for the diffs of file-editing tool calls
+var (
+	diffHeaderStyle = lipgloss.NewStyle().Bold(true)
+	diffHunkStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))  // Teal
+	diffAddStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("40"))  // Green
+	diffRemoveStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196")) // Red
+)
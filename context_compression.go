@@ -0,0 +1,53 @@
+package aistudio
+
+import (
+	"fmt"
+	"log"
+)
+
+// formatTokenCount abbreviates token counts for the status line, e.g. "12.3k".
+func formatTokenCount(n int64) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+// renderContextUsage describes the live session's token usage and, when
+// context compression is on, the point at which old turns are dropped.
+func (m *Model) renderContextUsage() string {
+	if m.sessionTokenCount <= 0 {
+		return ""
+	}
+	usage := fmt.Sprintf("Context: %s tokens", formatTokenCount(int64(m.sessionTokenCount)))
+	switch {
+	case m.contextCompression && m.compressionTriggerTokens > 0:
+		usage += fmt.Sprintf(" (compresses at %s)", formatTokenCount(m.compressionTriggerTokens))
+	case m.contextCompression:
+		usage += " (compression on)"
+	}
+	return usage
+}
+
+// applyCompressionSettings copies compression changes made in the settings
+// panel to the model. The setup message is only sent when a live session
+// connects, so changes take effect from the next session.
+func (m *Model) applyCompressionSettings() {
+	p := m.settingsPanel
+	if p.ContextCompression == m.contextCompression && p.CompressionTriggerTokens == m.compressionTriggerTokens {
+		return
+	}
+	m.contextCompression = p.ContextCompression
+	m.compressionTriggerTokens = p.CompressionTriggerTokens
+	if m.compressionTargetTokens >= m.compressionTriggerTokens {
+		m.compressionTargetTokens = 0 // Let the server pick a target below the new trigger
+	}
+	log.Printf("Context compression: enabled=%t trigger=%d target=%d",
+		m.contextCompression, m.compressionTriggerTokens, m.compressionTargetTokens)
+	m.multimodalConfig.ContextWindowCompression = m.contextCompression
+	m.multimodalConfig.CompressionTriggerTokens = m.compressionTriggerTokens
+	m.multimodalConfig.CompressionTargetTokens = m.compressionTargetTokens
+	if m.multimodalManager != nil {
+		m.multimodalManager.SetContextCompression(m.contextCompression, m.compressionTriggerTokens, m.compressionTargetTokens)
+	}
+}
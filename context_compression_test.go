@@ -0,0 +1,47 @@
+package aistudio
+
+import (
+	"testing"
+
+	"github.com/tmc/aistudio/settings"
+)
+
+// TestRenderContextUsage tests the status line token usage summary
+func TestRenderContextUsage(t *testing.T) {
+	m := &Model{}
+	if got := m.renderContextUsage(); got != "" {
+		t.Errorf("Expected no usage before the first report, got %q", got)
+	}
+
+	m.sessionTokenCount = 950
+	if got := m.renderContextUsage(); got != "Context: 950 tokens" {
+		t.Errorf("Unexpected usage: %q", got)
+	}
+
+	m.sessionTokenCount = 12345
+	m.contextCompression = true
+	m.compressionTriggerTokens = 25600
+	if got := m.renderContextUsage(); got != "Context: 12.3k tokens (compresses at 25.6k)" {
+		t.Errorf("Unexpected usage: %q", got)
+	}
+}
+
+// TestApplyCompressionSettings tests settings panel changes reach the model
+func TestApplyCompressionSettings(t *testing.T) {
+	panel := settings.New()
+	m := &Model{settingsPanel: &panel, compressionTargetTokens: 8192}
+
+	panel.ContextCompression = true
+	panel.CompressionTriggerTokens = 4096
+	m.applyCompressionSettings()
+
+	if !m.contextCompression || m.compressionTriggerTokens != 4096 {
+		t.Errorf("Expected compression at 4096 tokens, got %t/%d", m.contextCompression, m.compressionTriggerTokens)
+	}
+	if m.compressionTargetTokens != 0 {
+		t.Errorf("Expected target above the new trigger to reset, got %d", m.compressionTargetTokens)
+	}
+	if !m.multimodalConfig.ContextWindowCompression || m.multimodalConfig.CompressionTriggerTokens != 4096 {
+		t.Errorf("Expected multimodal config to follow, got %+v", m.multimodalConfig)
+	}
+}
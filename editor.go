@@ -0,0 +1,128 @@
+package aistudio
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorFinishedMsg is sent when the external editor exits.
+type editorFinishedMsg struct {
+	path string // Temporary file holding the edited draft
+	err  error
+}
+
+// editorCommand returns the user's preferred editor, following the usual
+// VISUAL then EDITOR convention. The value may include arguments.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openEditorCmd suspends the TUI and opens the current draft in $EDITOR.
+// The edited text is loaded back into the input when the editor exits.
+func (m *Model) openEditorCmd() tea.Cmd {
+	return editTextCmd("aistudio-prompt-*.md", m.textarea.Value(), func(path string, err error) tea.Msg {
+		return editorFinishedMsg{path: path, err: err}
+	})
+}
+
+// editTextCmd suspends the TUI and opens text in $EDITOR, in a temporary file
+// named after pattern as with os.CreateTemp. done makes the message sent when
+// the editor exits; its path is the file to read back and remove.
+func editTextCmd(pattern, text string, done func(path string, err error) tea.Msg) tea.Cmd {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return func() tea.Msg { return done("", fmt.Errorf("failed to create temporary file: %w", err)) }
+	}
+	path := f.Name()
+	_, err = f.WriteString(text)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg { return done("", fmt.Errorf("failed to write temporary file: %w", err)) }
+	}
+
+	editor := editorCommand()
+	log.Printf("Opening in editor: %s %s", strings.Join(editor, " "), path)
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return done(path, err)
+	})
+}
+
+// handleEditorFinished loads the edited draft into the input.
+func (m *Model) handleEditorFinished(msg editorFinishedMsg) {
+	if msg.path != "" {
+		defer os.Remove(msg.path)
+	}
+	if msg.err != nil {
+		log.Printf("Editor failed: %v", msg.err)
+		m.messages = append(m.messages, formatError(fmt.Errorf("editor failed: %w", msg.err)))
+		return
+	}
+
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.messages = append(m.messages, formatError(fmt.Errorf("failed to read edited draft: %w", err)))
+		return
+	}
+	// Editors usually add a trailing newline that isn't part of the prompt
+	m.textarea.SetValue(strings.TrimRight(string(data), "\r\n"))
+	m.textarea.Focus()
+	m.focusedComponent = "input"
+}
+
+// recordInput saves a sent prompt to the persisted input history.
+func (m *Model) recordInput(text string) {
+	if m.inputHistory == nil {
+		return
+	}
+	if err := m.inputHistory.Add(text); err != nil {
+		log.Printf("Warning: Failed to save input history: %v", err)
+	}
+}
+
+// recallInput replaces the draft with an older (delta < 0) or newer
+// (delta > 0) prompt from the input history. Returns false when there is
+// nothing to recall, so the key can fall through to normal handling.
+func (m *Model) recallInput(delta int) bool {
+	if m.inputHistory == nil {
+		return false
+	}
+	// Only take over up/down at the edges of the draft so multi-line input
+	// remains navigable.
+	if delta < 0 && m.textarea.Line() != 0 {
+		return false
+	}
+	if delta > 0 && m.textarea.Line() != m.textarea.LineCount()-1 {
+		return false
+	}
+
+	var text string
+	var ok bool
+	if delta < 0 {
+		text, ok = m.inputHistory.Previous(m.textarea.Value())
+	} else {
+		text, ok = m.inputHistory.Next()
+	}
+	if !ok {
+		return false
+	}
+	m.textarea.SetValue(text)
+	return true
+}
@@ -76,6 +76,8 @@ func (f *ToolFormatter) FormatToolCallHeader(vm ToolCallViewModel) string {
 		}
 	case ToolCallStatusRejected:
 		header.WriteString(toolErrorStyle.Render(fmt.Sprintf("%s Rejected", statusGlyph)))
+	case ToolCallStatusCancelled:
+		header.WriteString(toolErrorStyle.Render(fmt.Sprintf("%s Cancelled", statusGlyph)))
 	}
 
 	return header.String()
@@ -117,6 +119,13 @@ func (f *ToolFormatter) FormatToolResult(vm ToolCallViewModel, availWidth ...int
 		return "Result: (empty)"
 	}
 
+	// A result stored as an artifact is collapsed to a summary
+	if id := vm.Result.Fields["artifact_id"].GetStringValue(); id != "" {
+		return fmt.Sprintf("Result: %d bytes in %d lines, stored as artifact %s\n%s",
+			int(vm.Result.Fields["total_bytes"].GetNumberValue()), int(vm.Result.Fields["total_lines"].GetNumberValue()), id,
+			toolIdStyle.Render("▸ Ctrl+F to view the full output in a pager"))
+	}
+
 	jsonBytes, err := protojson.Marshal(vm.Result)
 	if err != nil {
 		return fmt.Sprintf("Error marshaling result: %v", err)
@@ -153,6 +162,14 @@ func (f *ToolFormatter) CreateToolCallMessage(vm ToolCallViewModel, availWidth .
 	content.WriteString(f.FormatToolArgs(vm, availWidth...))
 	content.WriteString("\n")
 
+	// Show the latest progress while the tool runs
+	if vm.Status == ToolCallStatusRunning {
+		for _, line := range vm.Progress {
+			content.WriteString(toolIdStyle.Render("  │ " + line))
+			content.WriteString("\n")
+		}
+	}
+
 	toolCall := ToolCall{
 		ID:        vm.ID,
 		Name:      vm.Name,
@@ -183,6 +200,16 @@ func (f *ToolFormatter) CreateToolResultMessage(vm ToolCallViewModel, availWidth
 		// Add the formatted result, passing available width if provided
 		content.WriteString(f.FormatToolResult(vm, availWidth...))
 		content.WriteString("\n")
+
+		// Show the change a file-editing call made
+		if vm.FileEdit != nil {
+			if vm.Diff == "" {
+				content.WriteString(toolIdStyle.Render(fmt.Sprintf("No changes made to %s", vm.FileEdit.Path)))
+				content.WriteString("\n")
+			} else {
+				content.WriteString(renderDiff(vm.Diff, 0))
+			}
+		}
 	}
 
 	toolResponse := &ToolResponse{
@@ -196,6 +223,7 @@ func (f *ToolFormatter) CreateToolResultMessage(vm ToolCallViewModel, availWidth
 		Sender:       "System",
 		Content:      content.String(),
 		ToolResponse: toolResponse,
+		ToolOutput:   vm.Output,
 		ToolCall:     &ToolCall{ID: vm.ID, Name: vm.Name},
 		Timestamp:    time.Now(),
 	}
@@ -224,7 +252,8 @@ func (f *APIFormatter) ConvertSafetyRating(apiRating *generativelanguagepb.Safet
 	probability := fmt.Sprintf("%s", apiRating.Probability)
 
 	// Check if this rating is blocking content - high and medium are generally considered blocking
-	blocked := apiRating.Probability == generativelanguagepb.SafetyRating_HIGH ||
+	blocked := apiRating.Blocked ||
+		apiRating.Probability == generativelanguagepb.SafetyRating_HIGH ||
 		apiRating.Probability == generativelanguagepb.SafetyRating_MEDIUM
 
 	return &SafetyRating{
@@ -235,6 +264,17 @@ func (f *APIFormatter) ConvertSafetyRating(apiRating *generativelanguagepb.Safet
 	}
 }
 
+// ConvertSafetyRatings converts a list of API safety ratings to our display format
+func (f *APIFormatter) ConvertSafetyRatings(apiRatings []*generativelanguagepb.SafetyRating) []*SafetyRating {
+	var ratings []*SafetyRating
+	for _, apiRating := range apiRatings {
+		if rating := f.ConvertSafetyRating(apiRating); rating != nil {
+			ratings = append(ratings, rating)
+		}
+	}
+	return ratings
+}
+
 // ConvertGroundingMetadata converts API grounding metadata to our display format
 func (f *APIFormatter) ConvertGroundingMetadata(apiMetadata *generativelanguagepb.GroundingMetadata) *GroundingMetadata {
 	if apiMetadata == nil {
@@ -295,6 +335,8 @@ func (f *APIFormatter) ConvertGroundingMetadata(apiMetadata *generativelanguagep
 		// Extract text from segment if available
 		if seg := support.GetSegment(); seg != nil {
 			displaySupport.Text = seg.GetText()
+			displaySupport.StartIndex = int(seg.GetStartIndex())
+			displaySupport.EndIndex = int(seg.GetEndIndex())
 		}
 
 		// Associate chunks with this support
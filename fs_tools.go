@@ -0,0 +1,633 @@
+package aistudio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits keeping file tool results a reasonable size for the model.
+const (
+	maxReadLines     = 2000    // Lines returned by one read_file call
+	maxReadLineBytes = 2000    // Longer lines are cut short
+	maxSearchBytes   = 1 << 20 // Larger files are skipped by grep
+	maxGlobResults   = 500
+	maxGrepMatches   = 200
+	maxListEntries   = 1000
+	maxLinkHops      = 40 // Dangling links followed when resolving a path
+)
+
+// ErrOutsideWorkspace is returned for paths that lead outside the workspace
+// root, directly or through a symbolic link.
+var ErrOutsideWorkspace = errors.New("path is outside the workspace")
+
+// Workspace confines the file tools to a root directory. Paths given to the
+// tools are relative to the root, or absolute paths inside it. Symbolic
+// links are followed only while they resolve inside the root.
+type Workspace struct {
+	Root string // Absolute, with symbolic links resolved
+}
+
+// NewWorkspace returns a workspace rooted at the directory root.
+func NewWorkspace(root string) (*Workspace, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace root: %w", err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid workspace root: %s is not a directory", root)
+	}
+	return &Workspace{Root: resolved}, nil
+}
+
+// Resolve returns the absolute path name refers to, with symbolic links
+// resolved. The path need not exist, but must be inside the root.
+func (w *Workspace) Resolve(name string) (string, error) {
+	if name == "" {
+		name = "."
+	}
+	p := filepath.Clean(name)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(w.Root, p)
+	}
+	if !w.contains(p) {
+		return "", fmt.Errorf("%s: %w", name, ErrOutsideWorkspace)
+	}
+
+	// Resolve the links in the part of the path that exists. A dangling
+	// link is followed to its target, as writing through it would be.
+	existing, rest := p, ""
+	for hops := 0; ; {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			p = filepath.Join(resolved, rest)
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if info, err := os.Lstat(existing); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if hops++; hops > maxLinkHops {
+				return "", fmt.Errorf("%s: too many levels of symbolic links", name)
+			}
+			target, err := os.Readlink(existing)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(existing), target)
+			}
+			existing = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	if !w.contains(p) {
+		return "", fmt.Errorf("%s: %w", name, ErrOutsideWorkspace)
+	}
+	return p, nil
+}
+
+// contains reports whether the clean absolute path p is inside the root.
+func (w *Workspace) contains(p string) bool {
+	rel, err := filepath.Rel(w.Root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// rel returns p relative to the root, with forward slashes, for results.
+func (w *Workspace) rel(p string) string {
+	rel, err := filepath.Rel(w.Root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// SetWorkspace confines the file tools to ws.
+func (tm *ToolManager) SetWorkspace(ws *Workspace) {
+	tm.workspace = ws
+}
+
+// Workspace returns the workspace the file tools are confined to, by
+// default the current directory.
+func (tm *ToolManager) Workspace() (*Workspace, error) {
+	if tm.workspace != nil {
+		return tm.workspace, nil
+	}
+	return NewWorkspace(".")
+}
+
+// fileTool describes one of the file tools.
+type fileTool struct {
+	name        string
+	description string
+	parameters  string
+	handler     func(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error)
+	writes      bool // Changes files, so calls run alone
+}
+
+var fileTools = []fileTool{
+	{
+		name:        "read_file",
+		description: "Read a text file in the workspace. Lines are numbered; use start_line and end_line to read part of a large file.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"file_path": {"type": "string", "description": "Path of the file, relative to the workspace root"},
+				"start_line": {"type": "integer", "minimum": 1, "description": "First line to read (default 1)"},
+				"end_line": {"type": "integer", "minimum": 1, "description": "Last line to read (default: 2000 lines from start_line)"}
+			},
+			"required": ["file_path"]
+		}`,
+		handler: readFileTool,
+	},
+	{
+		name:        "write_file",
+		description: "Write content to a file in the workspace, replacing the file if it exists and creating missing directories.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"file_path": {"type": "string", "description": "Path of the file, relative to the workspace root"},
+				"content": {"type": "string", "description": "The complete new content of the file"}
+			},
+			"required": ["file_path", "content"]
+		}`,
+		handler: writeFileTool,
+		writes:  true,
+	},
+	{
+		name:        "edit_file",
+		description: "Edit a file in the workspace by replacing exact text. old_string must match the file exactly, including whitespace, and occur expected_replacements times. An empty old_string writes new_string as the whole file.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"file_path": {"type": "string", "description": "Path of the file, relative to the workspace root"},
+				"old_string": {"type": "string", "description": "The exact text to replace"},
+				"new_string": {"type": "string", "description": "The text to replace it with"},
+				"expected_replacements": {"type": "integer", "minimum": 1, "default": 1, "description": "How many times old_string occurs; all occurrences are replaced"}
+			},
+			"required": ["file_path", "old_string", "new_string"]
+		}`,
+		handler: editFileTool,
+		writes:  true,
+	},
+	{
+		name:        "glob",
+		description: "Find files in the workspace whose paths match a glob pattern such as **/*.go. A pattern without a slash matches file names at any depth.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"pattern": {"type": "string", "description": "Glob pattern; ** matches any number of directories"},
+				"path": {"type": "string", "description": "Directory to search (default: the workspace root)"}
+			},
+			"required": ["pattern"]
+		}`,
+		handler: globTool,
+	},
+	{
+		name:        "grep",
+		description: "Search the contents of files in the workspace with a regular expression (RE2 syntax). Returns matching lines as path:line: text.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"pattern": {"type": "string", "description": "Regular expression to search for"},
+				"path": {"type": "string", "description": "File or directory to search (default: the workspace root)"},
+				"include": {"type": "string", "description": "Only search files matching this glob pattern, e.g. *.go"}
+			},
+			"required": ["pattern"]
+		}`,
+		handler: grepTool,
+	},
+	{
+		name:        "list_directory",
+		description: "List the entries of a directory in the workspace. Directory names end with a slash.",
+		parameters: `{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "Directory to list (default: the workspace root)"}
+			}
+		}`,
+		handler: listDirectoryTool,
+	},
+}
+
+// RegisterFileTools registers the file tools: read_file, write_file,
+// edit_file, glob, grep and list_directory, confined to the tool manager's
+// workspace. Tools that change files do not run alongside other calls.
+func (tm *ToolManager) RegisterFileTools() error {
+	for _, tool := range fileTools {
+		if err := tm.RegisterStreamingTool(tool.name, tool.description, json.RawMessage(tool.parameters), tm.fileToolHandler(tool.handler)); err != nil {
+			return fmt.Errorf("failed to register %s tool: %w", tool.name, err)
+		}
+		if tool.writes {
+			tm.SetToolExclusive(tool.name, true)
+		}
+	}
+	return nil
+}
+
+// fileToolHandler adapts a file tool to the tool manager, giving it the
+// workspace in use when the call runs.
+func (tm *ToolManager) fileToolHandler(handler func(context.Context, *Workspace, json.RawMessage) (any, error)) ToolStreamHandler {
+	return func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		ws, err := tm.Workspace()
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, ws, args)
+	}
+}
+
+// isFileTool reports whether name is one of the built-in file tools.
+func isFileTool(name string) bool {
+	for _, tool := range fileTools {
+		if tool.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fileToolHandlers are the handlers tools files can name for the file tools.
+var fileToolHandlers = map[string]func(context.Context, *Workspace, json.RawMessage) (any, error){
+	"read_file":       readFileTool,
+	"file_operations": fileOperationsTool,
+}
+
+// fileOperationsTool runs the file tool named by the operation argument, for
+// tools files using the file_operations handler.
+func fileOperationsTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	op := params.Operation
+	for _, tool := range fileTools {
+		if tool.name == op || tool.name == op+"_file" || tool.name == op+"_directory" {
+			return tool.handler(ctx, ws, args)
+		}
+	}
+	return nil, fmt.Errorf("unknown file operation %q (want read, write, edit, glob, grep or list)", params.Operation)
+}
+
+func readFileTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		FilePath  string `json:"file_path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	p, err := ws.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	start := max(params.StartLine, 1)
+	end := start + maxReadLines - 1
+	if params.EndLine > 0 {
+		if params.EndLine < start {
+			return nil, fmt.Errorf("end_line %d is before start_line %d", params.EndLine, start)
+		}
+		end = min(params.EndLine, end)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var content strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxSearchBytes)
+	n := 0
+	for scanner.Scan() {
+		n++
+		if n < start || n > end {
+			continue
+		}
+		fmt.Fprintf(&content, "%6d\t%s\n", n, clipLine(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", params.FilePath, err)
+	}
+	if start > n && n > 0 {
+		return nil, fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, n)
+	}
+	return map[string]any{
+		"file_path":   ws.rel(p),
+		"content":     content.String(),
+		"start_line":  start,
+		"end_line":    min(end, n),
+		"total_lines": n,
+	}, nil
+}
+
+// clipLine cuts a line read from a file to maxReadLineBytes, on a rune
+// boundary, and replaces bytes that are not UTF-8, which a tool response
+// cannot carry.
+func clipLine(line string) string {
+	if len(line) > maxReadLineBytes {
+		n := maxReadLineBytes
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		line = line[:n] + "…"
+	}
+	return strings.ToValidUTF8(line, "\uFFFD")
+}
+
+func writeFileTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		FilePath string `json:"file_path"`
+		Content  string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	p, err := ws.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeWorkspaceFile(p, params.Content); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"file_path":     ws.rel(p),
+		"bytes_written": len(params.Content),
+	}, nil
+}
+
+func editFileTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		FilePath             string `json:"file_path"`
+		OldString            string `json:"old_string"`
+		NewString            string `json:"new_string"`
+		ExpectedReplacements int    `json:"expected_replacements"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	p, err := ws.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if params.OldString == "" {
+		if err := writeWorkspaceFile(p, params.NewString); err != nil {
+			return nil, err
+		}
+		return map[string]any{"file_path": ws.rel(p), "replacements": 0, "created": true}, nil
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	want := max(params.ExpectedReplacements, 1)
+	if n := strings.Count(string(data), params.OldString); n != want {
+		return nil, fmt.Errorf("old_string occurs %d times in %s, expected %d; no changes were made", n, params.FilePath, want)
+	}
+	if err := writeWorkspaceFile(p, strings.ReplaceAll(string(data), params.OldString, params.NewString)); err != nil {
+		return nil, err
+	}
+	return map[string]any{"file_path": ws.rel(p), "replacements": want}, nil
+}
+
+// writeWorkspaceFile writes content to the resolved path p, keeping the
+// permissions of an existing file.
+func writeWorkspaceFile(p, content string) error {
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(p); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", p)
+		}
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(content), perm)
+}
+
+func globTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if _, err := path.Match(strings.ReplaceAll(params.Pattern, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", params.Pattern, err)
+	}
+	dir, err := ws.Resolve(params.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []any
+	truncated := false
+	err = walkWorkspace(ctx, ws, dir, func(p string) bool {
+		rel, _ := filepath.Rel(dir, p)
+		if !matchGlob(params.Pattern, filepath.ToSlash(rel)) {
+			return true
+		}
+		if len(files) == maxGlobResults {
+			truncated = true
+			return false
+		}
+		files = append(files, ws.rel(p))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"files": files, "truncated": truncated}, nil
+}
+
+func grepTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+		Include string `json:"include"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	re, err := regexp.Compile(params.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	root, err := ws.Resolve(params.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []any
+	truncated := false
+	err = walkWorkspace(ctx, ws, root, func(p string) bool {
+		rel, _ := filepath.Rel(root, p)
+		if params.Include != "" && !matchGlob(params.Include, filepath.ToSlash(rel)) {
+			return true
+		}
+		info, err := os.Stat(p)
+		if err != nil || info.Size() > maxSearchBytes {
+			return true
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 512)], 0) >= 0 {
+			return true // Unreadable or binary
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if len(matches) == maxGrepMatches {
+				truncated = true
+				return false
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", ws.rel(p), i+1, clipLine(line)))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"matches": matches, "truncated": truncated}, nil
+}
+
+func listDirectoryTool(ctx context.Context, ws *Workspace, args json.RawMessage) (any, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+	dir, err := ws.Resolve(params.Path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []any
+	for _, entry := range entries {
+		if len(names) == maxListEntries {
+			break
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		} else if entry.Type()&fs.ModeSymlink != 0 {
+			// Show links to directories inside the workspace as directories
+			if p, err := ws.Resolve(filepath.Join(dir, name)); err == nil {
+				if info, err := os.Stat(p); err == nil && info.IsDir() {
+					name += "/"
+				}
+			}
+		}
+		names = append(names, name)
+	}
+	return map[string]any{
+		"path":      ws.rel(dir),
+		"entries":   names,
+		"truncated": len(entries) > maxListEntries,
+	}, nil
+}
+
+// walkWorkspace calls visit with each regular file under root, or root itself
+// when it is a file, in lexical order, until visit returns false. It skips
+// .git directories and symbolic links, which could lead outside the
+// workspace or loop.
+func walkWorkspace(ctx context.Context, ws *Workspace, root string, visit func(p string) bool) error {
+	stop := errors.New("stop")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // Skip unreadable entries
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch {
+		case d.IsDir():
+			if d.Name() == ".git" && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		case !d.Type().IsRegular():
+			return nil
+		}
+		if !visit(p) {
+			return stop
+		}
+		return nil
+	})
+	if err == stop {
+		return nil
+	}
+	return err
+}
+
+// matchGlob reports whether the slash-separated path name matches pattern,
+// where ** matches any number of directories. A pattern without a slash
+// matches the last element of name.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,215 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestWorkspace returns a workspace holding the given files.
+func newTestWorkspace(t *testing.T, files map[string]string) *Workspace {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestWorkspaceResolve(t *testing.T) {
+	ws := newTestWorkspace(t, map[string]string{"src/main.go": "package main\n"})
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(ws.Root, "escape")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(ws.Root, "src"), filepath.Join(ws.Root, "code")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "pwned.txt"), filepath.Join(ws.Root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src/later.go", filepath.Join(ws.Root, "later")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string // Relative to the root
+		outside bool
+	}{
+		{"relative", "src/main.go", "src/main.go", false},
+		{"absolute", filepath.Join(ws.Root, "src"), "src", false},
+		{"new file", "src/new/file.go", "src/new/file.go", false},
+		{"link inside", "code/main.go", "src/main.go", false},
+		{"parent", "../etc/passwd", "", true},
+		{"absolute outside", outside, "", true},
+		{"link outside", "escape/secret", "", true},
+		{"new file through link", "escape/new/file", "", true},
+		{"dangling link inside", "later", "src/later.go", false},
+		{"dangling link outside", "dangling", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ws.Resolve(tt.path)
+			if tt.outside {
+				if !errors.Is(err, ErrOutsideWorkspace) {
+					t.Errorf("Resolve(%q) = %q, %v; want ErrOutsideWorkspace", tt.path, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) failed: %v", tt.path, err)
+			}
+			if rel := ws.rel(got); rel != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.path, rel, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileTools(t *testing.T) {
+	ws := newTestWorkspace(t, map[string]string{
+		"README.md":          "# Project\n",
+		"main.go":            "package main\n\nfunc main() {\n\trun()\n}\n",
+		"internal/run.go":    "package internal\n\nfunc run() {}\n",
+		"internal/x/deep.go": "package x\n",
+		".git/config":        "run\n",
+		"image.bin":          "run\x00\x01",
+		"wide.txt":           "x" + strings.Repeat("é", 1500) + "\n",
+		"latin1.txt":         "caf\xe9\n",
+	})
+	tm := NewToolManager()
+	tm.SetWorkspace(ws)
+	if err := tm.RegisterFileTools(); err != nil {
+		t.Fatalf("RegisterFileTools failed: %v", err)
+	}
+	call := func(name, args string) map[string]any {
+		t.Helper()
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: name, Arguments: json.RawMessage(args)})
+		if err != nil {
+			t.Fatalf("%s(%s) failed: %v", name, args, err)
+		}
+		result, _ := resp.Response.AsMap()["result"].(map[string]any)
+		return result
+	}
+
+	t.Run("read_file", func(t *testing.T) {
+		got := call("read_file", `{"file_path": "main.go", "start_line": 3, "end_line": 4}`)
+		if want := "     3\tfunc main() {\n     4\t\trun()\n"; got["content"] != want {
+			t.Errorf("Got content %q, want %q", got["content"], want)
+		}
+		if got["total_lines"] != float64(5) {
+			t.Errorf("Got total_lines %v, want 5", got["total_lines"])
+		}
+	})
+
+	t.Run("read_file cuts and cleans lines", func(t *testing.T) {
+		got := call("read_file", `{"file_path": "wide.txt"}`)
+		if want := "     1\tx" + strings.Repeat("é", 999) + "…\n"; got["content"] != want {
+			t.Errorf("Got content %q, want %q", got["content"], want)
+		}
+		got = call("read_file", `{"file_path": "latin1.txt"}`)
+		if want := "     1\tcaf\uFFFD\n"; got["content"] != want {
+			t.Errorf("Got content %q, want %q", got["content"], want)
+		}
+		got = call("grep", `{"pattern": "caf", "include": "*.txt"}`)
+		if want := []any{"latin1.txt:1: caf\uFFFD"}; !reflect.DeepEqual(got["matches"], want) {
+			t.Errorf("Got %v, want %v", got["matches"], want)
+		}
+	})
+
+	t.Run("edit_file", func(t *testing.T) {
+		call("edit_file", `{"file_path": "internal/run.go", "old_string": "func run() {}", "new_string": "func Run() {}"}`)
+		data, _ := os.ReadFile(filepath.Join(ws.Root, "internal/run.go"))
+		if want := "package internal\n\nfunc Run() {}\n"; string(data) != want {
+			t.Errorf("Got %q, want %q", data, want)
+		}
+		_, err := tm.Execute(context.Background(), ToolCall{Name: "edit_file", Arguments: json.RawMessage(`{"file_path": "main.go", "old_string": "missing", "new_string": "x"}`)})
+		if err == nil || !strings.Contains(err.Error(), "occurs 0 times") {
+			t.Errorf("Expected an error for a missing old_string, got %v", err)
+		}
+	})
+
+	t.Run("write_file", func(t *testing.T) {
+		call("write_file", `{"file_path": "docs/notes.txt", "content": "notes\n"}`)
+		if data, err := os.ReadFile(filepath.Join(ws.Root, "docs/notes.txt")); err != nil || string(data) != "notes\n" {
+			t.Errorf("Got %q, %v; want the written content", data, err)
+		}
+		_, err := tm.Execute(context.Background(), ToolCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "../outside.txt", "content": "x"}`)})
+		if !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("Expected ErrOutsideWorkspace writing outside the workspace, got %v", err)
+		}
+
+		// A dangling link must not let a write escape the workspace
+		outside := t.TempDir()
+		if err := os.Symlink(filepath.Join(outside, "pwned.txt"), filepath.Join(ws.Root, "evil")); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+		_, err = tm.Execute(context.Background(), ToolCall{Name: "write_file", Arguments: json.RawMessage(`{"file_path": "evil", "content": "x"}`)})
+		if !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("Expected ErrOutsideWorkspace writing through a dangling link, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "pwned.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("File was created outside the workspace: %v", err)
+		}
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		got := call("glob", `{"pattern": "**/*.go"}`)["files"]
+		want := []any{"internal/run.go", "internal/x/deep.go", "main.go"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+		got = call("glob", `{"pattern": "*.go", "path": "internal"}`)["files"]
+		if want := []any{"internal/run.go", "internal/x/deep.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("grep", func(t *testing.T) {
+		got := call("grep", `{"pattern": "\\brun\\(\\)", "include": "*.go"}`)["matches"]
+		want := []any{"main.go:4: \trun()"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+		// .git directories and binary files are skipped
+		got = call("grep", `{"pattern": "^run"}`)["matches"]
+		if len(got.([]any)) != 0 {
+			t.Errorf("Got %v, want no matches", got)
+		}
+	})
+
+	t.Run("list_directory", func(t *testing.T) {
+		got := call("list_directory", `{"path": "internal"}`)["entries"]
+		if want := []any{"run.go", "x/"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("file_operations", func(t *testing.T) {
+		got, err := fileOperationsTool(context.Background(), ws, json.RawMessage(`{"operation": "list", "path": "internal/x"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []any{"deep.go"}; !reflect.DeepEqual(got.(map[string]any)["entries"], want) {
+			t.Errorf("Got %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package aistudio
+
+import (
+	"log"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// regenerateState tracks a regenerate request until the new answer arrives.
+type regenerateState struct {
+	turnIndex  int      // Index in m.messages where the new response starts
+	alternates []string // Earlier answers to the same user turn, oldest first
+}
+
+// interruptibleStream is implemented by streams that can abandon a turn
+// without being torn down, such as the Live API adapter.
+type interruptibleStream interface {
+	Interrupt() error
+}
+
+// isAnswerMessage reports whether msg is plain model output, as opposed to a
+// tool call, tool result or code execution message.
+func isAnswerMessage(msg Message) bool {
+	return msg.Sender == senderNameModel &&
+		!msg.IsToolCall() &&
+		!msg.IsToolResponse() &&
+		!msg.IsExecutableCode &&
+		!msg.IsExecutableCodeResult
+}
+
+// syncAlternates writes the displayed content back into its alternate slot.
+func (msg *Message) syncAlternates() {
+	if msg.AlternateIndex >= 0 && msg.AlternateIndex < len(msg.Alternates) {
+		msg.Alternates[msg.AlternateIndex] = msg.Content
+	}
+}
+
+// isGenerating reports whether a response is currently in flight.
+func (m *Model) isGenerating() bool {
+	return m.currentState == AppStateWaiting || m.currentState == AppStateResponding
+}
+
+// lastUserMessageIndex returns the index of the most recent user message, or -1.
+func (m *Model) lastUserMessageIndex() int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Sender == senderNameUser {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastAnswerIndex returns the index of the answer to the most recent user
+// message, or -1 if there is none yet.
+func (m *Model) lastAnswerIndex() int {
+	for i := m.lastUserMessageIndex() + 1; i < len(m.messages); i++ {
+		if isAnswerMessage(m.messages[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sendTextCmd sends text on whichever stream type is active. Turns outside
+// Live sessions go through the chat, which keeps the conversation history.
+func (m *Model) sendTextCmd(text string) tea.Cmd {
+	if m.usesChat() {
+		return m.sendChatCmd(text) // chat_tui.go
+	}
+	if m.useBidi && m.bidiStream != nil {
+		return m.sendToBidiStreamCmd(text) // stream.go
+	}
+	return m.sendToStreamCmd(text) // stream.go
+}
+
+// StopGeneration aborts the response in flight. Live streams are interrupted
+// in place; other streams have their context cancelled. Pending audio is
+// discarded so nothing more is played for the aborted turn.
+// Returns true if a response was stopped.
+func (m *Model) StopGeneration() bool {
+	if !m.isGenerating() {
+		return false
+	}
+	log.Println("Stopping generation at user request")
+
+	interrupted := false
+	if stream, ok := m.bidiStream.(interruptibleStream); ok {
+		if err := stream.Interrupt(); err != nil {
+			log.Printf("Live interrupt failed, cancelling stream instead: %v", err)
+		} else {
+			interrupted = true
+		}
+	}
+	if !interrupted && m.streamCtxCancel != nil {
+		// The receive loop will see a cancellation error; don't treat it as a failure
+		m.stopRequested = true
+		m.streamCtxCancel()
+	}
+
+	m.StopCurrentAudio()
+	m.drainAudioChannel()
+
+	if idx := m.lastAnswerIndex(); idx >= 0 {
+		m.messages[idx].Stopped = true
+	}
+	m.processingTool = false
+	m.currentState = AppStateReady
+	return true
+}
+
+// drainAudioChannel discards audio chunks queued for playback.
+func (m *Model) drainAudioChannel() {
+	if m.audioChannel == nil {
+		return
+	}
+	for {
+		select {
+		case <-m.audioChannel:
+		default:
+			return
+		}
+	}
+}
+
+// RegenerateLastResponse re-sends the most recent user message. The current
+// answer is kept and attached to the new one as an alternate.
+func (m *Model) RegenerateLastResponse() tea.Cmd {
+	userIdx := m.lastUserMessageIndex()
+	if userIdx < 0 {
+		m.messages = append(m.messages, formatMessage(senderNameSystem, "Nothing to regenerate yet."))
+		return nil
+	}
+	m.StopGeneration()
+
+	var previous []string
+	if idx := m.lastAnswerIndex(); idx >= 0 {
+		answer := m.messages[idx]
+		if len(answer.Alternates) > 0 {
+			answer.syncAlternates()
+			previous = append(previous, answer.Alternates...)
+		} else if answer.Content != "" {
+			previous = append(previous, answer.Content)
+		}
+	}
+
+	text := m.messages[userIdx].Content
+	log.Printf("Regenerating response to: %s (%d previous answers)", text, len(previous))
+	m.messages = m.messages[:userIdx+1]
+	m.regenerate = &regenerateState{turnIndex: userIdx + 1, alternates: previous}
+	m.currentState = AppStateWaiting
+	if m.usesChat() && m.chat != nil {
+		return m.chatTurnCmd(m.chat.Regenerate) // chat_tui.go
+	}
+	return m.sendTextCmd(text)
+}
+
+// attachRegeneratedAnswer links earlier answers to the first answer produced
+// after a regenerate, making the new answer the selected alternate.
+func (m *Model) attachRegeneratedAnswer() {
+	if m.regenerate == nil || len(m.regenerate.alternates) == 0 {
+		m.regenerate = nil
+		return
+	}
+	for i := m.regenerate.turnIndex; i < len(m.messages); i++ {
+		if isAnswerMessage(m.messages[i]) {
+			msg := &m.messages[i]
+			msg.Alternates = append(m.regenerate.alternates, msg.Content)
+			msg.AlternateIndex = len(msg.Alternates) - 1
+			m.regenerate = nil
+			return
+		}
+	}
+}
+
+// CycleAlternate switches the latest answer to its next (delta > 0) or
+// previous (delta < 0) alternate. Returns false if there is nothing to flip.
+func (m *Model) CycleAlternate(delta int) bool {
+	idx := m.lastAnswerIndex()
+	if idx < 0 || len(m.messages[idx].Alternates) < 2 {
+		return false
+	}
+	msg := &m.messages[idx]
+	msg.syncAlternates()
+	n := len(msg.Alternates)
+	msg.AlternateIndex = ((msg.AlternateIndex+delta)%n + n) % n
+	msg.Content = msg.Alternates[msg.AlternateIndex]
+	return true
+}
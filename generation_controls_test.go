@@ -0,0 +1,66 @@
+package aistudio
+
+import "testing"
+
+// TestRegenerateKeepsAlternates tests that regenerating keeps earlier answers to flip between
+func TestRegenerateKeepsAlternates(t *testing.T) {
+	m := &Model{}
+	m.messages = []Message{
+		formatMessage(senderNameUser, "hello"),
+		formatMessage(senderNameModel, "first answer"),
+	}
+
+	m.regenerate = &regenerateState{turnIndex: 1, alternates: []string{"first answer"}}
+	m.messages = m.messages[:1]
+	m.messages = append(m.messages, formatMessage(senderNameModel, "second answer"))
+	m.attachRegeneratedAnswer()
+
+	answer := m.messages[1]
+	if len(answer.Alternates) != 2 || answer.AlternateIndex != 1 {
+		t.Fatalf("Expected 2 alternates with the new one selected, got %v (index %d)", answer.Alternates, answer.AlternateIndex)
+	}
+	if m.regenerate != nil {
+		t.Error("Expected regenerate state to be cleared")
+	}
+
+	if !m.CycleAlternate(-1) {
+		t.Fatal("Expected CycleAlternate to succeed")
+	}
+	if got := m.messages[1].Content; got != "first answer" {
+		t.Errorf("Expected first answer after cycling back, got %q", got)
+	}
+	m.CycleAlternate(-1)
+	if got := m.messages[1].Content; got != "second answer" {
+		t.Errorf("Expected cycling to wrap around, got %q", got)
+	}
+}
+
+// TestStopGenerationDrainsAudio tests that stopping discards queued audio and marks the answer
+func TestStopGenerationDrainsAudio(t *testing.T) {
+	m := &Model{}
+	m.audioChannel = make(chan AudioChunk, 4)
+	m.audioChannel <- AudioChunk{}
+	m.audioChannel <- AudioChunk{}
+	m.messages = []Message{
+		formatMessage(senderNameUser, "hello"),
+		formatMessage(senderNameModel, "partial"),
+	}
+
+	if m.StopGeneration() {
+		t.Fatal("Expected no stop while idle")
+	}
+
+	m.currentState = AppStateResponding
+	if !m.StopGeneration() {
+		t.Fatal("Expected StopGeneration to stop an in-flight response")
+	}
+	if len(m.audioChannel) != 0 {
+		t.Errorf("Expected audio channel to be drained, %d items left", len(m.audioChannel))
+	}
+	if !m.messages[1].Stopped {
+		t.Error("Expected answer to be marked stopped")
+	}
+	if m.currentState != AppStateReady {
+		t.Errorf("Expected ready state, got %v", m.currentState)
+	}
+}
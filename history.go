@@ -188,6 +188,10 @@ func (m *Model) saveSessionCmd() tea.Cmd {
 				execCodeCopy := *msg.ExecutableCode
 				msgCopy.ExecutableCode = &execCodeCopy
 			}
+			if len(msg.Alternates) > 0 {
+				msgCopy.Alternates = append([]string(nil), msg.Alternates...)
+				msgCopy.syncAlternates()
+			}
 
 			// Audio data is large, so we store only a small hint if it exists
 			if len(msg.AudioData) > 1024 && msgCopy.HasAudio {
@@ -0,0 +1,67 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExportSession renders a saved session as "markdown" or "json". Markdown
+// exports keep grounding citations as footnote markers with a sources list
+// under each answer; JSON exports carry the full grounding metadata.
+func ExportSession(session *ChatSession, format string) ([]byte, error) {
+	if session == nil {
+		return nil, fmt.Errorf("no session to export")
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize session: %w", err)
+		}
+		return data, nil
+	case "markdown", "md":
+		return exportSessionMarkdown(session), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// exportSessionMarkdown writes the conversation as Markdown.
+func exportSessionMarkdown(session *ChatSession) []byte {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("# %s\n\n", session.Title))
+	if session.ModelName != "" {
+		b.WriteString(fmt.Sprintf("**Model:** %s\n", session.ModelName))
+	}
+	b.WriteString(fmt.Sprintf("**Created:** %s\n\n", session.CreatedAt.Format("2006-01-02 15:04:05")))
+
+	for _, msg := range session.Messages {
+		if msg.Content == "" {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("### %s\n\n", msg.Sender))
+		content := msg.Content
+		if msg.HasGroundingMetadata {
+			content = annotateCitations(content, msg.GroundingMetadata)
+		}
+		b.WriteString(content)
+		b.WriteString("\n\n")
+
+		if !msg.HasGroundingMetadata || msg.GroundingMetadata == nil {
+			continue
+		}
+		gm := msg.GroundingMetadata
+		if sources := citationSources(gm); len(sources) > 0 {
+			b.WriteString("**Sources:**\n\n")
+			for _, line := range sources {
+				b.WriteString("- " + line + "\n")
+			}
+			b.WriteString("\n")
+		}
+		if len(gm.WebSearchQueries) > 0 {
+			b.WriteString(fmt.Sprintf("*Searched: %s*\n\n", strings.Join(gm.WebSearchQueries, ", ")))
+		}
+	}
+	return []byte(b.String())
+}
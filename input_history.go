@@ -0,0 +1,165 @@
+package aistudio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxInputHistory caps the number of prompts kept on disk.
+const maxInputHistory = 1000
+
+// InputHistory stores previously sent prompts so they can be recalled with
+// up/down across sessions. Entries are kept oldest first and persisted as one
+// JSON string per line, which keeps multi-line prompts intact.
+type InputHistory struct {
+	path    string
+	entries []string
+	index   int    // Position while browsing; len(entries) when not browsing
+	draft   string // Unsent input saved when browsing starts
+}
+
+// NewInputHistory loads the input history stored at path. A missing file is
+// not an error. An empty path keeps history in memory only.
+func NewInputHistory(path string) (*InputHistory, error) {
+	h := &InputHistory{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // Skip corrupt lines rather than losing the whole history
+		}
+		h.entries = append(h.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input history: %w", err)
+	}
+	if len(h.entries) > maxInputHistory {
+		h.entries = h.entries[len(h.entries)-maxInputHistory:]
+	}
+	h.index = len(h.entries)
+	return h, nil
+}
+
+// Len returns the number of stored entries.
+func (h *InputHistory) Len() int {
+	return len(h.entries)
+}
+
+// Add records a sent prompt and appends it to the history file. Repeating
+// the most recent entry is ignored.
+func (h *InputHistory) Add(entry string) error {
+	h.Reset()
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return nil
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxInputHistory {
+		h.entries = h.entries[len(h.entries)-maxInputHistory:]
+		h.index = len(h.entries)
+		return h.rewrite()
+	}
+	h.index = len(h.entries)
+
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create input history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open input history: %w", err)
+	}
+	defer f.Close()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the history file with the in-memory entries.
+func (h *InputHistory) rewrite() error {
+	if h.path == "" {
+		return nil
+	}
+	var b strings.Builder
+	for _, entry := range h.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create input history directory: %w", err)
+	}
+	if err := os.WriteFile(h.path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write input history: %w", err)
+	}
+	return nil
+}
+
+// Browsing reports whether an older entry is currently recalled.
+func (h *InputHistory) Browsing() bool {
+	return h.index < len(h.entries)
+}
+
+// Previous returns the entry before the current position. current is the
+// text in the input, saved as the draft when browsing starts.
+func (h *InputHistory) Previous(current string) (string, bool) {
+	if h.index == 0 || len(h.entries) == 0 {
+		return "", false
+	}
+	if !h.Browsing() {
+		h.draft = current
+	}
+	h.index--
+	return h.entries[h.index], true
+}
+
+// Next returns the entry after the current position, or the saved draft once
+// the newest entry has been passed.
+func (h *InputHistory) Next() (string, bool) {
+	if !h.Browsing() {
+		return "", false
+	}
+	h.index++
+	if h.index == len(h.entries) {
+		draft := h.draft
+		h.draft = ""
+		return draft, true
+	}
+	return h.entries[h.index], true
+}
+
+// Reset ends browsing without changing the stored entries.
+func (h *InputHistory) Reset() {
+	h.index = len(h.entries)
+	h.draft = ""
+}
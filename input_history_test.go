@@ -0,0 +1,82 @@
+package aistudio
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestInputHistoryPersistsAndBrowses tests that prompts survive a restart and can be browsed
+func TestInputHistoryPersistsAndBrowses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input_history.jsonl")
+
+	h, err := NewInputHistory(path)
+	if err != nil {
+		t.Fatalf("NewInputHistory failed: %v", err)
+	}
+	for _, entry := range []string{"first", "second\nwith a second line", "second\nwith a second line", "third"} {
+		if err := h.Add(entry); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	h, err = NewInputHistory(path)
+	if err != nil {
+		t.Fatalf("Reloading history failed: %v", err)
+	}
+	if h.Len() != 3 {
+		t.Fatalf("Expected 3 entries after dropping the repeat, got %d", h.Len())
+	}
+
+	if got, _ := h.Previous("draft"); got != "third" {
+		t.Errorf("Expected most recent entry, got %q", got)
+	}
+	if got, _ := h.Previous(""); got != "second\nwith a second line" {
+		t.Errorf("Expected multi-line entry intact, got %q", got)
+	}
+	h.Previous("")
+	if _, ok := h.Previous(""); ok {
+		t.Error("Expected no entry before the oldest")
+	}
+
+	h.Next()
+	h.Next()
+	if got, _ := h.Next(); got != "draft" {
+		t.Errorf("Expected the unsent draft after the newest entry, got %q", got)
+	}
+	if h.Browsing() {
+		t.Error("Expected browsing to end at the draft")
+	}
+}
+
+// TestInputHistoryRecallMultiLineDraft tests up and down move within a
+// multi-line draft, recalling history only from its first or last line
+func TestInputHistoryRecallMultiLineDraft(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	m := New(WithInputHistory(filepath.Join(t.TempDir(), "input_history.jsonl")))
+	m.inputHistory.Add("earlier prompt")
+	m.focusedComponent = "input"
+	m.textarea.Focus()
+	m.textarea.SetValue("first line\nsecond line")
+	press := func(key tea.KeyType) {
+		t.Helper()
+		updated, _ := m.Update(tea.KeyMsg{Type: key})
+		m = updated.(*Model)
+	}
+
+	press(tea.KeyUp)
+	if got := m.textarea.Value(); got != "first line\nsecond line" || m.textarea.Line() != 0 {
+		t.Fatalf("Expected up on the second line to move the cursor, got draft %q on line %d", got, m.textarea.Line())
+	}
+	press(tea.KeyUp)
+	if got := m.textarea.Value(); got != "earlier prompt" {
+		t.Errorf("Expected up on the first line to recall history, got %q", got)
+	}
+	press(tea.KeyDown)
+	if got := m.textarea.Value(); got != "first line\nsecond line" {
+		t.Errorf("Expected down to restore the draft, got %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+// Package diff computes line-based unified diffs, as shown when previewing
+// and recording file edits made by tools.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround each change.
+const contextLines = 3
+
+// maxLCSCells bounds the work spent matching changed lines. Larger changes
+// are shown as the removal of the old lines followed by the new ones.
+const maxLCSCells = 1 << 22
+
+// op is one line of an edit script: ' ' keeps, '-' removes, '+' adds.
+type op struct {
+	kind byte
+	line string
+}
+
+// Unified returns a unified diff turning old into new, with oldName and
+// newName in its header, or "" when old and new are equal.
+func Unified(oldName, newName, old, new string) string {
+	if old == new {
+		return ""
+	}
+	ops := edits(splitLines(old), splitLines(new))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk over changes whose
+		// contexts overlap
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				if i-last > 2*contextLines {
+					break
+				}
+				last = i
+			}
+		}
+		from := max(start, first-contextLines)
+		to := min(len(ops), last+contextLines+1)
+		writeHunk(&b, ops, from, to)
+		start = to
+	}
+	return b.String()
+}
+
+// writeHunk writes the hunk of ops[from:to] with its header.
+func writeHunk(b *strings.Builder, ops []op, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != '+' {
+			oldLine++
+		}
+		if o.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, o := range ops[from:to] {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+	// An empty range names the line before it
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, o := range ops[from:to] {
+		b.WriteByte(o.kind)
+		b.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits returns an edit script turning a into b.
+func edits(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{' ', line})
+	}
+	ops = append(ops, matchLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{' ', line})
+	}
+	return ops
+}
+
+// matchLines returns an edit script turning a into b that keeps a longest
+// common subsequence of their lines.
+func matchLines(a, b []string) []op {
+	var ops []op
+	n, m := len(a), len(b)
+	if n*m > maxLCSCells {
+		for _, line := range a {
+			ops = append(ops, op{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{'+', line})
+		}
+		return ops
+	}
+
+	// lcs[i*(m+1)+j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	numbered := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			b.WriteString(strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"change", "a\nb\nc\n", "a\nB\nc\n", `--- old
++++ new
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`},
+		{"new file", "", "hello\nworld\n", `--- old
++++ new
+@@ -0,0 +1,2 @@
++hello
++world
+`},
+		{"no newline at end", "a\n", "a\nb", `--- old
++++ new
+@@ -1,1 +1,2 @@
+ a
++b
+\ No newline at end of file
+`},
+		{"two hunks", numbered(1, 12), strings.Replace(strings.Replace(numbered(1, 12), "x\n", "one\n", 1), "xxxxxxxxxxxx\n", "twelve\n", 1), `--- old
++++ new
+@@ -1,4 +1,4 @@
+-x
++one
+ xx
+ xxx
+ xxxx
+@@ -9,4 +9,4 @@
+ xxxxxxxxx
+ xxxxxxxxxx
+ xxxxxxxxxxx
+-xxxxxxxxxxxx
++twelve
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.old, tt.new); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
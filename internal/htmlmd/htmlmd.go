@@ -0,0 +1,382 @@
+// Package htmlmd converts HTML pages to markdown, as returned to the model
+// by the web_fetch tool. It keeps the text, headings, links, lists, code and
+// tables of a page and drops scripts, styles and other markup.
+package htmlmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document is a converted page.
+type Document struct {
+	Title    string
+	Markdown string
+}
+
+// Convert reads an HTML page from r and converts it to markdown. Relative
+// links and image sources are resolved against base, which may be nil.
+func Convert(r io.Reader, base *url.URL) (*Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	c := &converter{base: base}
+	doc := &Document{Title: collapseSpace(textContent(find(root, atom.Title)))}
+	body := find(root, atom.Body)
+	if body == nil {
+		body = root
+	}
+	doc.Markdown = strings.TrimSpace(blankLines.ReplaceAllString(c.blocks(body, "\n\n"), "\n\n"))
+	return doc, nil
+}
+
+var (
+	spaces     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// skipped holds elements whose content is not part of the page's text.
+var skipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Canvas: true, atom.Iframe: true,
+	atom.Object: true, atom.Button: true, atom.Select: true, atom.Input: true,
+	atom.Textarea: true,
+}
+
+// blockElements holds the elements rendered as blocks of their own.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Details: true, atom.Dialog: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.Form: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Summary: true, atom.Table: true, atom.Ul: true,
+}
+
+type converter struct {
+	base *url.URL
+}
+
+// blocks renders the children of n as markdown blocks separated by sep.
+// Runs of inline content between blocks become paragraphs.
+func (c *converter) blocks(n *html.Node, sep string) string {
+	var out []string
+	var para strings.Builder
+	flush := func() {
+		if s := cleanInline(para.String()); s != "" {
+			out = append(out, s)
+		}
+		para.Reset()
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && blockElements[child.DataAtom] {
+			flush()
+			if s := c.block(child); s != "" {
+				out = append(out, s)
+			}
+			continue
+		}
+		para.WriteString(c.inline(child))
+	}
+	flush()
+	return strings.Join(out, sep)
+}
+
+// block renders the block element n.
+func (c *converter) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.ReplaceAll(cleanInline(c.inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.P, atom.Dt, atom.Summary:
+		return cleanInline(c.inlineChildren(n))
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Pre:
+		return codeBlock(n)
+	case atom.Blockquote:
+		return prefixLines(c.blocks(n, "\n\n"), "> ", "> ")
+	case atom.Hr:
+		return "---"
+	case atom.Table:
+		return c.table(n)
+	}
+	return c.blocks(n, "\n\n")
+}
+
+// inline renders n as inline markdown.
+func (c *converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaces.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if skipped[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.A:
+		text := strings.TrimSpace(c.inlineChildren(n))
+		href := c.resolve(attr(n, "href"))
+		if href == "" || text == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		src := c.resolve(attr(n, "src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + collapseSpace(attr(n, "alt")) + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrap(c.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.inlineChildren(n), "*")
+	case atom.Code, atom.Kbd, atom.Samp:
+		text := collapseSpace(textContent(n))
+		if text == "" {
+			return ""
+		}
+		fence := "`"
+		if strings.Contains(text, "`") {
+			fence = "``"
+		}
+		return fence + text + fence
+	}
+	// Block elements nested in inline content are flattened
+	s := c.inlineChildren(n)
+	if blockElements[n.DataAtom] {
+		s = " " + s + " "
+	}
+	return s
+}
+
+// inlineChildren renders the children of n as inline markdown.
+func (c *converter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+// list renders the ul or ol element n, indenting the lines of each item
+// under its marker.
+func (c *converter) list(n *html.Node) string {
+	var items []string
+	num := 1
+	if start := attr(n, "start"); start != "" {
+		fmt.Sscan(start, &num)
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", num)
+			num++
+		}
+		text := c.blocks(child, "\n")
+		items = append(items, prefixLines(text, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders the table element n as a markdown table, taking the first
+// row as the header.
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(child)
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						text := strings.ReplaceAll(cleanInline(c.inlineChildren(cell)), "\n", " ")
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// codeBlock renders the pre element n as a fenced code block, taking the
+// language from a language-* class on it or on its code element.
+func codeBlock(n *html.Node) string {
+	text := strings.Trim(textContent(n), "\n")
+	if text == "" {
+		return ""
+	}
+	lang := language(n)
+	if code := find(n, atom.Code); lang == "" && code != nil {
+		lang = language(code)
+	}
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + text + "\n" + fence
+}
+
+// language returns the language named by a language-* or lang-* class on n.
+func language(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(class, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// resolve returns the absolute URL of ref, or "" for references that lead
+// nowhere useful, such as fragments and javascript: links.
+func (c *converter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "", "http", "https", "mailto":
+		return u.String()
+	}
+	return ""
+}
+
+// find returns the first element below n, or n itself, with the given atom.
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := find(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the text below n as it appears in the source.
+func textContent(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// cleanInline trims the lines of rendered inline content and collapses
+// the spaces in them.
+func cleanInline(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = collapseSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s, " "))
+}
+
+// wrap surrounds the trimmed text s with marker, keeping the spaces around
+// it outside the markers.
+func wrap(s, marker string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+	lead := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	trail := s[len(strings.TrimRight(s, " ")):]
+	return lead + marker + text + marker + trail
+}
+
+// prefixLines prefixes the first line of s with first and the others with
+// rest, without trailing spaces on blank lines.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,53 @@
+package htmlmd
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/")
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"paragraphs", `<p>Hello,   <b>world</b>!</p><p>Second <em>line</em><br>broken</p>`,
+			"Hello, **world**!\n\nSecond *line*\nbroken"},
+		{"headings and links", `<h2>Install <a href="#install">#</a></h2><p>See <a href="guide.html">the guide</a>.</p>`,
+			"## Install #\n\nSee [the guide](https://example.com/docs/guide.html)."},
+		{"skipped", `<script>alert(1)</script><style>p{}</style><div>Text<noscript>No JS</noscript></div>`,
+			"Text"},
+		{"lists", `<ul><li>One</li><li>Two<ol start="3"><li>Three</li></ol></li></ul>`,
+			"- One\n- Two\n  3. Three"},
+		{"code", `<p>Run <code>go test</code>:</p><pre><code class="language-sh">go test ./...
+</code></pre>`,
+			"Run `go test`:\n\n```sh\ngo test ./...\n```"},
+		{"blockquote", `<blockquote><p>One</p><p>Two</p></blockquote>`,
+			"> One\n>\n> Two"},
+		{"table", `<table><tr><th>Name</th><th>Size</th></tr><tr><td>a|b</td><td>1</td></tr><tr><td>c</td></tr></table>`,
+			"| Name | Size |\n| --- | --- |\n| a\\|b | 1 |\n| c |  |"},
+		{"image", `<p><img src="/logo.png" alt="Logo"> <a href="javascript:void(0)">Menu</a></p>`,
+			"![Logo](https://example.com/logo.png) Menu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Convert(strings.NewReader(tt.html), base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Markdown != tt.want {
+				t.Errorf("Convert() =\n%s\nwant\n%s", doc.Markdown, tt.want)
+			}
+		})
+	}
+
+	doc, err := Convert(strings.NewReader("<html><head><title> Example\n Domain </title></head><body>Hi</body></html>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Example Domain" || doc.Markdown != "Hi" {
+		t.Errorf("Got title %q and markdown %q", doc.Title, doc.Markdown)
+	}
+}
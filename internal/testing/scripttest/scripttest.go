@@ -14,6 +14,7 @@ import (
 
 	"strings"
 
+	"github.com/tmc/aistudio/api"
 	"github.com/tmc/aistudio/internal/httprr"
 )
 
@@ -102,6 +103,9 @@ func Run(t *testing.T, scriptPath string, record ...bool) {
 	if err != nil {
 		t.Fatalf("Failed to initialize HTTP record/replay with file %q for script %s: %v", recordPath, scriptBase, err)
 	}
+	// Keep API keys out of the recorded traces.
+	rr.ScrubReq(api.ScrubHTTPRequest)
+	rr.ScrubResp(api.ScrubHTTPResponse)
 	defer func() {
 		log.Printf("Closing HTTP record/replay transport for script %s (file: %s).", scriptBase, recordPath)
 		if err := rr.Close(); err != nil {
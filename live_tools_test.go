@@ -0,0 +1,38 @@
+package aistudio
+
+import (
+	"context"
+	"testing"
+)
+
+// TestCancelToolCalls tests that server cancellations stop running calls and prune approvals
+func TestCancelToolCalls(t *testing.T) {
+	m := &Model{
+		pendingToolCalls: []ToolCall{{ID: "a", Name: "read_file"}, {ID: "b", Name: "write_file"}},
+		showToolApproval: true,
+		approvalIndex:    1,
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	m.toolCancels = map[string]context.CancelCauseFunc{"run": cancel}
+	m.getOrCreateToolVM("run", func(vm *ToolCallViewModel) { vm.Name = "list_files" })
+
+	m.cancelToolCalls([]string{"a", "run"})
+
+	if ctx.Err() == nil {
+		t.Error("Expected running tool call to be cancelled")
+	}
+	if len(m.pendingToolCalls) != 1 || m.pendingToolCalls[0].ID != "b" || m.approvalIndex != 0 || !m.showToolApproval {
+		t.Errorf("Expected only b awaiting approval, got %+v (index %d)", m.pendingToolCalls, m.approvalIndex)
+	}
+	if cmd := m.handleToolCallResult(toolCallResultMsg{call: ToolCall{ID: "run", Name: "list_files"}}); cmd != nil {
+		t.Error("Expected the result of a cancelled call to be dropped")
+	}
+	if len(m.toolCancels) != 0 || m.processingTool {
+		t.Errorf("Expected no running tools, got %v", m.toolCancels)
+	}
+
+	m.cancelToolCalls([]string{"b"})
+	if m.showToolApproval || len(m.pendingToolCalls) != 0 {
+		t.Error("Expected approval modal to close once every pending call is cancelled")
+	}
+}
@@ -2,8 +2,6 @@ package aistudio
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -29,6 +27,7 @@ type MultimodalStreamingManager struct {
 	
 	// Streaming state
 	isStreaming      bool
+	talking          bool // Push-to-talk key is held
 	streamingContext context.Context
 	streamingCancel  context.CancelFunc
 	
@@ -70,6 +69,7 @@ type MultimodalConfig struct {
 	
 	// Streaming configuration
 	StreamingMode     string // "continuous", "voice_activated", "manual"
+	PushToTalk        bool   // Disable server VAD; audio is sent only between StartTalking and StopTalking
 	MaxBufferSize     int
 	EnableCompression bool
 	
@@ -78,6 +78,11 @@ type MultimodalConfig struct {
 	Temperature       float32
 	MaxOutputTokens   int32
 	SystemPrompt      string
+	
+	// Context window compression for long sessions (0 = server default)
+	ContextWindowCompression bool
+	CompressionTriggerTokens int64
+	CompressionTargetTokens  int64
 }
 
 // DefaultMultimodalConfig returns default configuration
@@ -143,14 +148,15 @@ func (msm *MultimodalStreamingManager) StartStreaming() error {
 		return fmt.Errorf("failed to handle window selection: %w", err)
 	}
 	
+	// Create streaming context
+	msm.streamingContext, msm.streamingCancel = context.WithCancel(context.Background())
+	
 	// Initialize live client
 	if err := msm.initializeLiveClient(); err != nil {
+		msm.streamingCancel()
 		return fmt.Errorf("failed to initialize live client: %w", err)
 	}
 	
-	// Create streaming context
-	msm.streamingContext, msm.streamingCancel = context.WithCancel(context.Background())
-	
 	// Start audio input if enabled
 	if msm.config.EnableAudio && msm.audioInputManager != nil {
 		if err := msm.audioInputManager.StartRecording(); err != nil {
@@ -217,6 +223,7 @@ func (msm *MultimodalStreamingManager) StopStreaming() error {
 	}
 	
 	msm.isStreaming = false
+	msm.talking = false
 	
 	duration := time.Since(msm.streamStartTime)
 	log.Printf("[MULTIMODAL] Stopped streaming after %v - sent %d audio chunks, %d image frames, %d bytes total", 
@@ -253,6 +260,13 @@ func (msm *MultimodalStreamingManager) initializeLiveClient() error {
 		TopK:            40,
 		MaxOutputTokens: msm.config.MaxOutputTokens,
 		SystemPrompt:    msm.config.SystemPrompt,
+
+		ManualActivityDetection: msm.config.PushToTalk,
+		AudioTranscription:      msm.config.EnableAudio,
+
+		ContextWindowCompression: msm.config.ContextWindowCompression,
+		CompressionTriggerTokens: msm.config.CompressionTriggerTokens,
+		CompressionTargetTokens:  msm.config.CompressionTargetTokens,
 	}
 	
 	// Create live client
@@ -290,13 +304,19 @@ func (msm *MultimodalStreamingManager) processAudioInput() {
 				return
 			}
 			
-			// Filter based on voice activity if enabled
-			if msm.config.AudioVADEnabled && !chunk.IsVoice {
+			// In push-to-talk mode only audio captured while talking is sent;
+			// otherwise filter based on voice activity if enabled
+			msm.mu.Lock()
+			if msm.config.PushToTalk && !msm.talking {
+				msm.mu.Unlock()
+				continue
+			}
+			if !msm.config.PushToTalk && msm.config.AudioVADEnabled && !chunk.IsVoice {
+				msm.mu.Unlock()
 				continue
 			}
 			
 			// Add to buffer
-			msm.mu.Lock()
 			if len(msm.audioBuffer) >= msm.config.MaxBufferSize {
 				// Remove oldest chunk
 				msm.audioBuffer = msm.audioBuffer[1:]
@@ -395,20 +415,11 @@ func (msm *MultimodalStreamingManager) sendAudioData() {
 		return
 	}
 	
-	// Create audio message for live API
-	audioMessage := map[string]interface{}{
-		"realtime_input": map[string]interface{}{
-			"media_chunks": []map[string]interface{}{
-				{
-					"data":      base64.StdEncoding.EncodeToString(combinedData),
-					"mime_type": "audio/pcm",
-				},
-			},
-		},
-	}
-	
-	// Send to live API
-	if err := msm.sendToLiveAPI(audioMessage); err != nil {
+	// Send to live API as realtime audio
+	mimeType := fmt.Sprintf("audio/pcm;rate=%d", msm.config.AudioConfig.SampleRate)
+	if err := msm.sendRealtime(func(c *api.LiveClient) error {
+		return c.SendRealtimeAudio(combinedData, mimeType)
+	}); err != nil {
 		log.Printf("[MULTIMODAL] Error sending audio data: %v", err)
 	} else {
 		msm.audioChunksSent++
@@ -435,21 +446,8 @@ func (msm *MultimodalStreamingManager) sendImageData() {
 	msm.imageBuffer = msm.imageBuffer[:0] // Clear buffer
 	msm.mu.Unlock()
 	
-	// Create image message for live API
-	mimeType := fmt.Sprintf("image/%s", frame.Format)
-	imageMessage := map[string]interface{}{
-		"realtime_input": map[string]interface{}{
-			"media_chunks": []map[string]interface{}{
-				{
-					"data":      base64.StdEncoding.EncodeToString(frame.Data),
-					"mime_type": mimeType,
-				},
-			},
-		},
-	}
-	
-	// Send to live API
-	if err := msm.sendToLiveAPI(imageMessage); err != nil {
+	// Send to live API as a realtime video frame
+	if err := msm.sendFrame(frame); err != nil {
 		log.Printf("[MULTIMODAL] Error sending image data: %v", err)
 	} else {
 		msm.imageFramesSent++
@@ -461,20 +459,80 @@ func (msm *MultimodalStreamingManager) sendImageData() {
 	}
 }
 
-// sendToLiveAPI sends data to the live API
-func (msm *MultimodalStreamingManager) sendToLiveAPI(message map[string]interface{}) error {
-	if msm.liveClient == nil {
+// sendRealtime runs send against the live client if one is connected
+func (msm *MultimodalStreamingManager) sendRealtime(send func(*api.LiveClient) error) error {
+	msm.mu.RLock()
+	liveClient := msm.liveClient
+	msm.mu.RUnlock()
+	if liveClient == nil {
 		return fmt.Errorf("live client not initialized")
 	}
+	return send(liveClient)
+}
+
+// sendFrame sends an image frame to the live API as realtime video
+func (msm *MultimodalStreamingManager) sendFrame(frame ImageFrame) error {
+	mimeType := fmt.Sprintf("image/%s", frame.Format)
+	return msm.sendRealtime(func(c *api.LiveClient) error {
+		return c.SendRealtimeVideo(frame.Data, mimeType)
+	})
+}
+
+// StartTalking begins a push-to-talk utterance: the server is told user
+// activity started and captured audio is forwarded until StopTalking.
+func (msm *MultimodalStreamingManager) StartTalking() error {
+	msm.mu.Lock()
+	if !msm.config.PushToTalk {
+		msm.mu.Unlock()
+		return fmt.Errorf("push-to-talk is not enabled")
+	}
+	if msm.talking {
+		msm.mu.Unlock()
+		return nil
+	}
+	msm.talking = true
+	msm.audioBuffer = msm.audioBuffer[:0] // Drop audio captured before the key press
+	msm.mu.Unlock()
 	
-	// Convert message to JSON
-	jsonData, err := json.Marshal(message)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+	if err := msm.sendRealtime((*api.LiveClient).ActivityStart); err != nil {
+		msm.mu.Lock()
+		msm.talking = false
+		msm.mu.Unlock()
+		return fmt.Errorf("failed to start activity: %w", err)
+	}
+	return nil
+}
+
+// StopTalking ends a push-to-talk utterance, flushing buffered audio before
+// telling the server the user has finished so the model responds.
+func (msm *MultimodalStreamingManager) StopTalking() error {
+	msm.mu.Lock()
+	if !msm.talking {
+		msm.mu.Unlock()
+		return nil
 	}
+	msm.talking = false
+	msm.mu.Unlock()
 	
-	// Send message
-	return msm.liveClient.SendRawMessage(jsonData)
+	msm.sendAudioData()
+	if err := msm.sendRealtime((*api.LiveClient).ActivityEnd); err != nil {
+		return fmt.Errorf("failed to end activity: %w", err)
+	}
+	return nil
+}
+
+// IsTalking reports whether a push-to-talk utterance is in progress
+func (msm *MultimodalStreamingManager) IsTalking() bool {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+	return msm.talking
+}
+
+// IsPushToTalk reports whether the manager uses push-to-talk mode
+func (msm *MultimodalStreamingManager) IsPushToTalk() bool {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+	return msm.config.PushToTalk
 }
 
 // processLiveResponses processes responses from the live API
@@ -530,6 +588,28 @@ func (msm *MultimodalStreamingManager) processLiveResponse(response *api.StreamO
 		}
 	}
 	
+	// Handle transcriptions of spoken input and output
+	if response.InputTranscription != "" && msm.uiUpdateChan != nil {
+		msm.uiUpdateChan <- MultimodalResponseMsg{
+			Type:    "input_transcription",
+			Content: response.InputTranscription,
+		}
+	}
+	if response.OutputTranscription != "" && msm.uiUpdateChan != nil {
+		msm.uiUpdateChan <- MultimodalResponseMsg{
+			Type:    "output_transcription",
+			Content: response.OutputTranscription,
+		}
+	}
+	
+	// Report session token usage
+	if response.TotalTokenCount > 0 && msm.uiUpdateChan != nil {
+		msm.uiUpdateChan <- MultimodalResponseMsg{
+			Type:        "usage",
+			TotalTokens: response.TotalTokenCount,
+		}
+	}
+	
 	// Handle function calls
 	if response.FunctionCall != nil {
 		if msm.uiUpdateChan != nil {
@@ -562,20 +642,7 @@ func (msm *MultimodalStreamingManager) SendImageFile(filename string) error {
 		return fmt.Errorf("failed to load image file: %w", err)
 	}
 	
-	// Create image message
-	mimeType := fmt.Sprintf("image/%s", frame.Format)
-	imageMessage := map[string]interface{}{
-		"realtime_input": map[string]interface{}{
-			"media_chunks": []map[string]interface{}{
-				{
-					"data":      base64.StdEncoding.EncodeToString(frame.Data),
-					"mime_type": mimeType,
-				},
-			},
-		},
-	}
-	
-	return msm.sendToLiveAPI(imageMessage)
+	return msm.sendFrame(frame)
 }
 
 // CaptureScreenNow captures and sends a screen shot immediately
@@ -589,20 +656,7 @@ func (msm *MultimodalStreamingManager) CaptureScreenNow() error {
 		return fmt.Errorf("failed to capture screen: %w", err)
 	}
 	
-	// Create image message
-	mimeType := fmt.Sprintf("image/%s", frame.Format)
-	imageMessage := map[string]interface{}{
-		"realtime_input": map[string]interface{}{
-			"media_chunks": []map[string]interface{}{
-				{
-					"data":      base64.StdEncoding.EncodeToString(frame.Data),
-					"mime_type": mimeType,
-				},
-			},
-		},
-	}
-	
-	return msm.sendToLiveAPI(imageMessage)
+	return msm.sendFrame(frame)
 }
 
 // GetStreamingStats returns current streaming statistics
@@ -667,6 +721,7 @@ type MultimodalResponseMsg struct {
 	Content      string
 	AudioData    []byte
 	FunctionCall *generativelanguagepb.FunctionCall
+	TotalTokens  int32 // Session token usage, for "usage" messages
 }
 type MultimodalStreamingStats struct {
 	IsStreaming     bool
@@ -707,6 +762,17 @@ func (msm *MultimodalStreamingManager) SetStreamingMode(mode string) error {
 	return fmt.Errorf("invalid streaming mode: %s (valid: %v)", mode, validModes)
 }
 
+// SetContextCompression changes context window compression. It applies to
+// the next live session, since compression is part of the session setup.
+func (msm *MultimodalStreamingManager) SetContextCompression(enabled bool, triggerTokens, targetTokens int64) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	
+	msm.config.ContextWindowCompression = enabled
+	msm.config.CompressionTriggerTokens = triggerTokens
+	msm.config.CompressionTargetTokens = targetTokens
+}
+
 // ToggleStreaming toggles streaming state
 func (msm *MultimodalStreamingManager) ToggleStreaming() error {
 	if msm.IsStreaming() {
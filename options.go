@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
 	"github.com/tmc/aistudio/api"
+	"github.com/tmc/aistudio/prompts"
 )
 
 // WithAPIKey sets the Google API Key for the client.
@@ -19,10 +22,46 @@ func WithAPIKey(key string) Option {
 			m.client = &api.Client{}
 		}
 		m.client.APIKey = key
+		api.RegisterSecret(key)
 		return nil
 	}
 }
 
+// WithAPIKeyCommand sets a shell command (e.g. "pass show gemini") whose output
+// is used as the API key for the given backend. The command runs once, when the
+// client is first initialized, and the key is only kept in memory.
+func WithAPIKeyCommand(backend BackendType, command string) Option {
+	return func(m *Model) error {
+		if command == "" {
+			return nil
+		}
+		src := m.apiKeySources[backend]
+		src.Command = command
+		return m.setAPIKeySource(backend, src)
+	}
+}
+
+// WithAPIKeyFromKeyring looks up the API key for the given backend in the OS
+// keyring. Empty service and account default to "aistudio" and the backend name.
+func WithAPIKeyFromKeyring(backend BackendType, service, account string) Option {
+	return func(m *Model) error {
+		src := m.apiKeySources[backend]
+		src.Keyring = true
+		src.KeyringService = service
+		src.KeyringAccount = account
+		return m.setAPIKeySource(backend, src)
+	}
+}
+
+// setAPIKeySource records the key source for a backend.
+func (m *Model) setAPIKeySource(backend BackendType, src api.KeySource) error {
+	if m.apiKeySources == nil {
+		m.apiKeySources = make(map[BackendType]api.KeySource)
+	}
+	m.apiKeySources[backend] = src
+	return nil
+}
+
 // WithBackend explicitly sets the backend type (Gemini API or Vertex AI).
 func WithBackend(backend BackendType) Option {
 	return func(m *Model) error {
@@ -113,6 +152,15 @@ func WithListModels(filter string, apiVersions ...string) Option {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		// Resolve the key up front so every listing path below sees it
+		if m.client.APIKey == "" {
+			key, err := api.ResolveAPIKey(ctx, m.backend.apiBackend(), m.apiKeySources[m.backend])
+			if err != nil {
+				return err
+			}
+			m.client.APIKey = key
+		}
+
 		var models []string
 		var err error
 
@@ -310,6 +358,58 @@ func WithHistory(enabled bool, historyDir string) Option {
 	}
 }
 
+// WithInputHistory persists sent prompts to path so they can be recalled
+// with up/down in later sessions. An empty path keeps history in memory only.
+func WithInputHistory(path string) Option {
+	return func(m *Model) error {
+		h, err := NewInputHistory(path)
+		if err != nil {
+			return err
+		}
+		m.inputHistory = h
+		return nil
+	}
+}
+
+// WithPromptFile loads the contents of a file into the input as the initial
+// draft, ready to be reviewed or edited before sending.
+func WithPromptFile(path string) Option {
+	return func(m *Model) error {
+		if path == "" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		m.textarea.SetValue(strings.TrimRight(string(data), "\r\n"))
+		log.Printf("Loaded prompt draft from file: %s", path)
+		return nil
+	}
+}
+
+// WithInitialDraft places text in the input as the initial draft.
+func WithInitialDraft(text string) Option {
+	return func(m *Model) error {
+		m.textarea.SetValue(text)
+		return nil
+	}
+}
+
+// WithPromptLibrary loads prompt templates from dirs. Later directories
+// override templates of the same name from earlier ones.
+func WithPromptLibrary(dirs ...string) Option {
+	return func(m *Model) error {
+		lib, err := prompts.LoadLibrary(dirs...)
+		if err != nil {
+			return fmt.Errorf("failed to load prompt templates: %w", err)
+		}
+		m.promptLibrary = lib
+		log.Printf("Loaded %d prompt templates", lib.Len())
+		return nil
+	}
+}
+
 // WithTools enables or disables tool calling support.
 func WithTools(enabled bool) Option {
 	return func(m *Model) error {
@@ -331,7 +431,7 @@ func WithToolApproval(requireApproval bool) Option {
 	}
 }
 
-// WithToolsFile loads tools from a JSON file.
+// WithToolsFile loads tools from a JSON file, reloading them when it changes.
 func WithToolsFile(filePath string) Option {
 	return func(m *Model) error {
 		if !m.enableTools {
@@ -350,8 +450,8 @@ func WithToolsFile(filePath string) Option {
 			NewAdvancedToolsRegistry(m.toolManager)
 		}
 
-		err := LoadToolsFromFile(filePath, m.toolManager)
-		if err != nil {
+		// The file is watched, and its tools reloaded when it changes
+		if _, err := m.addToolSource(m.toolsFileSource(filePath)); err != nil {
 			return fmt.Errorf("failed to load tools from file: %w", err)
 		}
 
@@ -360,6 +460,154 @@ func WithToolsFile(filePath string) Option {
 	}
 }
 
+// WithToolDiscovery registers the aistudio-tool-* executables found in dir
+// and on PATH, asking each to --describe itself. Tools already registered,
+// such as those from a tools file, take precedence. The tools are discovered
+// again when the executables in dir change.
+func WithToolDiscovery(dir string) Option {
+	return func(m *Model) error {
+		if !m.enableTools {
+			return nil
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+
+		// dir is watched, and the tools discovered again when it changes
+		changes, err := m.addToolSource(toolsDirSource(dir))
+		if err != nil {
+			return err
+		}
+		log.Printf("Registered %d discovered tool executables", len(changes.added))
+		return nil
+	}
+}
+
+// WithToolConcurrency sets how many tool calls from one model turn may run at
+// once. Calls to tools that are not parallel-safe always run alone.
+func WithToolConcurrency(n int) Option {
+	return func(m *Model) error {
+		if !m.enableTools {
+			return nil
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+
+		m.toolManager.SetConcurrency(n)
+		return nil
+	}
+}
+
+// WithWorkspace registers the built-in file tools (read_file, write_file,
+// edit_file, glob, grep and list_directory), confined to the directory root.
+func WithWorkspace(root string) Option {
+	return func(m *Model) error {
+		if !m.enableTools {
+			return nil
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+
+		ws, err := NewWorkspace(root)
+		if err != nil {
+			return err
+		}
+		m.toolManager.SetWorkspace(ws)
+		return m.toolManager.RegisterFileTools()
+	}
+}
+
+// WithWebFetch registers the web_fetch tool, limited to the allowDomains
+// hosts and their subdomains, or to any public host when allowDomains is
+// empty.
+func WithWebFetch(allowDomains []string) Option {
+	return func(m *Model) error {
+		if !m.enableTools {
+			return nil
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+
+		return m.toolManager.RegisterWebFetchTool(NewWebFetcher(allowDomains))
+	}
+}
+
+// WithAgentTool registers the dispatch_agent tool, which hands a task to a
+// sub-agent with the read-only file and web tools. The sub-agent uses model,
+// or the conversation's model when model is empty and that is not a Live
+// model, and makes at most maxSteps model requests (0 for the default). It
+// takes the conversation's settings from the options before it.
+func WithAgentTool(model string, maxSteps int) Option {
+	return func(m *Model) error {
+		if !m.enableTools {
+			return nil
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+		if _, ok := m.toolManager.RegisteredTools["dispatch_agent"]; ok {
+			return nil // The tools file's definition wins
+		}
+		if m.client == nil {
+			m.client = &api.Client{}
+		}
+
+		// The tool runs outside the UI, so it gets its own copy of the settings
+		client, config, tools := m.client, m.agentConfig(model), m.toolManager
+		return tools.RegisterAgentTool(func() *SubAgent {
+			return &SubAgent{
+				Client:   client,
+				Config:   config,
+				Tools:    tools.readOnlyTools(),
+				MaxSteps: maxSteps,
+			}
+		})
+	}
+}
+
+// WithToolResultLimit stores tool results larger than limit bytes as
+// artifacts in the user cache directory, sending the model a preview and the
+// artifact ID instead, and registers the read_artifact tool for reading the
+// rest. A limit of 0 sends results whole; other limits must be at least 4 KiB.
+func WithToolResultLimit(limit int) Option {
+	return func(m *Model) error {
+		if !m.enableTools || limit == 0 {
+			return nil
+		}
+		if limit < artifactPreviewBytes {
+			return fmt.Errorf("tool result limit must be 0 or at least %d bytes, got %d", artifactPreviewBytes, limit)
+		}
+
+		if m.toolManager == nil {
+			m.toolManager = NewToolManager()
+			NewAdvancedToolsRegistry(m.toolManager)
+		}
+
+		dir := DefaultArtifactDir()
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "aistudio-artifacts")
+		}
+		store := NewArtifactStore(dir)
+		if err := store.Prune(artifactMaxAge); err != nil {
+			log.Printf("Warning: failed to remove old artifacts: %v", err)
+		}
+		return m.toolManager.StoreLargeResults(store, limit)
+	}
+}
+
 // WithSystemPrompt sets a system prompt for the conversation.
 func WithSystemPrompt(prompt string) Option {
 	return func(m *Model) error {
@@ -425,9 +673,71 @@ func WithResponseMimeType(mimeType string) Option {
 }
 
 // WithResponseSchema sets a schema for the response structure from a file.
+// Responses are validated against it when the model finishes answering.
 func WithResponseSchema(schemaFile string) Option {
 	return func(m *Model) error {
+		schema, err := api.LoadResponseSchema(schemaFile)
+		if err != nil {
+			return err
+		}
 		m.responseSchemaFile = schemaFile
+		m.responseSchema = schema
+		return nil
+	}
+}
+
+// WithSchemaRetries sets how many times a response that fails schema
+// validation is sent back to the model, with the problems, to be fixed.
+func WithSchemaRetries(retries int) Option {
+	return func(m *Model) error {
+		if retries < 0 {
+			return fmt.Errorf("schema retries cannot be negative, got %d", retries)
+		}
+		m.schemaRetries = retries
+		return nil
+	}
+}
+
+// WithSafetySettings sets per-category block thresholds for generated content.
+// Later settings for the same category replace earlier ones.
+func WithSafetySettings(settings ...*generativelanguagepb.SafetySetting) Option {
+	return func(m *Model) error {
+		for _, setting := range settings {
+			if setting == nil {
+				continue
+			}
+			replaced := false
+			for i, existing := range m.safetySettings {
+				if existing.Category == setting.Category {
+					m.safetySettings[i] = setting
+					replaced = true
+				}
+			}
+			if !replaced {
+				m.safetySettings = append(m.safetySettings, setting)
+			}
+		}
+		return nil
+	}
+}
+
+// WithThinkingBudget sets how many tokens 2.5 models may spend thinking
+// before answering. Zero disables thinking and -1 lets the model decide.
+func WithThinkingBudget(budget int32) Option {
+	return func(m *Model) error {
+		if budget < -1 {
+			return fmt.Errorf("thinking budget must be -1 (dynamic) or greater, got %d", budget)
+		}
+		m.thinkingBudget = &budget
+		return nil
+	}
+}
+
+// WithThoughts asks the model for summaries of its thinking, shown in a
+// collapsible block above each answer.
+func WithThoughts(enabled bool) Option {
+	return func(m *Model) error {
+		m.includeThoughts = enabled
 		return nil
 	}
 }
@@ -469,6 +779,33 @@ func WithAudioInput(enabled bool, config AudioInputConfig) Option {
 	}
 }
 
+// WithContextCompression enables sliding-window context compression for live
+// sessions. Zero token counts use the server defaults.
+func WithContextCompression(enabled bool, triggerTokens, targetTokens int64) Option {
+	return func(m *Model) error {
+		if triggerTokens < 0 || targetTokens < 0 {
+			return fmt.Errorf("compression token counts must not be negative")
+		}
+		if triggerTokens > 0 && targetTokens >= triggerTokens {
+			return fmt.Errorf("compression target (%d) must be below the trigger (%d)", targetTokens, triggerTokens)
+		}
+		m.contextCompression = enabled
+		m.compressionTriggerTokens = triggerTokens
+		m.compressionTargetTokens = targetTokens
+		return nil
+	}
+}
+
+// WithPushToTalk switches live audio input from automatic voice activity
+// detection to push-to-talk, where the user marks the start and end of
+// each utterance.
+func WithPushToTalk(enabled bool) Option {
+	return func(m *Model) error {
+		m.multimodalConfig.PushToTalk = enabled
+		return nil
+	}
+}
+
 // WithImageCapture enables image capture with the specified configuration.
 func WithImageCapture(enabled bool, config ImageCaptureConfig) Option {
 	return func(m *Model) error {
@@ -492,3 +492,39 @@ func TestOptionErrors(t *testing.T) {
 		t.Errorf("Expected API key to be set despite option error")
 	}
 }
+
+// TestWithAPIKeySources tests per-backend api_key_command and keyring options
+func TestWithAPIKeySources(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	model := New(
+		WithGrok(true),
+		WithAPIKeyCommand(BackendGeminiAPI, "pass show gemini"),
+		WithAPIKeyCommand(BackendGrok, "pass show grok"),
+		WithAPIKeyFromKeyring(BackendGrok, "", ""),
+	)
+
+	if got := model.apiKeySources[BackendGeminiAPI].Command; got != "pass show gemini" {
+		t.Errorf("Expected Gemini key command, got %q", got)
+	}
+	grok := model.apiKeySources[BackendGrok]
+	if grok.Command != "pass show grok" || !grok.Keyring {
+		t.Errorf("Expected Grok command and keyring, got %+v", grok)
+	}
+	if model.apiKey != "" {
+		t.Errorf("Expected API key to stay unresolved until client init, got %q", model.apiKey)
+	}
+}
+
+// TestWithPushToTalk tests push-to-talk survives the multimodal config it
+// follows, as the command line applies them
+func TestWithPushToTalk(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	model := New(WithMultimodalConfig(DefaultMultimodalConfig()), WithPushToTalk(true))
+	if !model.multimodalConfig.PushToTalk {
+		t.Error("Expected push-to-talk to be enabled")
+	}
+}
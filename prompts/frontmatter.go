@@ -0,0 +1,154 @@
+package prompts
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// frontMatterDelimiter opens and closes the metadata block of a template file.
+const frontMatterDelimiter = "---"
+
+// splitFrontMatter separates the metadata block from the template body.
+// Files without front-matter are returned as body only.
+func splitFrontMatter(data string) (header, body string, err error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	if !strings.HasPrefix(data, frontMatterDelimiter+"\n") {
+		return "", data, nil
+	}
+	rest := data[len(frontMatterDelimiter)+1:]
+	if strings.HasPrefix(rest, frontMatterDelimiter+"\n") || rest == frontMatterDelimiter {
+		return "", strings.TrimPrefix(rest[len(frontMatterDelimiter):], "\n"), nil
+	}
+	end := strings.Index(rest, "\n"+frontMatterDelimiter+"\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n"+frontMatterDelimiter) {
+			return rest[:len(rest)-len(frontMatterDelimiter)-1], "", nil
+		}
+		return "", "", fmt.Errorf("unterminated front-matter")
+	}
+	return rest[:end], rest[end+len(frontMatterDelimiter)+2:], nil
+}
+
+// parseFrontMatter reads the small YAML subset used by templates:
+//
+//	name: code-review
+//	description: Review a change
+//	model: models/gemini-2.5-pro
+//	temperature: 0.2
+//	variables:
+//	  - name: language
+//	    description: Programming language
+//	    default: Go
+//	  - diff
+//
+// Scalars may be quoted. Unknown keys are ignored so files can carry extra
+// metadata for other tools.
+func parseFrontMatter(header string, t *Template) error {
+	scanner := bufio.NewScanner(strings.NewReader(header))
+	inVariables := false
+	var current *Variable
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+
+		if inVariables && indented {
+			if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+				t.Variables = append(t.Variables, Variable{})
+				current = &t.Variables[len(t.Variables)-1]
+				trimmed = strings.TrimSpace(item)
+				if !strings.Contains(trimmed, ":") {
+					// Short form: a bare variable name
+					current.Name = unquote(trimmed)
+					continue
+				}
+			}
+			if current == nil {
+				return fmt.Errorf("line %d: expected a list item under variables", lineNum)
+			}
+			key, value, ok := cutKeyValue(trimmed)
+			if !ok {
+				return fmt.Errorf("line %d: expected key: value", lineNum)
+			}
+			switch key {
+			case "name":
+				current.Name = value
+			case "description":
+				current.Description = value
+			case "default":
+				current.Default = value
+				current.HasDefault = true
+			}
+			continue
+		}
+
+		inVariables = false
+		current = nil
+		key, value, ok := cutKeyValue(trimmed)
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", lineNum)
+		}
+		switch key {
+		case "name":
+			t.Name = value
+		case "description":
+			t.Description = value
+		case "model":
+			t.Model = value
+		case "temperature":
+			temp, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid temperature %q: %w", lineNum, value, err)
+			}
+			f := float32(temp)
+			t.Temperature = &f
+		case "variables":
+			if value != "" && value != "[]" {
+				return fmt.Errorf("line %d: variables must be a list", lineNum)
+			}
+			inVariables = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for i, v := range t.Variables {
+		if v.Name == "" {
+			return fmt.Errorf("variable %d has no name", i+1)
+		}
+	}
+	return nil
+}
+
+// cutKeyValue splits "key: value" and unquotes the value.
+func cutKeyValue(s string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(s, ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), unquote(strings.TrimSpace(value)), true
+}
+
+// unquote strips YAML-style single or double quotes from a scalar.
+func unquote(s string) string {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		case s[0] == '\'' && s[len(s)-1] == '\'':
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+		}
+	}
+	return s
+}
@@ -0,0 +1,218 @@
+// Package prompts implements a library of reusable prompt templates.
+//
+// A template is a text file with optional front-matter describing its name,
+// variables and generation overrides, followed by a text/template body:
+//
+//	---
+//	name: summarize-log
+//	description: Summarize a log file
+//	temperature: 0.2
+//	variables:
+//	  - name: log
+//	  - name: focus
+//	    default: errors
+//	---
+//	Summarize the following log, focusing on {{.focus}}:
+//
+//	{{.log}}
+package prompts
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateExtensions are the file types loaded into a library.
+var templateExtensions = map[string]bool{".md": true, ".txt": true, ".tmpl": true, ".prompt": true}
+
+// Variable is a value substituted into a template body.
+type Variable struct {
+	Name        string
+	Description string
+	Default     string
+	HasDefault  bool // Distinguishes an empty default from a required variable
+}
+
+// Required reports whether a value must be supplied for the variable.
+func (v Variable) Required() bool {
+	return !v.HasDefault
+}
+
+// Template is a reusable prompt.
+type Template struct {
+	Name        string
+	Description string
+	Variables   []Variable
+	Model       string   // Overrides the session model when set
+	Temperature *float32 // Overrides the session temperature when set
+	Body        string
+	Path        string // File the template was loaded from, if any
+}
+
+// Parse reads a template from data. The name defaults to fallbackName when
+// the front-matter does not set one.
+func Parse(data []byte, fallbackName string) (*Template, error) {
+	header, body, err := splitFrontMatter(string(data))
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{Body: body}
+	if err := parseFrontMatter(header, t); err != nil {
+		return nil, fmt.Errorf("invalid front-matter: %w", err)
+	}
+	if t.Name == "" {
+		t.Name = fallbackName
+	}
+	if t.Name == "" {
+		return nil, fmt.Errorf("template has no name")
+	}
+	if _, err := t.parseBody(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadFile reads a template from path, naming it after the file if the
+// front-matter does not.
+func LoadFile(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	t, err := Parse(data, name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	t.Path = path
+	return t, nil
+}
+
+func (t *Template) parseBody() (*template.Template, error) {
+	tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template body: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render substitutes vars into the body. Defaults fill in missing values and
+// an error lists any required variables that were not supplied.
+func (t *Template) Render(vars map[string]string) (string, error) {
+	values := make(map[string]string, len(t.Variables)+len(vars))
+	var missing []string
+	for _, v := range t.Variables {
+		if val, ok := vars[v.Name]; ok {
+			values[v.Name] = val
+		} else if v.HasDefault {
+			values[v.Name] = v.Default
+		} else {
+			missing = append(missing, v.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %q is missing variables: %s", t.Name, strings.Join(missing, ", "))
+	}
+	// Values for undeclared variables are still available to the body
+	for k, val := range vars {
+		if _, ok := values[k]; !ok {
+			values[k] = val
+		}
+	}
+
+	tmpl, err := t.parseBody()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, values); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", t.Name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Library is a set of templates indexed by name.
+type Library struct {
+	templates map[string]*Template
+}
+
+// LoadLibrary loads every template file in dirs. Missing directories are
+// skipped, and so are malformed templates, with a warning logged, so one bad
+// file does not hide the rest. When two templates share a name the one from
+// the later directory wins, so a project directory can override the user's
+// library.
+func LoadLibrary(dirs ...string) (*Library, error) {
+	lib := &Library{templates: make(map[string]*Template)}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !templateExtensions[filepath.Ext(entry.Name())] {
+				continue
+			}
+			t, err := LoadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				log.Printf("Warning: skipping prompt template %s: %v", entry.Name(), err)
+				continue
+			}
+			lib.templates[t.Name] = t
+		}
+	}
+	return lib, nil
+}
+
+// Get returns the template with the given name.
+func (l *Library) Get(name string) (*Template, bool) {
+	t, ok := l.templates[name]
+	return t, ok
+}
+
+// List returns all templates sorted by name.
+func (l *Library) List() []*Template {
+	list := make([]*Template, 0, len(l.templates))
+	for _, t := range l.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Len returns the number of templates in the library.
+func (l *Library) Len() int {
+	return len(l.templates)
+}
+
+// DefaultDir returns the per-user template directory.
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "aistudio", "prompts")
+}
+
+// ParseVars parses k=v pairs as given on the command line.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected name=value", pair)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
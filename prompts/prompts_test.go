@@ -0,0 +1,117 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reviewTemplate = `---
+name: code-review
+description: "Review a change"
+model: models/gemini-2.5-pro
+temperature: 0.2
+variables:
+  - name: language
+    description: Programming language
+    default: Go
+  - diff
+---
+Review this {{.language}} change:
+
+{{.diff}}
+`
+
+// TestParseTemplate tests front-matter parsing
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := Parse([]byte(reviewTemplate), "fallback")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if tmpl.Name != "code-review" || tmpl.Description != "Review a change" {
+		t.Errorf("Unexpected name/description: %q, %q", tmpl.Name, tmpl.Description)
+	}
+	if tmpl.Model != "models/gemini-2.5-pro" {
+		t.Errorf("Unexpected model: %q", tmpl.Model)
+	}
+	if tmpl.Temperature == nil || *tmpl.Temperature != 0.2 {
+		t.Errorf("Unexpected temperature: %v", tmpl.Temperature)
+	}
+	if len(tmpl.Variables) != 2 {
+		t.Fatalf("Expected 2 variables, got %d", len(tmpl.Variables))
+	}
+	if v := tmpl.Variables[0]; v.Name != "language" || v.Default != "Go" || v.Required() {
+		t.Errorf("Unexpected first variable: %+v", v)
+	}
+	if v := tmpl.Variables[1]; v.Name != "diff" || !v.Required() {
+		t.Errorf("Unexpected second variable: %+v", v)
+	}
+}
+
+// TestRenderTemplate tests variable substitution, defaults and missing values
+func TestRenderTemplate(t *testing.T) {
+	tmpl, err := Parse([]byte(reviewTemplate), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if _, err := tmpl.Render(nil); err == nil || !strings.Contains(err.Error(), "diff") {
+		t.Errorf("Expected missing variable error naming diff, got %v", err)
+	}
+
+	got, err := tmpl.Render(map[string]string{"diff": "+x := 1"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Review this Go change:\n\n+x := 1"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// TestLoadLibrary tests loading templates from directories
+func TestLoadLibrary(t *testing.T) {
+	userDir := t.TempDir()
+	projectDir := t.TempDir()
+	write := func(dir, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(userDir, "review.md", reviewTemplate)
+	write(userDir, "summarize.txt", "Summarize: {{.text}}")
+	write(userDir, "notes.json", "{}")
+	write(projectDir, "summarize.md", "---\ndescription: project override\n---\nTL;DR {{.text}}")
+	write(projectDir, "broken.md", "Unclosed {{.text")
+
+	lib, err := LoadLibrary(userDir, projectDir, filepath.Join(userDir, "missing"))
+	if err != nil {
+		t.Fatalf("LoadLibrary failed: %v", err)
+	}
+	if lib.Len() != 2 {
+		t.Fatalf("Expected 2 templates, skipping the broken one, got %d", lib.Len())
+	}
+	list := lib.List()
+	if list[0].Name != "code-review" || list[1].Name != "summarize" {
+		t.Errorf("Unexpected template order: %q, %q", list[0].Name, list[1].Name)
+	}
+	tmpl, ok := lib.Get("summarize")
+	if !ok || tmpl.Description != "project override" {
+		t.Errorf("Expected project template to override user template, got %+v", tmpl)
+	}
+}
+
+// TestParseVars tests command line variable parsing
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"a=1", "b=x=y", "c="})
+	if err != nil {
+		t.Fatalf("ParseVars failed: %v", err)
+	}
+	if vars["a"] != "1" || vars["b"] != "x=y" || vars["c"] != "" {
+		t.Errorf("Unexpected vars: %v", vars)
+	}
+	if _, err := ParseVars([]string{"novalue"}); err == nil {
+		t.Error("Expected error for missing '='")
+	}
+}
@@ -199,7 +199,9 @@ func (r *MessageRenderer) formatMessageText(msg Message, messageIndex int) strin
 		!msg.IsToolCall() &&
 		!msg.IsToolResponse() &&
 		!msg.IsExecutableCode &&
-		!msg.IsExecutableCodeResult {
+		!msg.IsExecutableCodeResult &&
+		msg.BlockReason == "" &&
+		msg.Thoughts == "" {
 		return "" // Skip empty messages that don't have special formatting
 	}
 
@@ -211,10 +213,9 @@ func (r *MessageRenderer) formatMessageText(msg Message, messageIndex int) strin
 	switch {
 	case msg.HasAudio:
 		r.formatAudioMessage(&finalMsg, msg, messageIndex)
-	case msg.ToolCall != nil:
-		finalMsg.WriteString(msg.Content) // Pre-formatted content
-	case msg.ToolResponse != nil:
+	case msg.ToolCall != nil, msg.ToolResponse != nil:
 		finalMsg.WriteString(msg.Content) // Pre-formatted content
+		r.formatToolOutput(&finalMsg, msg)
 	case msg.IsExecutableCode:
 		r.formatExecutableCodeMessage(&finalMsg, msg)
 	case msg.IsExecutableCodeResult:
@@ -230,9 +231,9 @@ func (r *MessageRenderer) formatMessageText(msg Message, messageIndex int) strin
 func (r *MessageRenderer) formatMessageHeader(msg Message, messageIndex int) string {
 	var senderStyle lipgloss.Style
 	switch msg.Sender {
-	case senderNameUser:
+	case senderNameUser, senderNameUserSpoken:
 		senderStyle = senderUserStyle
-	case senderNameModel:
+	case senderNameModel, senderNameModelSpoken:
 		senderStyle = senderModelStyle
 	default:
 		senderStyle = senderSystemStyle
@@ -327,22 +328,124 @@ func (r *MessageRenderer) formatExecutableCodeResultMessage(finalMsg *strings.Bu
 	}
 }
 
+// formatToolOutput shows the progress lines a tool call reported, such as
+// the transcript of a sub-agent, collapsed to a one-line summary unless
+// expanded with Ctrl+Y
+func (r *MessageRenderer) formatToolOutput(finalMsg *strings.Builder, msg Message) {
+	if len(msg.ToolOutput) == 0 {
+		return
+	}
+	label := "Output"
+	if msg.ToolCall != nil && msg.ToolCall.Name == "dispatch_agent" {
+		label = "Transcript"
+	}
+
+	if !r.model.detailsExpanded {
+		finalMsg.WriteString(toolIdStyle.Render(fmt.Sprintf("▸ %s (%d lines) Ctrl+Y to expand", label, len(msg.ToolOutput))))
+		finalMsg.WriteString("\n")
+		return
+	}
+
+	finalMsg.WriteString(toolIdStyle.Render(fmt.Sprintf("▾ %s Ctrl+Y to collapse", label)))
+	for _, line := range msg.ToolOutput {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(toolIdStyle.Render("│ " + line))
+	}
+	finalMsg.WriteString("\n")
+}
+
 // formatDefaultMessage formats a regular message
 func (r *MessageRenderer) formatDefaultMessage(finalMsg *strings.Builder, msg Message) {
-	if msg.Content != "" {
-		finalMsg.WriteString(msg.Content)
+	r.formatThoughts(finalMsg, msg) // thoughts.go
+	if msg.SchemaChecked {
+		r.formatStructuredOutput(finalMsg, msg) // structured_output.go
+	} else if msg.Content != "" {
+		if msg.HasGroundingMetadata {
+			finalMsg.WriteString(annotateCitations(msg.Content, msg.GroundingMetadata)) // citations.go
+		} else {
+			finalMsg.WriteString(msg.Content)
+		}
 	}
+	r.formatGenerationStatus(finalMsg, msg)
+	r.formatBlocked(finalMsg, msg)
+	r.formatGrounding(finalMsg, msg)
 	r.formatTokenCounts(finalMsg, msg)
 }
 
+// formatGenerationStatus notes stopped responses and which alternate is shown
+func (r *MessageRenderer) formatGenerationStatus(finalMsg *strings.Builder, msg Message) {
+	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Italic(true)
+	if msg.Stopped {
+		finalMsg.WriteString(" ")
+		finalMsg.WriteString(statusStyle.Render("[stopped]"))
+	}
+	if len(msg.Alternates) > 1 {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(statusStyle.Render(fmt.Sprintf("‹ %d/%d › Ctrl+←/→ to switch answers",
+			msg.AlternateIndex+1, len(msg.Alternates))))
+	}
+}
+
+// formatBlocked explains why a prompt or response was blocked, listing the
+// safety ratings that were reported with it
+func (r *MessageRenderer) formatBlocked(finalMsg *strings.Builder, msg Message) {
+	if msg.BlockReason == "" {
+		return
+	}
+	blockedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	ratingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
+	if msg.Content != "" {
+		finalMsg.WriteString("\n")
+	}
+	finalMsg.WriteString(blockedStyle.Render("⛔ Blocked: " + msg.BlockReason))
+	for _, rating := range msg.SafetyRatings {
+		line := fmt.Sprintf("%s: %s", rating.Category, rating.Probability)
+		if rating.Blocked {
+			line += " (blocked)"
+		}
+		finalMsg.WriteString("\n  ")
+		finalMsg.WriteString(ratingStyle.Render(line))
+	}
+	finalMsg.WriteString("\n")
+}
+
+// formatGrounding lists the sources cited by footnote markers and the
+// searches the model ran to find them
+func (r *MessageRenderer) formatGrounding(finalMsg *strings.Builder, msg Message) {
+	gm := msg.GroundingMetadata
+	if !msg.HasGroundingMetadata || gm == nil {
+		return
+	}
+	sourceStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
+	if sources := citationSources(gm); len(sources) > 0 {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(dimStyle.Render("Sources:"))
+		for _, line := range sources {
+			finalMsg.WriteString("\n  ")
+			finalMsg.WriteString(sourceStyle.Render(line))
+		}
+	}
+	if len(gm.WebSearchQueries) > 0 {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(dimStyle.Render("Searched: " + strings.Join(gm.WebSearchQueries, " · ")))
+	}
+	finalMsg.WriteString("\n")
+}
+
 // formatTokenCounts formats token count information
 func (r *MessageRenderer) formatTokenCounts(finalMsg *strings.Builder, msg Message) {
 	if msg.TokenCounts != nil {
 		tokenStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
-		finalMsg.WriteString(tokenStyle.Render(
-			fmt.Sprintf("Token Counts: %d (Input) / %d (Output)",
-				msg.TokenCounts.PromptTokenCount,
-				msg.TokenCounts.ResponseTokenCount)))
+		counts := fmt.Sprintf("Token Counts: %d (Input) / %d (Output)",
+			msg.TokenCounts.PromptTokenCount,
+			msg.TokenCounts.ResponseTokenCount)
+		if msg.TokenCounts.ThoughtsTokenCount > 0 {
+			counts += fmt.Sprintf(" / %d (Thinking)", msg.TokenCounts.ThoughtsTokenCount)
+		}
+		finalMsg.WriteString(tokenStyle.Render(counts))
 		finalMsg.WriteString("\n")
 	}
 }
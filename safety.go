@@ -0,0 +1,54 @@
+package aistudio
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"github.com/tmc/aistudio/api"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// showBlockedResponse records why the prompt or response was blocked on the
+// current model message, adding one when the turn produced no text, so a
+// blocked turn explains itself instead of rendering as an empty response.
+func (m *Model) showBlockedResponse(output api.StreamOutput) tea.Cmd {
+	reason := output.BlockedReason()
+	if reason == "" {
+		return nil
+	}
+	return m.markBlocked(reason, output.SafetyRatings)
+}
+
+// markBlocked records a block reason and the ratings behind it on the current
+// model message, as described for showBlockedResponse.
+func (m *Model) markBlocked(reason string, ratings []*generativelanguagepb.SafetyRating) tea.Cmd {
+	idx := len(m.messages) - 1
+	if idx < 0 || m.messages[idx].Sender != senderNameModel ||
+		m.messages[idx].IsToolCall() || m.messages[idx].BlockReason != "" {
+		m.messages = append(m.messages, formatMessage(senderNameModel, ""))
+		idx++
+	}
+	m.messages[idx].BlockReason = reason
+	m.messages[idx].SafetyRatings = NewAPIFormatter().ConvertSafetyRatings(ratings)
+	m.viewport.GotoBottom()
+
+	if m.historyEnabled && m.historyManager != nil {
+		return m.saveSessionCmd()
+	}
+	return nil
+}
+
+// describeBlock summarizes a block reason and the ratings that were elevated
+// or caused the block, for plain-text output.
+func describeBlock(reason string, ratings []*SafetyRating) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", reason)
+	for _, r := range ratings {
+		if r.Blocked || (r.Probability != "NEGLIGIBLE" && r.Probability != "LOW") {
+			fmt.Fprintf(&b, " [%s: %s]", r.Category, r.Probability)
+		}
+	}
+	return b.String()
+}
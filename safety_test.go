@@ -0,0 +1,58 @@
+package aistudio
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"github.com/tmc/aistudio/api"
+)
+
+// TestShowBlockedResponse tests a blocked turn renders its reason and ratings
+func TestShowBlockedResponse(t *testing.T) {
+	m := &Model{width: 80, messages: []Message{formatMessage(senderNameUser, "hello")}}
+	m.showBlockedResponse(api.StreamOutput{
+		FinishReason: generativelanguagepb.Candidate_SAFETY,
+		SafetyRatings: []*generativelanguagepb.SafetyRating{
+			{Category: generativelanguagepb.HarmCategory_HARM_CATEGORY_HARASSMENT, Probability: generativelanguagepb.SafetyRating_NEGLIGIBLE},
+			{Category: generativelanguagepb.HarmCategory_HARM_CATEGORY_DANGEROUS_CONTENT, Probability: generativelanguagepb.SafetyRating_HIGH, Blocked: true},
+		},
+	})
+
+	if len(m.messages) != 2 || m.messages[1].Sender != senderNameModel {
+		t.Fatalf("Expected a model message for the blocked turn, got %+v", m.messages)
+	}
+	msg := m.messages[1]
+	if msg.BlockReason != "response blocked: SAFETY" || len(msg.SafetyRatings) != 2 {
+		t.Errorf("Unexpected blocked message: %+v", msg)
+	}
+
+	rendered := NewMessageRenderer(m).formatMessageText(msg, 1)
+	for _, want := range []string{"Blocked: response blocked: SAFETY", "DANGEROUS_CONTENT: HIGH (blocked)", "HARASSMENT: NEGLIGIBLE"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("Rendered message missing %q:\n%s", want, rendered)
+		}
+	}
+
+	if got := describeBlock(msg.BlockReason, msg.SafetyRatings); got != "[response blocked: SAFETY] [DANGEROUS_CONTENT: HIGH]" {
+		t.Errorf("describeBlock() = %q", got)
+	}
+}
+
+// TestWithSafetySettings tests later settings replace earlier ones per category
+func TestWithSafetySettings(t *testing.T) {
+	m := &Model{}
+	harassment := generativelanguagepb.HarmCategory_HARM_CATEGORY_HARASSMENT
+	opts := []Option{
+		WithSafetySettings(&generativelanguagepb.SafetySetting{Category: harassment, Threshold: generativelanguagepb.SafetySetting_BLOCK_LOW_AND_ABOVE}),
+		WithSafetySettings(&generativelanguagepb.SafetySetting{Category: harassment, Threshold: generativelanguagepb.SafetySetting_BLOCK_NONE}),
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(m.safetySettings) != 1 || m.safetySettings[0].Threshold != generativelanguagepb.SafetySetting_BLOCK_NONE {
+		t.Errorf("Expected a single BLOCK_NONE setting, got %v", m.safetySettings)
+	}
+}
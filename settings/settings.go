@@ -18,8 +18,15 @@ type Model struct {
 	ShowLogo        bool
 	ShowLogMessage  bool
 	ShowAudioStatus bool
+
+	// Live API context window compression
+	ContextCompression       bool
+	CompressionTriggerTokens int64 // 0 uses the server default
 }
 
+// CompressionStep is how much +/- changes the compression trigger.
+const CompressionStep = 4096
+
 // New creates a new settings model
 func New() Model {
 	return Model{
@@ -48,8 +55,15 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			return m, nil
 		}
 
-		if msg.String() == "esc" {
+		switch msg.String() {
+		case "esc":
 			m.Focused = false
+		case "c":
+			m.ContextCompression = !m.ContextCompression
+		case "+", "=":
+			m.CompressionTriggerTokens += CompressionStep
+		case "-":
+			m.CompressionTriggerTokens = max(m.CompressionTriggerTokens-CompressionStep, 0)
 		}
 	}
 
@@ -69,8 +83,12 @@ func (m Model) View() string {
 		BorderForeground(lipgloss.Color("62")).
 		Padding(1, 2)
 
-	content := fmt.Sprintf("Settings\n\nModel: %s\nVoice: %s\nAudio Enabled: %t\nShow Logo: %t\nShow Log Messages: %t\nShow Audio Status: %t\n\nPress ESC to close",
-		m.CurrentModel, m.CurrentVoice, m.AudioEnabled, m.ShowLogo, m.ShowLogMessage, m.ShowAudioStatus)
+	trigger := "server default"
+	if m.CompressionTriggerTokens > 0 {
+		trigger = fmt.Sprintf("%d tokens", m.CompressionTriggerTokens)
+	}
+	content := fmt.Sprintf("Settings\n\nModel: %s\nVoice: %s\nAudio Enabled: %t\nShow Logo: %t\nShow Log Messages: %t\nShow Audio Status: %t\nContext Compression: %t (c)\nCompression Trigger: %s (+/-)\n\nPress ESC to close",
+		m.CurrentModel, m.CurrentVoice, m.AudioEnabled, m.ShowLogo, m.ShowLogMessage, m.ShowAudioStatus, m.ContextCompression, trigger)
 
 	return style.Render(content)
 }
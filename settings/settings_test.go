@@ -154,3 +154,27 @@ func TestUpdate(t *testing.T) {
 		t.Error("Update(KeyMsg{Enter}) returned non-nil command")
 	}
 }
+
+func TestCompressionKeys(t *testing.T) {
+	model := New()
+	model.Focus()
+
+	for _, key := range []string{"c", "+", "+", "-"} {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	if !model.ContextCompression {
+		t.Error("Update(c) should enable context compression")
+	}
+	if model.CompressionTriggerTokens != CompressionStep {
+		t.Errorf("CompressionTriggerTokens = %d, want %d", model.CompressionTriggerTokens, CompressionStep)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("-")})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("-")})
+	if model.CompressionTriggerTokens != 0 {
+		t.Errorf("CompressionTriggerTokens = %d, want 0 (server default)", model.CompressionTriggerTokens)
+	}
+	if !strings.Contains(model.View(), "Compression Trigger: server default") {
+		t.Error("View() should show the server default trigger")
+	}
+}
@@ -361,3 +361,20 @@ func TestStreamClientConfigCreation(t *testing.T) {
 		}
 	}
 }
+
+// TestHeadlessAPIKeyCommand tests the api_key_command is used outside the TUI
+func TestHeadlessAPIKeyCommand(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	model := New(WithAPIKeyCommand(BackendGeminiAPI, "echo headless-secret-key"))
+	model.apiKey = ""
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := model.startHeadlessStream(ctx); err != nil {
+		t.Fatalf("startHeadlessStream failed: %v", err)
+	}
+	if got := model.client.APIKey; got != "headless-secret-key" {
+		t.Errorf("Expected the key from the command, got %q", got)
+	}
+}
@@ -120,7 +120,15 @@ func (m *Model) initStreamCmd() tea.Cmd {
 			TopK:            m.topK,
 			MaxOutputTokens: m.maxOutputTokens,
 			// Feature flags
-			EnableWebSocket: m.enableWebSocket,
+			EnableWebSocket:    m.enableWebSocket,
+			AudioTranscription: m.enableAudio,
+
+			ContextWindowCompression: m.contextCompression,
+			CompressionTriggerTokens: m.compressionTriggerTokens,
+			CompressionTargetTokens:  m.compressionTargetTokens,
+
+			ThinkingBudget:  m.thinkingBudget,
+			IncludeThoughts: m.includeThoughts,
 		}
 
 		if m.enableTools && m.toolManager != nil {
@@ -148,6 +156,7 @@ func (m *Model) initStreamCmd() tea.Cmd {
 		clientConfig.DisplayTokenCounts = m.displayTokenCounts
 		clientConfig.ResponseMimeType = m.responseMimeType
 		clientConfig.ResponseSchemaFile = m.responseSchemaFile
+		clientConfig.SafetySettings = m.safetySettings
 
 		// Initialize the stream
 		start := time.Now()
@@ -167,6 +176,7 @@ func (m *Model) initStreamCmd() tea.Cmd {
 
 		// Set API authentication and service selection
 		m.client.APIKey = m.apiKey
+		m.client.KeySource = m.apiKeySources[m.backend]
 		if m.backend == BackendVertexAI {
 			m.client.Backend = api.BackendVertexAI
 			m.client.ProjectID = m.projectID
@@ -315,6 +325,20 @@ func (m *Model) receiveBidiStreamCmd() tea.Cmd {
 
 		output := api.ExtractOutput(resp)
 
+		// Live transcriptions, tool call cancellations and usage have no
+		// GenerateContentResponse equivalent
+		if adapter, ok := m.bidiStream.(*api.LiveStreamAdapter); ok {
+			if live := adapter.LastOutput(); live != nil {
+				output.InputTranscription = live.InputTranscription
+				output.OutputTranscription = live.OutputTranscription
+				output.CancelledToolCallIDs = live.CancelledToolCallIDs
+				output.PromptTokenCount = live.PromptTokenCount
+				output.CandidateTokenCount = live.CandidateTokenCount
+				output.TotalTokenCount = live.TotalTokenCount
+				output.ThoughtsTokenCount = live.ThoughtsTokenCount
+			}
+		}
+
 		// Check if there's a function call in the output that needs to be processed
 		if output.FunctionCall != nil {
 			log.Printf("Detected function call in stream response: %s", output.FunctionCall.Name)
@@ -568,7 +592,15 @@ func (m *Model) sendToBidiStreamCmd(text string) tea.Cmd {
 			TopK:            m.topK,
 			MaxOutputTokens: m.maxOutputTokens,
 			// Feature flags
-			EnableWebSocket: m.enableWebSocket,
+			EnableWebSocket:    m.enableWebSocket,
+			AudioTranscription: m.enableAudio,
+
+			ContextWindowCompression: m.contextCompression,
+			CompressionTriggerTokens: m.compressionTriggerTokens,
+			CompressionTargetTokens:  m.compressionTargetTokens,
+
+			ThinkingBudget:  m.thinkingBudget,
+			IncludeThoughts: m.includeThoughts,
 		}
 
 		if m.enableTools && m.toolManager != nil {
@@ -598,6 +630,7 @@ func (m *Model) sendToBidiStreamCmd(text string) tea.Cmd {
 					Role: "user",
 				},
 			},
+			SafetySettings: m.safetySettings,
 		}
 
 		// Set up GenerationConfig
@@ -612,6 +645,14 @@ func (m *Model) sendToBidiStreamCmd(text string) tea.Cmd {
 		if m.maxOutputTokens > 0 {
 			genConfig.MaxOutputTokens = &m.maxOutputTokens
 		}
+		genConfig.ThinkingConfig = api.ThinkingConfig(&clientConfig)
+		if m.responseMimeType != "" {
+			genConfig.ResponseMimeType = m.responseMimeType
+		}
+		if m.responseSchema != nil {
+			genConfig.ResponseMimeType = "application/json"
+			genConfig.ResponseSchema = m.responseSchema
+		}
 
 		request.GenerationConfig = genConfig
 
@@ -0,0 +1,189 @@
+package aistudio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tmc/aistudio/api"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// extractJSON returns the JSON document in a response, dropping the Markdown
+// code fence models sometimes wrap it in.
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if rest, ok := strings.CutPrefix(text, "```"); ok {
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+			rest = rest[nl+1:] // Skip the language tag
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "```"))
+	}
+	return text
+}
+
+// indentJSON returns the JSON document in a response indented for display,
+// or "" if it does not parse.
+func indentJSON(text string) string {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(extractJSON(text)), "", "  "); err != nil {
+		return ""
+	}
+	return pretty.String()
+}
+
+// validateStructuredOutput checks a response against the response schema. It
+// returns the response as indented JSON when it parses, and the validation
+// problems, if any.
+func (m *Model) validateStructuredOutput(text string) (string, []string) {
+	problems := api.ValidateJSON([]byte(extractJSON(text)), m.responseSchema)
+	return indentJSON(text), problems
+}
+
+// schemaRetryPrompt asks the model to correct a response that failed validation.
+func schemaRetryPrompt(problems []string) string {
+	return "Your previous response does not match the required JSON schema:\n- " +
+		strings.Join(problems, "\n- ") +
+		"\nReply again with only the corrected JSON."
+}
+
+// latestAnswerIndex returns the index of the most recent model answer since
+// the last user message, or -1 if there is none.
+func (m *Model) latestAnswerIndex() int {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Sender == senderNameUser {
+			break
+		}
+		if isAnswerMessage(m.messages[i]) && m.messages[i].Content != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkStructuredOutput validates the answer to a finished turn against the
+// response schema. Invalid answers are sent back to the model with the
+// problems until the configured number of retries is used up.
+func (m *Model) checkStructuredOutput() tea.Cmd {
+	if m.responseSchema == nil {
+		return nil
+	}
+	idx := m.latestAnswerIndex()
+	if idx < 0 || m.messages[idx].SchemaChecked {
+		return nil
+	}
+
+	_, problems := m.validateStructuredOutput(m.messages[idx].Content)
+	m.messages[idx].SchemaChecked = true
+	m.messages[idx].SchemaErrors = problems
+	if len(problems) == 0 || m.schemaRetryCount >= m.schemaRetries {
+		m.schemaRetryCount = 0
+		return nil
+	}
+
+	m.schemaRetryCount++
+	log.Printf("Response failed schema validation (%d problems), retry %d of %d", len(problems), m.schemaRetryCount, m.schemaRetries)
+	m.messages = append(m.messages, formatMessage(senderNameSystem, fmt.Sprintf(
+		"Response does not match the schema; asking the model to fix it (retry %d of %d).", m.schemaRetryCount, m.schemaRetries)))
+	m.currentState = AppStateWaiting
+	return m.sendTextCmd(schemaRetryPrompt(problems)) // generation_controls.go
+}
+
+// sendStructuredMessage sends a message outside the TUI and, when a response
+// schema is set, returns the answer only once it validates, as indented JSON.
+// Invalid answers are retried with the problems fed back to the model.
+func (m *Model) sendStructuredMessage(message string) (string, error) {
+	if m.responseSchema == nil {
+		return m.sendHeadlessMessage(message)
+	}
+	for attempt := 0; ; attempt++ {
+		response, err := m.sendHeadlessMessage(message)
+		if err != nil {
+			return "", err
+		}
+		pretty, problems := m.validateStructuredOutput(response)
+		if len(problems) == 0 {
+			return pretty, nil
+		}
+		if attempt >= m.schemaRetries {
+			return "", fmt.Errorf("response does not match schema %s:\n  %s", m.responseSchemaFile, strings.Join(problems, "\n  "))
+		}
+		log.Printf("Response failed schema validation, retry %d of %d", attempt+1, m.schemaRetries)
+		message = schemaRetryPrompt(problems)
+	}
+}
+
+// highlightJSON colors the keys, strings, numbers and literals of a JSON document.
+func highlightJSON(s string) string {
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	stringStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("114"))
+	numberStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	literalStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		j := i + 1
+		switch {
+		case c == '"':
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(s))
+			rest := strings.TrimLeft(s[j:], " ")
+			if strings.HasPrefix(rest, ":") {
+				b.WriteString(keyStyle.Render(s[i:j]))
+			} else {
+				b.WriteString(stringStyle.Render(s[i:j]))
+			}
+		case c == '-' || (c >= '0' && c <= '9'):
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			b.WriteString(numberStyle.Render(s[i:j]))
+		case c >= 'a' && c <= 'z':
+			for j < len(s) && s[j] >= 'a' && s[j] <= 'z' {
+				j++
+			}
+			b.WriteString(literalStyle.Render(s[i:j]))
+		default:
+			b.WriteByte(c)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// formatStructuredOutput renders a validated response as highlighted JSON
+// followed by the validation result
+func (r *MessageRenderer) formatStructuredOutput(finalMsg *strings.Builder, msg Message) {
+	okStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+	if pretty := indentJSON(msg.Content); pretty != "" {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(highlightJSON(pretty))
+	} else {
+		finalMsg.WriteString(msg.Content)
+	}
+	finalMsg.WriteString("\n")
+
+	if len(msg.SchemaErrors) == 0 {
+		finalMsg.WriteString(okStyle.Render("✓ Matches response schema"))
+		finalMsg.WriteString("\n")
+		return
+	}
+	finalMsg.WriteString(errStyle.Render("✗ Does not match response schema:"))
+	for _, problem := range msg.SchemaErrors {
+		finalMsg.WriteString("\n  ")
+		finalMsg.WriteString(errStyle.Render(problem))
+	}
+	finalMsg.WriteString("\n")
+}
@@ -0,0 +1,77 @@
+package aistudio
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+)
+
+func testResponseSchema() *generativelanguagepb.Schema {
+	return &generativelanguagepb.Schema{
+		Type:       generativelanguagepb.Type_OBJECT,
+		Required:   []string{"answer"},
+		Properties: map[string]*generativelanguagepb.Schema{"answer": {Type: generativelanguagepb.Type_STRING}},
+	}
+}
+
+// TestValidateStructuredOutput tests fenced JSON is unwrapped, indented and validated
+func TestValidateStructuredOutput(t *testing.T) {
+	m := &Model{responseSchema: testResponseSchema()}
+
+	pretty, problems := m.validateStructuredOutput("```json\n{\"answer\":\"yes\"}\n```")
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %q", problems)
+	}
+	if pretty != "{\n  \"answer\": \"yes\"\n}" {
+		t.Errorf("Unexpected pretty output: %q", pretty)
+	}
+
+	if _, problems := m.validateStructuredOutput(`{"answer": 1}`); len(problems) != 1 {
+		t.Errorf("Expected one problem, got %q", problems)
+	}
+}
+
+// TestCheckStructuredOutput tests an invalid answer is marked and retried
+func TestCheckStructuredOutput(t *testing.T) {
+	m := &Model{
+		responseSchema: testResponseSchema(),
+		schemaRetries:  1,
+		messages: []Message{
+			formatMessage(senderNameUser, "Answer in JSON"),
+			formatMessage(senderNameModel, `{"reply": "yes"}`),
+		},
+	}
+
+	if cmd := m.checkStructuredOutput(); cmd == nil {
+		t.Fatal("Expected a retry to be sent for an invalid answer")
+	}
+	if !m.messages[1].SchemaChecked || len(m.messages[1].SchemaErrors) != 1 {
+		t.Errorf("Expected the answer to be marked invalid, got %+v", m.messages[1])
+	}
+	if m.schemaRetryCount != 1 || m.messages[2].Sender != senderNameSystem {
+		t.Errorf("Expected a retry notice, got count %d and %+v", m.schemaRetryCount, m.messages[2])
+	}
+
+	// The retried answer is still invalid and no retries are left
+	m.messages = append(m.messages, formatMessage(senderNameModel, `{"answer": 2}`))
+	if cmd := m.checkStructuredOutput(); cmd != nil {
+		t.Error("Expected no further retries")
+	}
+	if m.schemaRetryCount != 0 {
+		t.Errorf("Expected the retry count to reset, got %d", m.schemaRetryCount)
+	}
+
+	rendered := NewMessageRenderer(m).formatMessageText(m.messages[3], 3)
+	if !strings.Contains(rendered, "Does not match response schema") || !strings.Contains(rendered, "$.answer: expected string, got number") {
+		t.Errorf("Expected validation problems in the rendered answer, got:\n%s", rendered)
+	}
+}
+
+// TestHighlightJSON tests highlighting keeps the document text intact
+func TestHighlightJSON(t *testing.T) {
+	doc := "{\n  \"a\": [1, -2.5e3, true, null],\n  \"b\": \"x \\\"y\\\"\"\n}"
+	if got := StripANSI(highlightJSON(doc)); got != doc {
+		t.Errorf("highlightJSON changed the text:\n%s", got)
+	}
+}
@@ -0,0 +1,175 @@
+package aistudio
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tmc/aistudio/prompts"
+)
+
+// templatePicker is the modal used to choose a prompt template and fill in
+// its variables.
+type templatePicker struct {
+	templates []*prompts.Template
+	cursor    int
+
+	selected *prompts.Template // Set once a template is chosen
+	varIndex int               // Variable currently being prompted for
+	values   map[string]string
+	input    textinput.Model
+}
+
+// openTemplatePicker shows the template picker, or explains how to add
+// templates when the library is empty.
+func (m *Model) openTemplatePicker() {
+	if m.promptLibrary == nil || m.promptLibrary.Len() == 0 {
+		dir := prompts.DefaultDir()
+		m.messages = append(m.messages, formatMessage(senderNameSystem,
+			fmt.Sprintf("No prompt templates found. Add .md files with front-matter to %s or pass --prompts-dir.", dir)))
+		return
+	}
+	input := textinput.New()
+	input.Prompt = "> "
+	m.templatePicker = &templatePicker{
+		templates: m.promptLibrary.List(),
+		values:    make(map[string]string),
+		input:     input,
+	}
+	m.textarea.Blur()
+}
+
+// closeTemplatePicker hides the picker and returns focus to the input.
+func (m *Model) closeTemplatePicker() {
+	m.templatePicker = nil
+	m.focusedComponent = "input"
+	m.textarea.Focus()
+}
+
+// handleTemplatePickerKey processes keys while the picker is open.
+func (m *Model) handleTemplatePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.templatePicker
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.closeTemplatePicker()
+		return m, nil
+	}
+
+	if p.selected == nil {
+		switch msg.String() {
+		case "up", "k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "down", "j":
+			if p.cursor < len(p.templates)-1 {
+				p.cursor++
+			}
+		case "enter":
+			p.selected = p.templates[p.cursor]
+			return m, m.promptNextTemplateVariable()
+		}
+		return m, nil
+	}
+
+	if msg.String() == "enter" {
+		v := p.selected.Variables[p.varIndex]
+		value := p.input.Value()
+		if value == "" && v.HasDefault {
+			value = v.Default
+		}
+		if value == "" && v.Required() {
+			return m, nil // Keep prompting until a required value is given
+		}
+		p.values[v.Name] = value
+		p.varIndex++
+		return m, m.promptNextTemplateVariable()
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return m, cmd
+}
+
+// promptNextTemplateVariable readies the input for the next variable, or
+// applies the template once all variables are filled.
+func (m *Model) promptNextTemplateVariable() tea.Cmd {
+	p := m.templatePicker
+	if p.varIndex >= len(p.selected.Variables) {
+		m.applyTemplate(p.selected, p.values)
+		return nil
+	}
+	v := p.selected.Variables[p.varIndex]
+	p.input.Reset()
+	p.input.Placeholder = v.Default
+	return p.input.Focus()
+}
+
+// applyTemplate renders a template into the input draft and applies its
+// model and temperature overrides to the session.
+func (m *Model) applyTemplate(t *prompts.Template, vars map[string]string) {
+	m.closeTemplatePicker()
+	text, err := t.Render(vars)
+	if err != nil {
+		m.messages = append(m.messages, formatError(err))
+		return
+	}
+	m.textarea.SetValue(text)
+
+	var overrides []string
+	if t.Model != "" && t.Model != m.modelName {
+		m.modelName = t.Model
+		overrides = append(overrides, "model "+t.Model)
+	}
+	if t.Temperature != nil && *t.Temperature != m.temperature {
+		m.temperature = *t.Temperature
+		overrides = append(overrides, fmt.Sprintf("temperature %.2f", *t.Temperature))
+	}
+	log.Printf("Applied prompt template %q", t.Name)
+	if len(overrides) > 0 {
+		m.messages = append(m.messages, formatMessage(senderNameSystem,
+			fmt.Sprintf("Template %q set %s.", t.Name, strings.Join(overrides, " and "))))
+	}
+}
+
+// renderTemplatePicker renders the picker modal.
+func (m *Model) renderTemplatePicker() string {
+	p := m.templatePicker
+	var b strings.Builder
+	b.WriteString(viewTitleStyle.Render("Prompt Templates"))
+	b.WriteString("\n\n")
+
+	if p.selected == nil {
+		for i, t := range p.templates {
+			line := t.Name
+			if t.Description != "" {
+				line += " - " + t.Description
+			}
+			if i == p.cursor {
+				b.WriteString(dialogOptionSelected.Render("❯ " + line))
+			} else {
+				b.WriteString(dialogOptionUnselected.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(fmt.Sprintf("\n%s to choose, %s to cancel", dialogHintStyle.Render("enter"), dialogHintStyle.Render("esc")))
+	} else {
+		v := p.selected.Variables[p.varIndex]
+		b.WriteString(viewToolNameStyle.Render(p.selected.Name))
+		b.WriteString(fmt.Sprintf("  (variable %d of %d)\n\n", p.varIndex+1, len(p.selected.Variables)))
+		b.WriteString(dialogActionHighlight.Render(v.Name))
+		if v.Description != "" {
+			b.WriteString(": " + v.Description)
+		}
+		if v.HasDefault {
+			b.WriteString(dialogOptionUnselected.Render(fmt.Sprintf(" (default %q)", v.Default)))
+		}
+		b.WriteString("\n")
+		b.WriteString(p.input.View())
+		b.WriteString(fmt.Sprintf("\n\n%s to accept, %s to cancel", dialogHintStyle.Render("enter"), dialogHintStyle.Render("esc")))
+	}
+
+	return viewModalStyle.Width(max(m.width-10, 20)).Render(b.String())
+}
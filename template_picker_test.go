@@ -0,0 +1,47 @@
+package aistudio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tmc/aistudio/prompts"
+)
+
+// TestTemplatePickerFillsDraft tests choosing a template and filling its variables
+func TestTemplatePickerFillsDraft(t *testing.T) {
+	dir := t.TempDir()
+	content := "---\nname: greet\ntemperature: 0.1\nvariables:\n  - name: who\n  - name: tone\n    default: warm\n---\nGreet {{.who}} in a {{.tone}} tone."
+	if err := os.WriteFile(filepath.Join(dir, "greet.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := prompts.LoadLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadLibrary failed: %v", err)
+	}
+	m := &Model{textarea: textarea.New(), promptLibrary: lib, temperature: 0.7}
+
+	m.openTemplatePicker()
+	if m.templatePicker == nil {
+		t.Fatal("Expected picker to open")
+	}
+	m.handleTemplatePickerKey(tea.KeyMsg{Type: tea.KeyEnter})
+	for _, r := range "Ada" {
+		m.handleTemplatePickerKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m.handleTemplatePickerKey(tea.KeyMsg{Type: tea.KeyEnter})
+	m.handleTemplatePickerKey(tea.KeyMsg{Type: tea.KeyEnter}) // Accept the default tone
+
+	if m.templatePicker != nil {
+		t.Error("Expected picker to close after the last variable")
+	}
+	if got, want := m.textarea.Value(), "Greet Ada in a warm tone."; got != want {
+		t.Errorf("Expected draft %q, got %q", want, got)
+	}
+	if m.temperature != 0.1 {
+		t.Errorf("Expected template temperature override, got %v", m.temperature)
+	}
+}
@@ -0,0 +1,59 @@
+package aistudio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// appendThought adds a fragment of a thought summary to the model message
+// for the current turn. Thoughts arrive before the answer, so a model message
+// that has no answer text yet is extended; otherwise a new one is started and
+// the answer that follows is appended to it.
+func (m *Model) appendThought(text string) {
+	if text == "" {
+		return
+	}
+
+	idx := len(m.messages) - 1
+	if idx < 0 || m.messages[idx].Sender != senderNameModel || m.messages[idx].Content != "" ||
+		m.messages[idx].IsToolCall() || m.messages[idx].BlockReason != "" {
+		m.messages = append(m.messages, formatMessage(senderNameModel, ""))
+		idx++
+	}
+	m.messages[idx].Thoughts += text
+	m.messages[idx].Timestamp = time.Now()
+	m.viewport.GotoBottom()
+}
+
+// toggleDetails expands or collapses thought summaries and tool output in
+// every message.
+func (m *Model) toggleDetails() {
+	m.detailsExpanded = !m.detailsExpanded
+}
+
+// formatThoughts renders a message's thought summary as a dimmed block above
+// the answer, collapsed to a one-line summary unless expanded with Ctrl+Y
+func (r *MessageRenderer) formatThoughts(finalMsg *strings.Builder, msg Message) {
+	if msg.Thoughts == "" {
+		return
+	}
+	headerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	thoughtStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Italic(true)
+
+	if !r.model.detailsExpanded {
+		words := len(strings.Fields(msg.Thoughts))
+		finalMsg.WriteString(headerStyle.Render(fmt.Sprintf("▸ Thoughts (%d words) Ctrl+Y to expand", words)))
+		finalMsg.WriteString("\n")
+		return
+	}
+
+	finalMsg.WriteString(headerStyle.Render("▾ Thoughts Ctrl+Y to collapse"))
+	for _, line := range strings.Split(strings.TrimSpace(msg.Thoughts), "\n") {
+		finalMsg.WriteString("\n")
+		finalMsg.WriteString(thoughtStyle.Render("│ " + line))
+	}
+	finalMsg.WriteString("\n")
+}
@@ -0,0 +1,48 @@
+package aistudio
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestAppendThought tests thought fragments collect on the model message for the turn
+func TestAppendThought(t *testing.T) {
+	m := &Model{width: 80, messages: []Message{formatMessage(senderNameUser, "why?")}}
+	m.appendThought("First, ")
+	m.appendThought("consider the input.")
+
+	if len(m.messages) != 2 {
+		t.Fatalf("Expected one model message for the thoughts, got %d messages", len(m.messages))
+	}
+	if got := m.messages[1].Thoughts; got != "First, consider the input." {
+		t.Errorf("Thoughts = %q", got)
+	}
+
+	// Once the answer starts, later thoughts begin a new message
+	m.messages[1].Content = "Because."
+	m.appendThought("More thinking.")
+	if len(m.messages) != 3 {
+		t.Errorf("Expected a new message after the answer, got %d messages", len(m.messages))
+	}
+}
+
+// TestFormatThoughts tests the collapsed and expanded thought block
+func TestFormatThoughts(t *testing.T) {
+	m := &Model{width: 80}
+	msg := Message{Sender: senderNameModel, Content: "42", Thoughts: "Add six\nand seven times five"}
+	r := NewMessageRenderer(m)
+
+	collapsed := r.formatMessageText(msg, 0)
+	if !strings.Contains(collapsed, "Thoughts (6 words)") || strings.Contains(collapsed, "seven times") {
+		t.Errorf("Expected a collapsed summary, got:\n%s", collapsed)
+	}
+
+	m.toggleDetails()
+	expanded := r.formatMessageText(msg, 0)
+	if !strings.Contains(expanded, "│ and seven times five") {
+		t.Errorf("Expected the full thoughts when expanded, got:\n%s", expanded)
+	}
+	if strings.Index(expanded, "Add six") > strings.Index(expanded, "42") {
+		t.Errorf("Expected thoughts above the answer, got:\n%s", expanded)
+	}
+}
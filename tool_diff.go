@@ -0,0 +1,295 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/tmc/aistudio/internal/diff"
+)
+
+// maxDiffPreviewLines bounds the diff shown in the tool approval modal.
+const maxDiffPreviewLines = 30
+
+// fileEdit is the change a file-editing tool call makes to a file. Calls
+// are recognized by their arguments: file_path with old_string and
+// new_string, as for the example Edit tool, or file_path with content, as
+// for Replace and Write tools.
+type fileEdit struct {
+	Path   string
+	Field  string // The argument holding the proposed text, which the user may edit
+	Before string // The file's content when the call was made; empty for a new file
+	After  string // The content the call proposes
+	Err    error  // Why the proposed content could not be worked out
+}
+
+// proposeFileEdit works out the change call would make to the file as it is
+// now. It returns nil for calls that do not edit or write a file.
+func proposeFileEdit(call ToolCall) *fileEdit {
+	var args struct {
+		FilePath             string   `json:"file_path"`
+		Content              *string  `json:"content"`
+		OldString            *string  `json:"old_string"`
+		NewString            *string  `json:"new_string"`
+		ExpectedReplacements *float64 `json:"expected_replacements"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil || args.FilePath == "" {
+		return nil
+	}
+	edit := &fileEdit{Path: args.FilePath}
+	switch {
+	case args.Content != nil:
+		edit.Field = "content"
+	case args.OldString != nil && args.NewString != nil:
+		edit.Field = "new_string"
+	default:
+		return nil
+	}
+
+	data, err := os.ReadFile(args.FilePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		edit.Err = err
+		return edit
+	}
+	edit.Before = string(data)
+
+	switch {
+	case args.Content != nil:
+		edit.After = *args.Content
+	case *args.OldString == "":
+		// An empty old_string creates the file
+		edit.After = *args.NewString
+	default:
+		want := 1
+		if args.ExpectedReplacements != nil {
+			want = int(*args.ExpectedReplacements)
+		}
+		if n := strings.Count(edit.Before, *args.OldString); n != want {
+			edit.Err = fmt.Errorf("old_string occurs %d times in %s, expected %d", n, args.FilePath, want)
+			return edit
+		}
+		edit.After = strings.ReplaceAll(edit.Before, *args.OldString, *args.NewString)
+	}
+	return edit
+}
+
+// workspaceFileEdit works out the change a call by the model would make.
+// Paths given to the built-in file tools are resolved in their workspace
+// first, so the preview reads the file the tool would write.
+func (m *Model) workspaceFileEdit(call ToolCall) *fileEdit {
+	edit := proposeFileEdit(call)
+	if edit == nil || m.toolManager == nil || !isFileTool(call.Name) {
+		return edit
+	}
+	ws, err := m.toolManager.Workspace()
+	if err == nil {
+		var p string
+		if p, err = ws.Resolve(edit.Path); err == nil {
+			var resolved ToolCall
+			if resolved, err = setToolCallArgument(call, "file_path", p); err == nil {
+				return proposeFileEdit(resolved)
+			}
+		}
+	}
+	return &fileEdit{Path: edit.Path, Field: edit.Field, Err: err}
+}
+
+// diff returns the proposed change as a unified diff.
+func (e *fileEdit) diff() string {
+	return diff.Unified(e.Path, e.Path, e.Before, e.After)
+}
+
+// appliedDiff returns the change the call made, comparing the file now with
+// its content when the call was made.
+func (e *fileEdit) appliedDiff() string {
+	data, err := os.ReadFile(e.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Failed to read %s after the tool call: %v", e.Path, err)
+		return ""
+	}
+	return diff.Unified(e.Path, e.Path, e.Before, string(data))
+}
+
+// toolCallArgument returns the string argument name of call.
+func toolCallArgument(call ToolCall, name string) string {
+	var args map[string]any
+	json.Unmarshal(call.Arguments, &args)
+	s, _ := args[name].(string)
+	return s
+}
+
+// setToolCallArgument returns call with its argument name set to value,
+// keeping the other arguments as they are.
+func setToolCallArgument(call ToolCall, name, value string) (ToolCall, error) {
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return call, fmt.Errorf("failed to parse tool call arguments: %w", err)
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return call, err
+	}
+	args[name] = v
+	if call.Arguments, err = json.Marshal(args); err != nil {
+		return call, err
+	}
+	return call, nil
+}
+
+// renderDiff colors a unified diff, showing at most maxLines lines of it
+// when maxLines is positive.
+func renderDiff(d string, maxLines int) string {
+	lines := strings.Split(strings.TrimSuffix(d, "\n"), "\n")
+	more := 0
+	if maxLines > 0 && len(lines) > maxLines {
+		lines, more = lines[:maxLines], len(lines)-maxLines
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			b.WriteString(diffHeaderStyle.Render(line))
+		case strings.HasPrefix(line, "@@"):
+			b.WriteString(diffHunkStyle.Render(line))
+		case strings.HasPrefix(line, "+"):
+			b.WriteString(diffAddStyle.Render(line))
+		case strings.HasPrefix(line, "-"):
+			b.WriteString(diffRemoveStyle.Render(line))
+		default:
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+	if more > 0 {
+		b.WriteString(dialogHintStyle.Render(fmt.Sprintf("… %d more lines", more)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderFileEditPreview shows the change a file-editing tool call proposes,
+// left-aligned in a block width columns wide when width is positive.
+func renderFileEditPreview(edit *fileEdit, width int) string {
+	var preview string
+	switch d := edit.diff(); {
+	case edit.Err != nil:
+		preview = errorStyle.Render(fmt.Sprintf("Cannot preview the change: %v", edit.Err)) + "\n"
+	case d == "":
+		preview = fmt.Sprintf("No changes to %s\n", edit.Path)
+	default:
+		preview = renderDiff(d, maxDiffPreviewLines)
+	}
+	if width > 0 {
+		preview = lipgloss.NewStyle().Width(width).Render(strings.TrimSuffix(preview, "\n")) + "\n"
+	}
+	return preview
+}
+
+// toolFileEdit returns the change call would make, remembering it on the
+// call's view model so the file is read once, before the call runs. It
+// returns nil for calls that do not edit or write a file.
+func (m *Model) toolFileEdit(call ToolCall) *fileEdit {
+	vm := m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
+		vm.Name = call.Name
+		if vm.FileEdit == nil || string(vm.Arguments) != string(call.Arguments) {
+			vm.Arguments = call.Arguments
+			vm.FileEdit = m.workspaceFileEdit(call)
+		}
+	})
+	return vm.FileEdit
+}
+
+// toolResultMessage formats the result of a tool call, recording the change
+// the call made when it edited a file.
+func (m *Model) toolResultMessage(res *ToolResponse, status ToolCallStatus) Message {
+	vm := m.getOrCreateToolVM(res.Id, func(vm *ToolCallViewModel) {
+		if vm.FileEdit != nil && vm.FileEdit.Err == nil {
+			vm.Diff = vm.FileEdit.appliedDiff()
+		}
+	})
+	result := *vm
+	result.Name, result.Status, result.Result = res.Name, status, res.Response
+	return formatToolResultMessageFromViewModel(result)
+}
+
+// toolEditFinishedMsg is sent when the user is done editing the proposed
+// text of a file-editing tool call.
+type toolEditFinishedMsg struct {
+	id   string // The tool call ID
+	path string // Temporary file holding the edited text
+	err  error
+}
+
+// editToolCallCmd opens the proposed text of the file-editing tool call
+// awaiting approval in $EDITOR. It returns nil when the call does not edit a
+// file.
+func (m *Model) editToolCallCmd() tea.Cmd {
+	if !m.showToolApproval || m.approvalIndex >= len(m.pendingToolCalls) {
+		return nil
+	}
+	call := m.pendingToolCalls[m.approvalIndex]
+	edit := m.toolFileEdit(call)
+	if edit == nil {
+		return nil
+	}
+	// Keep the file's extension so the editor highlights the syntax
+	pattern := "aistudio-edit-*" + filepath.Ext(edit.Path)
+	return editTextCmd(pattern, toolCallArgument(call, edit.Field), func(path string, err error) tea.Msg {
+		return toolEditFinishedMsg{id: call.ID, path: path, err: err}
+	})
+}
+
+// handleToolEditFinished replaces the proposed text of the pending tool call
+// with the user's edited text.
+func (m *Model) handleToolEditFinished(msg toolEditFinishedMsg) {
+	if msg.path != "" {
+		defer os.Remove(msg.path)
+	}
+	if msg.err != nil {
+		log.Printf("Editor failed: %v", msg.err)
+		m.messages = append(m.messages, formatError(fmt.Errorf("editor failed: %w", msg.err)))
+		return
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.messages = append(m.messages, formatError(fmt.Errorf("failed to read edited text: %w", err)))
+		return
+	}
+
+	for i, call := range m.pendingToolCalls {
+		if call.ID != msg.id {
+			continue
+		}
+		edit := m.toolFileEdit(call)
+		if edit == nil {
+			return
+		}
+		text := string(data)
+		// Editors usually add a trailing newline the proposed text did not have
+		if old := toolCallArgument(call, edit.Field); !strings.HasSuffix(old, "\n") {
+			text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		}
+		edited, err := setToolCallArgument(call, edit.Field, text)
+		if err != nil {
+			m.messages = append(m.messages, formatError(err))
+			return
+		}
+		m.pendingToolCalls[i] = edited
+		m.replaceToolCallMessage(*m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
+			vm.Arguments = edited.Arguments
+			vm.FileEdit = m.workspaceFileEdit(edited)
+		}))
+		log.Printf("Edited proposed %s of tool call %s (%s)", edit.Field, call.Name, call.ID)
+		return
+	}
+	log.Printf("Tool call %s is no longer awaiting approval; dropping edit", msg.id)
+}
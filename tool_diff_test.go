@@ -0,0 +1,118 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProposeFileEdit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := func(v map[string]any) json.RawMessage {
+		data, _ := json.Marshal(v)
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		args    json.RawMessage
+		field   string
+		after   string
+		wantErr string
+	}{
+		{"edit", args(map[string]any{"file_path": path, "old_string": "func main() {}", "new_string": "func main() { run() }"}),
+			"new_string", "package main\n\nfunc main() { run() }\n", ""},
+		{"write", args(map[string]any{"file_path": path, "content": "package other\n"}),
+			"content", "package other\n", ""},
+		{"new file", args(map[string]any{"file_path": filepath.Join(dir, "new.txt"), "old_string": "", "new_string": "hello\n"}),
+			"new_string", "hello\n", ""},
+		{"not found", args(map[string]any{"file_path": path, "old_string": "func other()", "new_string": "x"}),
+			"new_string", "", "occurs 0 times"},
+		{"replacements", args(map[string]any{"file_path": path, "old_string": "main", "new_string": "app", "expected_replacements": 2}),
+			"new_string", "package app\n\nfunc app() {}\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edit := proposeFileEdit(ToolCall{ID: "1", Name: "Edit", Arguments: tt.args})
+			if edit == nil {
+				t.Fatal("Expected a file edit")
+			}
+			if tt.wantErr != "" {
+				if edit.Err == nil || !strings.Contains(edit.Err.Error(), tt.wantErr) {
+					t.Errorf("Expected an error containing %q, got %v", tt.wantErr, edit.Err)
+				}
+				return
+			}
+			if edit.Err != nil || edit.Field != tt.field || edit.After != tt.after {
+				t.Errorf("Got field %q, content %q, error %v; want field %q, content %q", edit.Field, edit.After, edit.Err, tt.field, tt.after)
+			}
+		})
+	}
+
+	if edit := proposeFileEdit(ToolCall{Name: "LS", Arguments: args(map[string]any{"path": dir})}); edit != nil {
+		t.Errorf("Expected no file edit for a call that doesn't edit files, got %+v", edit)
+	}
+}
+
+// TestFileEditApproval tests editing the proposed content before approval and
+// recording the change the call made in its result message
+func TestFileEditApproval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	call := ToolCall{ID: "1", Name: "Replace", Arguments: json.RawMessage(`{"file_path":"` + path + `","content":"one\ntwo\nthree"}`)}
+	m := &Model{requireApproval: true, showToolApproval: true, pendingToolCalls: []ToolCall{call}}
+
+	if content := m.renderToolApprovalModalContent(); !strings.Contains(content, "+three") {
+		t.Errorf("Expected the modal to show the proposed diff, got:\n%s", content)
+	}
+
+	// The editor adds a trailing newline the proposed content did not have
+	edited := filepath.Join(t.TempDir(), "edited.txt")
+	if err := os.WriteFile(edited, []byte("one\n2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.handleToolEditFinished(toolEditFinishedMsg{id: "1", path: edited})
+	if got := toolCallArgument(m.pendingToolCalls[0], "content"); got != "one\n2\nthree" {
+		t.Fatalf("Expected the edited content in the pending call, got %q", got)
+	}
+	if content := m.renderToolApprovalModalContent(); !strings.Contains(content, "-two") || !strings.Contains(content, "+2") {
+		t.Errorf("Expected the modal to show the edited diff, got:\n%s", content)
+	}
+
+	// Run the call as the tool would
+	if err := os.WriteFile(path, []byte("one\n2\nthree"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msg := m.toolResultMessage(&ToolResponse{Id: "1", Name: "Replace"}, ToolCallStatusCompleted)
+	for _, want := range []string{"-two", "+2", "+three"} {
+		if !strings.Contains(msg.Content, want) {
+			t.Errorf("Expected the result message to record %q, got:\n%s", want, msg.Content)
+		}
+	}
+}
+
+// TestFileToolEditPreview tests previewing a built-in file tool call, whose
+// path is relative to the workspace rather than the current directory
+func TestFileToolEditPreview(t *testing.T) {
+	ws := newTestWorkspace(t, map[string]string{"notes.txt": "one\n"})
+	m := &Model{toolManager: NewToolManager()}
+	m.toolManager.SetWorkspace(ws)
+
+	edit := m.toolFileEdit(ToolCall{ID: "1", Name: "edit_file", Arguments: json.RawMessage(`{"file_path":"notes.txt","old_string":"one","new_string":"two"}`)})
+	if edit == nil || edit.Err != nil || edit.Before != "one\n" || edit.After != "two\n" {
+		t.Errorf("Expected the edit of notes.txt in the workspace, got %+v", edit)
+	}
+	edit = m.toolFileEdit(ToolCall{ID: "2", Name: "write_file", Arguments: json.RawMessage(`{"file_path":"../outside.txt","content":"x"}`)})
+	if edit == nil || !errors.Is(edit.Err, ErrOutsideWorkspace) {
+		t.Errorf("Expected ErrOutsideWorkspace for a path outside the workspace, got %+v", edit)
+	}
+}
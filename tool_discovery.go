@@ -0,0 +1,238 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// toolExecutablePrefix names the executables run as tools, e.g. aistudio-tool-LS.
+const toolExecutablePrefix = "aistudio-tool-"
+
+const toolDescribeTimeout = 5 * time.Second
+
+// ToolDescription is the JSON a tool executable prints when run with
+// --describe. Name defaults to the part of the executable name after
+// "aistudio-tool-"; Parameters is a JSON schema. Timeout, such as "2m", is how
+// long a call may run, by default the same as for "custom" tools from a file.
+// ParallelSafe set to false makes calls run alone.
+type ToolDescription struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Parameters   json.RawMessage `json:"parameters,omitempty"`
+	Timeout      string          `json:"timeout,omitempty"`
+	ParallelSafe *bool           `json:"parallel_safe,omitempty"`
+}
+
+// DiscoveredTool is a tool executable found by DiscoverTools.
+type DiscoveredTool struct {
+	ToolDescription
+	Path string
+}
+
+// toolDescribeCacheEntry records the --describe result for one executable.
+// It is reused while the executable's modification time and size match.
+type toolDescribeCacheEntry struct {
+	ModTime time.Time        `json:"mod_time"`
+	Size    int64            `json:"size"`
+	Tool    *ToolDescription `json:"tool,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// DefaultToolsDir returns the per-user directory searched for tool executables.
+func DefaultToolsDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "aistudio", "tools")
+}
+
+// DefaultToolCacheFile returns the file caching --describe results.
+func DefaultToolCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "aistudio", "tool-descriptions.json")
+}
+
+// ToolSearchDirs returns the directories searched for tool executables: dir,
+// when set, followed by the PATH entries.
+func ToolSearchDirs(dir string) []string {
+	var dirs []string
+	if dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// DiscoverTools finds aistudio-tool-* executables in dirs and runs each with
+// --describe to learn its name, description and parameters. As with PATH
+// lookups, an executable in an earlier directory hides one of the same name
+// in a later one. Results are cached in cacheFile, keyed by the executable's
+// modification time and size, so unchanged executables are not run again;
+// an empty cacheFile disables the cache. Executables that fail to describe
+// themselves are logged and skipped. The returned error reports a cache that
+// could not be saved; the tools are valid regardless.
+func DiscoverTools(dirs []string, cacheFile string) ([]DiscoveredTool, error) {
+	cache := loadToolDescribeCache(cacheFile)
+	changed := false
+
+	var tools []DiscoveredTool
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Warning: Failed to scan %s for tools: %v", dir, err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, toolExecutablePrefix) || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			info, err := os.Stat(path) // Follows symlinks
+			if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			seen[name] = true
+
+			cached, ok := cache[path]
+			if !ok || !cached.ModTime.Equal(info.ModTime()) || cached.Size != info.Size() {
+				cached = describeToolExecutable(path, name, info)
+				cache[path] = cached
+				changed = true
+			}
+			if cached.Error != "" {
+				log.Printf("Warning: Skipping tool executable %s: %s", path, cached.Error)
+				continue
+			}
+			tools = append(tools, DiscoveredTool{ToolDescription: *cached.Tool, Path: path})
+		}
+	}
+
+	// Forget executables that no longer exist
+	for path := range cache {
+		if _, err := os.Stat(path); err != nil {
+			delete(cache, path)
+			changed = true
+		}
+	}
+	if changed && cacheFile != "" {
+		if err := saveToolDescribeCache(cacheFile, cache); err != nil {
+			return tools, fmt.Errorf("failed to save tool cache: %w", err)
+		}
+	}
+	return tools, nil
+}
+
+// describeToolExecutable runs path with --describe and records the result.
+func describeToolExecutable(path, name string, info fs.FileInfo) toolDescribeCacheEntry {
+	entry := toolDescribeCacheEntry{ModTime: info.ModTime(), Size: info.Size()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), toolDescribeTimeout)
+	defer cancel()
+	out, err := runToolExecutable(ctx, path, []string{"--describe"}, nil)
+	if err != nil {
+		entry.Error = fmt.Sprintf("--describe failed: %v", err)
+		return entry
+	}
+	var desc ToolDescription
+	if err := json.Unmarshal([]byte(out), &desc); err != nil {
+		entry.Error = fmt.Sprintf("invalid --describe output: %v", err)
+		return entry
+	}
+	if desc.Name == "" {
+		desc.Name = strings.TrimPrefix(name, toolExecutablePrefix)
+	}
+	if desc.Description == "" {
+		entry.Error = "--describe output has no description"
+		return entry
+	}
+	if len(desc.Parameters) > 0 && !json.Valid(desc.Parameters) {
+		entry.Error = "--describe output has invalid parameters"
+		return entry
+	}
+	if desc.Timeout != "" {
+		if d, err := time.ParseDuration(desc.Timeout); err != nil || d <= 0 {
+			entry.Error = fmt.Sprintf("--describe output has invalid timeout %q", desc.Timeout)
+			return entry
+		}
+	}
+	entry.Tool = &desc
+	return entry
+}
+
+// RegisterDiscoveredTools registers tools found by DiscoverTools and returns
+// how many were registered. Tools already registered, for example from a tools
+// file, keep their existing definition.
+func RegisterDiscoveredTools(tm *ToolManager, tools []DiscoveredTool) int {
+	registered := 0
+	for _, tool := range tools {
+		if _, exists := tm.RegisteredTools[tool.Name]; exists {
+			log.Printf("Skipping discovered tool %s (%s): already registered", tool.Name, tool.Path)
+			continue
+		}
+		path := tool.Path
+		handler := func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+			return runToolExecutable(ctx, path, []string{string(args)}, progress)
+		}
+		if err := tm.RegisterStreamingTool(tool.Name, tool.Description, tool.Parameters, handler); err != nil {
+			log.Printf("Warning: Failed to register discovered tool '%s': %v", tool.Name, err)
+			continue
+		}
+		timeout := customToolTimeout
+		if tool.Timeout != "" {
+			timeout, _ = time.ParseDuration(tool.Timeout) // Checked by describeToolExecutable
+		}
+		tm.SetToolTimeout(tool.Name, timeout)
+		tm.SetToolExclusive(tool.Name, tool.ParallelSafe != nil && !*tool.ParallelSafe)
+		log.Printf("Registered discovered tool: %s (%s)", tool.Name, path)
+		registered++
+	}
+	return registered
+}
+
+func loadToolDescribeCache(cacheFile string) map[string]toolDescribeCacheEntry {
+	cache := make(map[string]toolDescribeCacheEntry)
+	if cacheFile == "" {
+		return cache
+	}
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("Warning: Ignoring unreadable tool cache %s: %v", cacheFile, err)
+		return make(map[string]toolDescribeCacheEntry)
+	}
+	return cache
+}
+
+func saveToolDescribeCache(cacheFile string, cache map[string]toolDescribeCacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cacheFile, data, 0o644)
+}
@@ -0,0 +1,114 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeToolScript writes a shell script tool that logs each --describe to
+// describeLog.
+func writeToolScript(t *testing.T, dir, name, describe, describeLog string, mode os.FileMode) string {
+	t.Helper()
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"--describe\" ]; then\n" +
+		"  echo describe >> " + describeLog + "\n" +
+		"  " + describe + "\n" +
+		"fi\n" +
+		"echo \"got $1\"\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func countDescribes(t *testing.T, describeLog string) int {
+	t.Helper()
+	data, err := os.ReadFile(describeLog)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "describe")
+}
+
+func TestDiscoverTools(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	describeLog := filepath.Join(t.TempDir(), "describes.log")
+	cacheFile := filepath.Join(t.TempDir(), "cache", "tools.json")
+
+	greetDesc := `echo '{"description":"Greets someone","parameters":{"type":"object","properties":{"who":{"type":"string"}}}}'; exit 0`
+	greet := writeToolScript(t, first, "aistudio-tool-greet", greetDesc, describeLog, 0o755)
+	writeToolScript(t, second, "aistudio-tool-greet", `echo '{"description":"Hidden"}'; exit 0`, describeLog, 0o755)
+	writeToolScript(t, first, "aistudio-tool-broken", "exit 1", describeLog, 0o755)
+	writeToolScript(t, first, "aistudio-tool-garbled", "echo not json; exit 0", describeLog, 0o755)
+	writeToolScript(t, first, "aistudio-tool-noexec", greetDesc, describeLog, 0o644)
+	writeToolScript(t, first, "other-tool", greetDesc, describeLog, 0o755)
+
+	dirs := []string{first, filepath.Join(first, "missing"), second}
+	tools, err := DiscoverTools(dirs, cacheFile)
+	if err != nil {
+		t.Fatalf("DiscoverTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "greet" || tools[0].Path != greet || tools[0].Description != "Greets someone" {
+		t.Fatalf("Expected only greet from the first directory, got %+v", tools)
+	}
+	if n := countDescribes(t, describeLog); n != 3 {
+		t.Errorf("Expected greet, broken and garbled to be described, got %d runs", n)
+	}
+
+	// Unchanged executables, including failing ones, come from the cache
+	if tools, err = DiscoverTools(dirs, cacheFile); err != nil || len(tools) != 1 {
+		t.Fatalf("Second DiscoverTools = %+v, %v", tools, err)
+	}
+	if n := countDescribes(t, describeLog); n != 3 {
+		t.Errorf("Expected cached descriptions, got %d runs", n)
+	}
+
+	// A changed executable is described again
+	writeToolScript(t, first, "aistudio-tool-greet", `echo '{"name":"hello","description":"Says hello"}'; exit 0`, describeLog, 0o755)
+	if tools, err = DiscoverTools(dirs, cacheFile); err != nil || len(tools) != 1 {
+		t.Fatalf("Third DiscoverTools = %+v, %v", tools, err)
+	}
+	if n := countDescribes(t, describeLog); n != 4 || tools[0].Name != "hello" {
+		t.Errorf("Expected greet to be described again as hello, got %d runs and %+v", n, tools[0])
+	}
+}
+
+func TestRegisterDiscoveredTools(t *testing.T) {
+	dir := t.TempDir()
+	describeLog := filepath.Join(t.TempDir(), "describes.log")
+	writeToolScript(t, dir, "aistudio-tool-greet", `echo '{"description":"Greets someone"}'; exit 0`, describeLog, 0o755)
+	writeToolScript(t, dir, "aistudio-tool-list_files", `echo '{"description":"Lists files"}'; exit 0`, describeLog, 0o755)
+
+	tools, err := DiscoverTools([]string{dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := NewToolManager()
+	existing := func(json.RawMessage) (any, error) { return "from file", nil }
+	if err := tm.RegisterTool("list_files", "Lists files from the tools file", nil, existing); err != nil {
+		t.Fatal(err)
+	}
+	if n := RegisterDiscoveredTools(tm, tools); n != 1 {
+		t.Errorf("Expected 1 tool registered, got %d", n)
+	}
+	if desc := tm.RegisteredTools["list_files"].ToolDefinition.Description; desc != "Lists files from the tools file" {
+		t.Errorf("Expected the existing definition to win, got %q", desc)
+	}
+
+	resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: "greet", Arguments: json.RawMessage(`{"who":"you"}`)})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := resp.Response.Fields["result"].GetStringValue(); got != "got {\"who\":\"you\"}\n" {
+		t.Errorf("Unexpected tool output %q", got)
+	}
+}
@@ -0,0 +1,265 @@
+package aistudio
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tmc/aistudio/api"
+)
+
+// toolReloadInterval is how often tool sources are checked for changes.
+const toolReloadInterval = 2 * time.Second
+
+// toolSource is a tools file or tools directory. Its tools are loaded again
+// when it changes: changed tools are registered anew and tools no longer in
+// it are removed.
+type toolSource struct {
+	name     string                       // The file or directory, for notices
+	override bool                         // Its tools replace tools of the same name from elsewhere
+	stamp    func() string                // Changes when the source does
+	load     func() (*ToolManager, error) // Loads the source's tools into a new manager
+
+	last    string          // Stamp of the last load
+	tools   map[string]bool // Names of the tools registered from the source
+	pending *ToolManager    // Loaded tools waiting for the current turn to end
+}
+
+// toolChanges summarizes how reloading a tool source changed the tools.
+type toolChanges struct {
+	added, updated, removed []string
+}
+
+func (c toolChanges) String() string {
+	var parts []string
+	for _, part := range []struct {
+		verb  string
+		names []string
+	}{{"added", c.added}, {"updated", c.updated}, {"removed", c.removed}} {
+		if len(part.names) > 0 {
+			parts = append(parts, part.verb+" "+strings.Join(part.names, ", "))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes to tool declarations"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// toolsFileSource returns the source for the tools file at path.
+func (m *Model) toolsFileSource(path string) *toolSource {
+	return &toolSource{
+		name:     path,
+		override: true,
+		stamp:    func() string { return fileStamp(path) },
+		load: func() (*ToolManager, error) {
+			tm := NewToolManager()
+			tm.workspace = m.toolManager.workspace
+			if err := LoadToolsFromFile(path, tm); err != nil {
+				return nil, err
+			}
+			return tm, nil
+		},
+	}
+}
+
+// toolsDirSource returns the source for the tool executables found in dir and
+// on PATH. Only dir is watched for changes.
+func toolsDirSource(dir string) *toolSource {
+	return &toolSource{
+		name: dir,
+		stamp: func() string {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return ""
+			}
+			var stamp strings.Builder
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), toolExecutablePrefix) {
+					fmt.Fprintf(&stamp, "%s:%s\n", entry.Name(), fileStamp(filepath.Join(dir, entry.Name())))
+				}
+			}
+			return stamp.String()
+		},
+		load: func() (*ToolManager, error) {
+			tools, err := DiscoverTools(ToolSearchDirs(dir), DefaultToolCacheFile())
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			tm := NewToolManager()
+			RegisterDiscoveredTools(tm, tools)
+			return tm, nil
+		},
+	}
+}
+
+// fileStamp identifies the version of the file at path by its modification
+// time, size and mode, or returns "" if it cannot be read.
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d/%v", info.ModTime().UnixNano(), info.Size(), info.Mode())
+}
+
+// addToolSource loads the tools of src and watches it for changes.
+func (m *Model) addToolSource(src *toolSource) (toolChanges, error) {
+	src.last = src.stamp()
+	loaded, err := src.load()
+	if err != nil {
+		return toolChanges{}, err
+	}
+	m.toolSources = append(m.toolSources, src)
+	return m.applyToolSource(src, loaded), nil
+}
+
+// applyToolSource replaces the tools registered from src with the loaded
+// ones. Tools of a source without override do not replace tools of the same
+// name from elsewhere.
+func (m *Model) applyToolSource(src *toolSource, loaded *ToolManager) toolChanges {
+	tm := m.toolManager
+	var changes toolChanges
+	names := make(map[string]bool)
+	for name, tool := range loaded.RegisteredTools {
+		old, exists := tm.RegisteredTools[name]
+		if exists && !src.tools[name] && !src.override {
+			continue
+		}
+		names[name] = true
+		switch {
+		case !exists:
+			changes.added = append(changes.added, name)
+		case !sameToolDefinition(old.ToolDefinition, tool.ToolDefinition):
+			changes.updated = append(changes.updated, name)
+		}
+		tm.setTool(tool)
+		for _, other := range m.toolSources {
+			if other != src {
+				delete(other.tools, name)
+			}
+		}
+	}
+	for name := range src.tools {
+		if !names[name] {
+			tm.UnregisterTool(name)
+			changes.removed = append(changes.removed, name)
+		}
+	}
+	src.tools = names
+	src.pending = nil
+	sort.Strings(changes.added)
+	sort.Strings(changes.updated)
+	sort.Strings(changes.removed)
+	return changes
+}
+
+// sameToolDefinition reports whether a and b declare the same tool to the
+// model.
+func sameToolDefinition(a, b api.ToolDefinition) bool {
+	return a.Name == b.Name && a.Description == b.Description && proto.Equal(a.Parameters, b.Parameters)
+}
+
+// toolReloadTickMsg checks the tool sources for changes.
+type toolReloadTickMsg time.Time
+
+// toolSourceLoadedMsg carries the tools loaded from a changed source.
+type toolSourceLoadedMsg struct {
+	src   *toolSource
+	tools *ToolManager
+	err   error
+}
+
+// toolReloadTickCmd schedules the next check of the tool sources.
+func (m *Model) toolReloadTickCmd() tea.Cmd {
+	if len(m.toolSources) == 0 {
+		return nil
+	}
+	return tea.Tick(toolReloadInterval, func(t time.Time) tea.Msg {
+		return toolReloadTickMsg(t)
+	})
+}
+
+// handleToolReloadTick loads the tool sources that changed, and applies
+// reloads that waited for a turn to end.
+func (m *Model) handleToolReloadTick() tea.Cmd {
+	cmds := []tea.Cmd{m.toolReloadTickCmd()}
+	for _, src := range m.toolSources {
+		if src.pending != nil && !m.toolsInUse() {
+			m.reportToolReload(src, m.applyToolSource(src, src.pending))
+		}
+		if stamp := src.stamp(); stamp != src.last {
+			src.last = stamp
+			// Loading may run executables, so it happens off the UI goroutine
+			cmds = append(cmds, func() tea.Msg {
+				tools, err := src.load()
+				return toolSourceLoadedMsg{src: src, tools: tools, err: err}
+			})
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleToolSourceLoaded registers the tools loaded from a changed source.
+// The chat reads the tool manager while a turn runs, so the tools are
+// replaced once it ends.
+func (m *Model) handleToolSourceLoaded(msg toolSourceLoadedMsg) {
+	if msg.err != nil {
+		log.Printf("Warning: failed to reload tools from %s, keeping the current tools: %v", msg.src.name, msg.err)
+		return
+	}
+	if m.toolsInUse() {
+		msg.src.pending = msg.tools
+		log.Printf("Tools in %s changed; reloading them after the current turn", msg.src.name)
+		return
+	}
+	m.reportToolReload(msg.src, m.applyToolSource(msg.src, msg.tools))
+}
+
+// toolsInUse reports whether a turn or tool call may be reading the tool
+// manager.
+func (m *Model) toolsInUse() bool {
+	return m.isGenerating() || m.processingTool || m.chatStream != nil
+}
+
+// reportToolReload notes a reload in the log panel.
+func (m *Model) reportToolReload(src *toolSource, changes toolChanges) {
+	notice := fmt.Sprintf("Reloaded tools from %s: %s", src.name, changes)
+	if m.bidiStream != nil && !m.usesChat() {
+		notice += " (the live session uses them once it reconnects)"
+	}
+	log.Print(notice)
+}
+
+// setTool registers tool, replacing any tool of the same name.
+func (tm *ToolManager) setTool(tool api.RegisteredTool) {
+	name := tool.ToolDefinition.Name
+	tm.UnregisterTool(name)
+	tm.RegisteredTools[name] = tool
+	registered := tm.RegisteredTools[name]
+	tm.RegisteredToolDefs = append(tm.RegisteredToolDefs, &registered.ToolDefinition)
+}
+
+// UnregisterTool removes the named tool, reporting whether it was registered.
+func (tm *ToolManager) UnregisterTool(name string) bool {
+	if _, ok := tm.RegisteredTools[name]; !ok {
+		return false
+	}
+	delete(tm.RegisteredTools, name)
+	// A new slice: live sessions may hold the old one
+	var defs []*api.ToolDefinition
+	for _, def := range tm.RegisteredToolDefs {
+		if def.Name != name {
+			defs = append(defs, def)
+		}
+	}
+	tm.RegisteredToolDefs = defs
+	return true
+}
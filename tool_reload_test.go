@@ -0,0 +1,139 @@
+package aistudio
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// toolNames returns the sorted names of the tools declared to the model.
+func toolNames(tm *ToolManager) []string {
+	var names []string
+	for _, def := range tm.GetAvailableTools() {
+		names = append(names, def.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeToolsFile writes a tools file, moving its modification time on so
+// that each write is seen as a change.
+func writeToolsFile(t *testing.T, path, content string, version int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(version) * time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToolReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools.json")
+	writeToolsFile(t, path, `[
+		{"name": "greet", "description": "Greets someone", "command": "greet"},
+		{"name": "shout", "description": "Shouts", "command": "shout"}
+	]`, 0)
+
+	m := &Model{enableTools: true, toolManager: NewToolManager()}
+	if _, err := m.addToolSource(m.toolsFileSource(path)); err != nil {
+		t.Fatal(err)
+	}
+	src := m.toolSources[0]
+	if got := toolNames(m.toolManager); !reflect.DeepEqual(got, []string{"greet", "shout"}) {
+		t.Fatalf("Got tools %v", got)
+	}
+
+	// reload loads the changed file as a tick would
+	reload := func() {
+		t.Helper()
+		if src.stamp() == src.last {
+			t.Fatal("Expected the tools file change to be seen")
+		}
+		src.last = src.stamp()
+		tools, err := src.load()
+		m.handleToolSourceLoaded(toolSourceLoadedMsg{src: src, tools: tools, err: err})
+	}
+
+	writeToolsFile(t, path, `[
+		{"name": "greet", "description": "Greets someone by name", "command": "greet",
+		 "parameters": {"type": "object", "properties": {"name": {"type": "string"}}}},
+		{"name": "wave", "description": "Waves", "command": "wave"}
+	]`, 1)
+	reload()
+	if got := toolNames(m.toolManager); !reflect.DeepEqual(got, []string{"greet", "wave"}) {
+		t.Fatalf("Expected shout removed and wave added, got %v", got)
+	}
+	if len(m.toolManager.RegisteredToolDefs) != 2 {
+		t.Errorf("Expected 2 tool definitions, got %d", len(m.toolManager.RegisteredToolDefs))
+	}
+	greet := m.toolManager.RegisteredTools["greet"].ToolDefinition
+	if greet.Description != "Greets someone by name" || greet.Parameters.GetProperties()["name"] == nil {
+		t.Errorf("Expected the updated greet declaration, got %+v", greet)
+	}
+
+	t.Run("invalid file", func(t *testing.T) {
+		writeToolsFile(t, path, `[{"name": "greet",`, 2)
+		reload()
+		if got := toolNames(m.toolManager); !reflect.DeepEqual(got, []string{"greet", "wave"}) {
+			t.Errorf("Expected the current tools to be kept, got %v", got)
+		}
+	})
+
+	t.Run("during a turn", func(t *testing.T) {
+		m.currentState = AppStateResponding
+		writeToolsFile(t, path, `[{"name": "wave", "description": "Waves", "command": "wave"}]`, 3)
+		reload()
+		if got := toolNames(m.toolManager); !reflect.DeepEqual(got, []string{"greet", "wave"}) {
+			t.Errorf("Expected the tools to be kept until the turn ends, got %v", got)
+		}
+		m.currentState = AppStateReady
+		m.handleToolReloadTick()
+		if got := toolNames(m.toolManager); !reflect.DeepEqual(got, []string{"wave"}) {
+			t.Errorf("Expected the tools reloaded after the turn, got %v", got)
+		}
+	})
+}
+
+func TestToolReloadDir(t *testing.T) {
+	dir := t.TempDir()
+	describeLog := filepath.Join(t.TempDir(), "describes.log")
+	t.Setenv("PATH", "")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	writeToolScript(t, dir, "aistudio-tool-greet", `echo '{"description":"Greets someone"}'; exit 0`, describeLog, 0o755)
+
+	m := &Model{enableTools: true, toolManager: NewToolManager()}
+	if err := m.toolManager.RegisterTool("list_files", "Lists files from the tools file", nil, func(json.RawMessage) (any, error) { return "from file", nil }); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := m.addToolSource(toolsDirSource(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes.added, []string{"greet"}) {
+		t.Errorf("Expected greet added, got %v", changes)
+	}
+
+	src := m.toolSources[0]
+	os.Remove(filepath.Join(dir, "aistudio-tool-greet"))
+	writeToolScript(t, dir, "aistudio-tool-list_files", `echo '{"description":"Lists files"}'; exit 0`, describeLog, 0o755)
+	if src.stamp() == src.last {
+		t.Fatal("Expected the tools directory change to be seen")
+	}
+	tools, err := src.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = m.applyToolSource(src, tools)
+	if !reflect.DeepEqual(changes.removed, []string{"greet"}) || len(changes.added) != 0 {
+		t.Errorf("Expected greet removed, got %v", changes)
+	}
+	if desc := m.toolManager.RegisteredTools["list_files"].ToolDefinition.Description; desc != "Lists files from the tools file" {
+		t.Errorf("Expected the existing definition to win, got %q", desc)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
@@ -41,6 +43,18 @@ type ToolManager struct {
 	// RegisteredTools holds all available tools that can be called
 	RegisteredTools    map[string]api.RegisteredTool
 	RegisteredToolDefs []*api.ToolDefinition // Store the tool definitions for reference
+
+	// Scheduling of concurrent tool calls; see acquire
+	slots     chan struct{} // One token per call that may run at once
+	exclusive chan struct{} // Held by an exclusive call while it gathers every slot
+
+	workspace *Workspace // Confines the file tools; see fs_tools.go
+
+	webFetcher *WebFetcher // Used by web_fetch; see web_fetch.go
+
+	// Results larger than resultLimit bytes are stored in artifacts; see artifacts.go
+	artifacts   *ArtifactStore
+	resultLimit int
 }
 
 type ToolCallStatus string
@@ -52,96 +66,162 @@ const (
 	ToolCallStatusApproved  ToolCallStatus = "approved"
 	ToolCallStatusRejected  ToolCallStatus = "rejected"
 	ToolCallStatusCompleted ToolCallStatus = "completed"
+	ToolCallStatusCancelled ToolCallStatus = "cancelled"
 )
 
-// JSONSchema represents a standard JSON Schema structure, used for intermediate
-// unmarshalling of tool parameters before converting to the protobuf Schema type.
-type JSONSchema struct {
-	Type        string                 `json:"type"`
-	Description string                 `json:"description,omitempty"`
-	Format      string                 `json:"format,omitempty"`
-	Nullable    bool                   `json:"nullable,omitempty"`
-	Enum        []string               `json:"enum,omitempty"`
-	Properties  map[string]*JSONSchema `json:"properties,omitempty"` // Recursive for object
-	Required    []string               `json:"required,omitempty"`   // For object
-	Items       *JSONSchema            `json:"items,omitempty"`      // Recursive for array
-}
-
-// convertJSONSchemaToProtoSchema converts the intermediate JSONSchema representation
-// into the generativelanguagepb.Schema format required by the API.
-func convertJSONSchemaToProtoSchema(js *JSONSchema) (*generativelanguagepb.Schema, error) {
-	if js == nil {
-		return nil, nil
+// Finished reports whether a tool call with this status has ended.
+func (s ToolCallStatus) Finished() bool {
+	switch s {
+	case ToolCallStatusRejected, ToolCallStatusCompleted, ToolCallStatusCancelled:
+		return true
 	}
+	return false
+}
 
-	protoSchema := &generativelanguagepb.Schema{
-		Description: js.Description,
-		Nullable:    js.Nullable,
-		Format:      js.Format,
-		Enum:        js.Enum,
-	}
-
-	// Convert the type string to the corresponding generativelanguagepb.Type enum
-	switch strings.ToLower(js.Type) {
-	case "string":
-		protoSchema.Type = generativelanguagepb.Type_STRING
-	case "integer", "int", "int32", "int64":
-		protoSchema.Type = generativelanguagepb.Type_INTEGER
-	case "number", "float", "double":
-		protoSchema.Type = generativelanguagepb.Type_NUMBER
-	case "boolean", "bool":
-		protoSchema.Type = generativelanguagepb.Type_BOOLEAN
-	case "array":
-		protoSchema.Type = generativelanguagepb.Type_ARRAY
-		if js.Items != nil {
-			var err error
-			protoSchema.Items, err = convertJSONSchemaToProtoSchema(js.Items)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert array items for field '%s': %w", js.Description, err)
-			}
-		}
-	case "object":
-		protoSchema.Type = generativelanguagepb.Type_OBJECT
-		protoSchema.Properties = make(map[string]*generativelanguagepb.Schema)
-		for key, propJSONSchema := range js.Properties {
-			var err error
-			protoSchema.Properties[key], err = convertJSONSchemaToProtoSchema(propJSONSchema)
-			if err != nil {
-				// Add context about which property failed
-				return nil, fmt.Errorf("failed to convert object property '%s' (described as '%s'): %w", key, propJSONSchema.Description, err)
-			}
-		}
-		protoSchema.Required = js.Required
-	case "":
-		// If type is empty, treat as unspecified. Could potentially infer object if properties exist,
-		// but explicit type is preferred.
-		protoSchema.Type = generativelanguagepb.Type_TYPE_UNSPECIFIED
-		if len(js.Properties) > 0 {
-			log.Printf("Warning: Schema for field '%s' has properties but no 'type' specified. Treating as unspecified.", js.Description)
-		}
-	default:
-		// Add context about the field description
-		return nil, fmt.Errorf("unsupported schema type: '%s' for field '%s'", js.Type, js.Description)
-	}
+// ToolStreamHandler handles a tool call that can be cancelled and report progress.
+type ToolStreamHandler = api.ToolStreamHandler
 
-	return protoSchema, nil
+// ErrToolCancelled is the cause given when the user cancels a running tool call.
+var ErrToolCancelled = errors.New("tool call cancelled by user")
+
+// ToolArgumentsError reports tool call arguments that do not match the tool's
+// parameter schema. Problems holds one description per mismatch, prefixed with
+// the location of the offending value ($ is the arguments object).
+type ToolArgumentsError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ToolArgumentsError) Error() string {
+	return fmt.Sprintf("invalid arguments for tool '%s': %s", e.Tool, strings.Join(e.Problems, "; "))
 }
 
+// response tells the model what was wrong, so it can correct the call.
+func (e *ToolArgumentsError) response() *structpb.Struct {
+	problems := make([]any, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p
+	}
+	s, _ := structpb.NewStruct(map[string]any{
+		"error":             fmt.Sprintf("invalid arguments for tool '%s'; correct them and call the tool again", e.Tool),
+		"validation_errors": problems,
+	})
+	return s
+}
+
+const (
+	// defaultToolTimeout is how long a tool call may run when its tool sets no timeout.
+	defaultToolTimeout = 60 * time.Second
+	// customToolTimeout is the default for "custom" command tools from a tools file.
+	customToolTimeout = 5 * time.Second
+	// maxToolProgressLines is how many progress lines a running tool call shows.
+	maxToolProgressLines = 5
+	// maxToolOutputLines is how many progress lines are kept for the result
+	// of a tool call, such as the transcript of a sub-agent.
+	maxToolOutputLines = 1000
+	// defaultToolConcurrency is how many tool calls may run at once.
+	defaultToolConcurrency = 4
+)
+
+// JSONSchema is the intermediate form of tool parameter schemas; see
+// api.ConvertJSONSchema.
+type JSONSchema = api.JSONSchema
+
 // NewToolManager creates a new tool manager
 func NewToolManager() *ToolManager {
-	return &ToolManager{
+	tm := &ToolManager{
 		RegisteredTools: make(map[string]api.RegisteredTool),
 	}
+	tm.SetConcurrency(defaultToolConcurrency)
+	return tm
+}
+
+// SetConcurrency sets how many tool calls may run at once. It must be called
+// before tools run.
+func (tm *ToolManager) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	tm.slots = make(chan struct{}, n)
+	tm.exclusive = make(chan struct{}, 1)
+}
+
+// acquire waits until a call to the named tool may run: a slot is free and,
+// for exclusive tools, no other call is running. The returned func must be
+// called when the call ends.
+func (tm *ToolManager) acquire(ctx context.Context, name string) (func(), error) {
+	if tm.slots == nil {
+		tm.SetConcurrency(defaultToolConcurrency)
+	}
+	n := 1
+	if tm.RegisteredTools[name].Exclusive {
+		// Take every slot. One exclusive call gathers slots at a time, so
+		// two cannot each hold part of them.
+		select {
+		case tm.exclusive <- struct{}{}:
+			defer func() { <-tm.exclusive }()
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+		n = cap(tm.slots)
+	}
+
+	release := func(held int) {
+		for range held {
+			<-tm.slots
+		}
+	}
+	for held := 0; held < n; held++ {
+		select {
+		case tm.slots <- struct{}{}:
+		case <-ctx.Done():
+			release(held)
+			return nil, context.Cause(ctx)
+		}
+	}
+	return func() { release(n) }, nil
+}
+
+// ExecuteQueued runs a tool call once acquire gives it a turn, calling
+// started as it begins. A call cancelled while it waits gets the same
+// response as one cancelled while running.
+func (tm *ToolManager) ExecuteQueued(ctx context.Context, call ToolCall, started func(), progress func(line string)) (*ToolResponse, error) {
+	done, err := tm.acquire(ctx, call.Name)
+	if err != nil {
+		return toolContextResponse(ctx, &ToolResponse{Id: call.ID, Name: call.Name}, 0)
+	}
+	defer done()
+	if started != nil {
+		started()
+	}
+	return tm.ExecuteWithProgress(ctx, call, progress)
 }
 
 // Message to indicate that a tool call has been sent
 type toolCallSentMsg struct{}
 
+// toolStartedMsg reports that a tool call got its turn and started running.
+type toolStartedMsg struct {
+	id string
+}
+
+// toolBatchResultMsg carries the results of tool calls made together, in the
+// order of the calls, once all of them finished.
+type toolBatchResultMsg struct {
+	results []*ToolResponse
+}
+
+// toolProgressMsg carries a progress line from a running tool call.
+type toolProgressMsg struct {
+	id   string
+	line string
+}
+
 // Message for tool call results
 type toolCallResultMsg struct {
-	results   []*ToolResponse    // Each response includes its tool call ID in the Id field
-	call      ToolCall           // The original tool call (for additional context if needed)
-	viewModel *ToolCallViewModel // The view model for UI rendering
+	results []*ToolResponse // Each response includes its tool call ID in the Id field
+	call    ToolCall        // The original tool call (for additional context if needed)
+	err     error           // The error the call ended with, if any
 }
 
 // getOrCreateToolVM returns an existing ToolCallViewModel or creates a new one.
@@ -187,7 +267,7 @@ func convertAndValidateParameters(toolName string, params json.RawMessage) (*gen
 		return nil, fmt.Errorf("tool '%s': failed to unmarshal parameters JSON: %w", toolName, err)
 	}
 
-	protoSchema, err := convertJSONSchemaToProtoSchema(&js)
+	protoSchema, err := api.ConvertJSONSchema(&js)
 	if err != nil {
 		return nil, fmt.Errorf("tool '%s': failed to convert parameters schema: %w", toolName, err)
 	}
@@ -381,6 +461,48 @@ func (tm *ToolManager) RegisterTool(name, description string, parameters json.Ra
 	return nil
 }
 
+// RegisterStreamingTool registers a tool whose handler can be cancelled and
+// reports progress while it runs.
+func (tm *ToolManager) RegisterStreamingTool(name, description string, parameters json.RawMessage, handler ToolStreamHandler) error {
+	if handler == nil {
+		return fmt.Errorf("handler cannot be nil for tool '%s'", name)
+	}
+	err := tm.RegisterTool(name, description, parameters, func(args json.RawMessage) (any, error) {
+		return handler(context.Background(), args, func(string) {})
+	})
+	if err != nil {
+		return err
+	}
+	tool := tm.RegisteredTools[name]
+	tool.StreamHandler = handler
+	tm.RegisteredTools[name] = tool
+	return nil
+}
+
+// SetToolExclusive marks a registered tool as not safe to run in parallel:
+// its calls run alone, never alongside other tool calls.
+func (tm *ToolManager) SetToolExclusive(name string, exclusive bool) error {
+	tool, ok := tm.RegisteredTools[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' is not registered", name)
+	}
+	tool.Exclusive = exclusive
+	tm.RegisteredTools[name] = tool
+	return nil
+}
+
+// SetToolTimeout sets how long calls to a registered tool may run. Zero
+// restores the default.
+func (tm *ToolManager) SetToolTimeout(name string, timeout time.Duration) error {
+	tool, ok := tm.RegisteredTools[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' is not registered", name)
+	}
+	tool.Timeout = timeout
+	tm.RegisteredTools[name] = tool
+	return nil
+}
+
 // GetAvailableTools returns the definitions of all available tools
 func (tm *ToolManager) GetAvailableTools() []*api.ToolDefinition {
 	var availableTools []*api.ToolDefinition
@@ -418,6 +540,37 @@ func (m *Model) processToolCalls(toolCalls []ToolCall) ([]*ToolResponse, error)
 		return nil, fmt.Errorf("tool manager not initialized")
 	}
 
+	// Answer calls with invalid arguments right away so the model can correct
+	// them; the others go on with the defaults of their parameters filled in
+	var rejected []*ToolResponse
+	var valid []ToolCall
+	for _, call := range toolCalls {
+		prepared, err := m.toolManager.PrepareToolCall(call)
+		if err != nil {
+			log.Printf("Tool call rejected: %v", err)
+			vm := m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
+				vm.Name = call.Name
+				vm.Arguments = call.Arguments
+				vm.Status = toolResultStatus(err)
+				vm.Error = err
+			})
+			m.replaceToolCallMessage(*vm)
+			rejected = append(rejected, toolArgumentsResponse(call, err))
+			continue
+		}
+		valid = append(valid, prepared)
+	}
+	results, err := m.approveToolCalls(valid)
+	return append(rejected, results...), err
+}
+
+// approveToolCalls executes the tool calls that need no approval and leaves
+// the others waiting for it.
+func (m *Model) approveToolCalls(toolCalls []ToolCall) ([]*ToolResponse, error) {
+	if len(toolCalls) == 0 {
+		return nil, nil
+	}
+
 	// If tool approval is required, check if any tools need approval
 	if m.requireApproval {
 		// Filter out already approved tool types
@@ -470,6 +623,144 @@ func mkErrorResponseStruct(err error) *structpb.Struct {
 	return s
 }
 
+// PrepareToolCall checks the arguments of call against the tool's parameter
+// schema and fills in the defaults the schema declares for arguments left out.
+// Arguments that do not match return a *ToolArgumentsError. Calls to unknown
+// tools and tools without parameters are returned unchanged.
+func (tm *ToolManager) PrepareToolCall(call ToolCall) (ToolCall, error) {
+	schema := tm.RegisteredTools[call.Name].ToolDefinition.Parameters
+	if schema == nil {
+		return call, nil
+	}
+	args := call.Arguments
+	if len(bytes.TrimSpace(args)) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	args, err := api.ApplyDefaults(args, schema)
+	if err != nil {
+		return call, &ToolArgumentsError{Tool: call.Name, Problems: []string{"$: " + err.Error()}}
+	}
+	if problems := api.ValidateJSON(args, schema); len(problems) > 0 {
+		return call, &ToolArgumentsError{Tool: call.Name, Problems: problems}
+	}
+	call.Arguments = args
+	return call, nil
+}
+
+// toolArgumentsResponse returns the response for a call whose arguments
+// PrepareToolCall rejected.
+func toolArgumentsResponse(call ToolCall, err error) *ToolResponse {
+	result := &ToolResponse{Id: call.ID, Name: call.Name, Response: mkErrorResponseStruct(err)}
+	var argsErr *ToolArgumentsError
+	if errors.As(err, &argsErr) {
+		result.Response = argsErr.response()
+	}
+	return result
+}
+
+// Execute runs a tool call and returns its response. The error reports a
+// missing tool, invalid arguments, a failed handler or a cancelled call; the
+// response carries it too, so the response can always be sent back to the
+// model.
+func (tm *ToolManager) Execute(ctx context.Context, call ToolCall) (*ToolResponse, error) {
+	return tm.ExecuteWithProgress(ctx, call, nil)
+}
+
+// ExecuteWithProgress runs a tool call, passing progress lines from tools with
+// a streaming handler to progress, which may be nil. The call is limited to the
+// tool's timeout. A call cancelled with ErrToolCancelled as the cause, or one
+// that times out, still returns a response telling the model what happened.
+func (tm *ToolManager) ExecuteWithProgress(ctx context.Context, call ToolCall, progress func(line string)) (*ToolResponse, error) {
+	result := &ToolResponse{
+		Id:   call.ID,
+		Name: call.Name,
+	}
+
+	// Check if the tool exists and is available
+	registeredTool, exists := tm.RegisteredTools[call.Name]
+	if !exists || !registeredTool.IsAvailable {
+		err := fmt.Errorf("tool '%s' not found or not available", call.Name)
+		result.Response = mkErrorResponseStruct(err)
+		return result, err
+	}
+	call, err := tm.PrepareToolCall(call)
+	if err != nil {
+		return toolArgumentsResponse(call, err), err
+	}
+
+	if progress == nil {
+		progress = func(string) {}
+	}
+	timeout := registeredTool.Timeout
+	if timeout <= 0 {
+		timeout = defaultToolTimeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Execute the tool handler, giving up if the call is cancelled
+	type handlerResult struct {
+		response any
+		err      error
+	}
+	done := make(chan handlerResult, 1)
+	go func() {
+		var response any
+		var err error
+		if registeredTool.StreamHandler != nil {
+			response, err = registeredTool.StreamHandler(callCtx, call.Arguments, progress)
+		} else {
+			response, err = registeredTool.Handler(call.Arguments)
+		}
+		done <- handlerResult{response, err}
+	}()
+	var res handlerResult
+	select {
+	case res = <-done:
+	case <-callCtx.Done():
+	}
+	if callCtx.Err() != nil {
+		return toolContextResponse(callCtx, result, timeout)
+	}
+
+	// Create the response map
+	resp := map[string]any{
+		"result": res.response,
+	}
+	if res.err != nil {
+		resp["error"] = res.err.Error()
+	}
+	tm.storeLargeResult(call.Name, resp)
+	response, err := structpb.NewStruct(resp)
+	if err != nil {
+		err = fmt.Errorf("tool '%s' returned a result that cannot be sent: %w", call.Name, err)
+		result.Response = mkErrorResponseStruct(err)
+		return result, err
+	}
+	result.Response = response
+	return result, res.err
+}
+
+// toolContextResponse fills in the response for a call stopped by its
+// context: cancelled by the user, timed out, or abandoned with the turn.
+func toolContextResponse(ctx context.Context, result *ToolResponse, timeout time.Duration) (*ToolResponse, error) {
+	var resp map[string]any
+	var err error
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, ErrToolCancelled):
+		err = ErrToolCancelled
+		resp = map[string]any{"error": err.Error(), "cancelled": true}
+	case errors.Is(cause, context.DeadlineExceeded):
+		err = fmt.Errorf("tool call timed out after %s", timeout)
+		resp = map[string]any{"error": err.Error(), "timed_out": true}
+	default:
+		err = fmt.Errorf("tool call canceled: %v", ctx.Err())
+		resp = map[string]any{"error": err.Error()}
+	}
+	result.Response, _ = structpb.NewStruct(resp)
+	return result, err
+}
+
 // ToolCallViewModel represents a tool call for rendering purposes
 type ToolCallViewModel struct {
 	ID        string
@@ -479,6 +770,35 @@ type ToolCallViewModel struct {
 	Result    *structpb.Struct
 	Error     error
 	StartedAt time.Time
+	Progress  []string  // The latest progress lines while the call runs
+	Output    []string  // All progress lines, shown expanded in the result
+	FileEdit  *fileEdit // The change a file-editing call proposes
+	Diff      string    // The change a file-editing call made, once it ran
+}
+
+// addProgress appends a progress line, keeping the last few to show while
+// the call runs and the rest for its result.
+func (vm *ToolCallViewModel) addProgress(line string) {
+	vm.Progress = append(vm.Progress, line)
+	if n := len(vm.Progress); n > maxToolProgressLines {
+		vm.Progress = append([]string(nil), vm.Progress[n-maxToolProgressLines:]...)
+	}
+	vm.Output = append(vm.Output, line)
+	if n := len(vm.Output); n > maxToolOutputLines {
+		vm.Output = append([]string(nil), vm.Output[n-maxToolOutputLines:]...)
+	}
+}
+
+// toolResultStatus returns the status of a tool call that returned err.
+func toolResultStatus(err error) ToolCallStatus {
+	var argsErr *ToolArgumentsError
+	switch {
+	case errors.Is(err, ErrToolCancelled):
+		return ToolCallStatusCancelled
+	case errors.As(err, &argsErr):
+		return ToolCallStatusRejected
+	}
+	return ToolCallStatusCompleted
 }
 
 // Tool status to Unicode glyph mapping
@@ -495,6 +815,8 @@ func toolStatusGlyph(status ToolCallStatus) string {
 		return "✓"
 	case ToolCallStatusRejected:
 		return "✗"
+	case ToolCallStatusCancelled:
+		return "⊘"
 	default:
 		return "?"
 	}
@@ -515,6 +837,11 @@ func (m *Model) executeToolCalls(toolCalls []ToolCall) ([]*ToolResponse, error)
 	// Keep track of which tool calls we've already added to avoid duplicates
 	toolCallIDs := make(map[string]bool)
 
+	// The calls run concurrently, as the tool manager's limit allows
+	var batch []ToolCall
+	var wg sync.WaitGroup
+	results := make([]*ToolResponse, len(toolCalls))
+
 	// Execute each tool call asynchronously
 	for _, call := range toolCalls {
 		// Skip if we've already added this tool call to avoid duplicates
@@ -526,15 +853,13 @@ func (m *Model) executeToolCalls(toolCalls []ToolCall) ([]*ToolResponse, error)
 		// Mark this tool call ID as processed
 		toolCallIDs[call.ID] = true
 
-		// Get or create the view model for this tool call
+		// Get or create the view model for this tool call; it stays pending
+		// until its turn to run comes. File edits note the file's content
+		// first, to record the change the call makes.
+		m.toolFileEdit(call) // tool_diff.go
 		toolVM := m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
 			vm.Name = call.Name
 			vm.Arguments = call.Arguments
-
-			// Only update status if it's not already running
-			if vm.Status == ToolCallStatusPending {
-				vm.Status = ToolCallStatusRunning
-			}
 		})
 
 		// Add a message with the spinner for this tool call if not already in messages
@@ -561,91 +886,201 @@ func (m *Model) executeToolCalls(toolCalls []ToolCall) ([]*ToolResponse, error)
 		// Set a flag to indicate tool processing is happening
 		m.processingTool = true
 
-		// Create a unique context for each tool call
-		callCtx, callCancel := context.WithTimeout(context.Background(), 60*time.Second) // 60 second timeout
+		// Create a unique context for each tool call; the tool's timeout applies
+		callCtx, callCancel := context.WithCancelCause(context.Background())
+		if m.toolCancels == nil {
+			m.toolCancels = make(map[string]context.CancelCauseFunc)
+		}
+		m.toolCancels[call.ID] = callCancel
 
 		// Start a goroutine to execute the tool asynchronously
-		go func(call ToolCall, ctx context.Context, cancel context.CancelFunc, toolVM *ToolCallViewModel) {
-			// Create the response structure
-			result := ToolResponse{
-				Id:   call.ID,
-				Name: call.Name,
-			}
+		batch = append(batch, call)
+		wg.Add(1)
+		go func(i int, call ToolCall, ctx context.Context, cancel context.CancelCauseFunc) {
+			defer wg.Done()
+			defer cancel(nil)
+			started := func() { m.uiUpdateChan <- toolStartedMsg{id: call.ID} }
+			result, err := m.toolManager.ExecuteQueued(ctx, call, started, func(line string) {
+				m.uiUpdateChan <- toolProgressMsg{id: call.ID, line: line}
+			})
+			results[i] = result
 
-			// Check if the tool exists and is available
-			registeredTool, exists := m.toolManager.RegisteredTools[call.Name]
-			if !exists || !registeredTool.IsAvailable {
-				err := fmt.Errorf("tool '%s' not found or not available", call.Name)
-				result.Response = mkErrorResponseStruct(err)
-
-				// Update the view model through the helper
-				m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
-					vm.Status = ToolCallStatusCompleted
-					vm.Error = err
-				})
-
-				m.uiUpdateChan <- toolCallResultMsg{
-					results: []*ToolResponse{&result},
-					call:    call,
-				}
-				cancel()
-				return
+			// Send the result through the channel; Update records it in the
+			// view model
+			m.uiUpdateChan <- toolCallResultMsg{
+				results: []*ToolResponse{result},
+				call:    call,
+				err:     err,
 			}
+		}(len(batch)-1, call, callCtx, callCancel)
+	}
 
-			// Execute the tool handler
-			response, err := registeredTool.Handler(call.Arguments)
+	// Send the results to the model together, in the order of the calls
+	go func() {
+		wg.Wait()
+		m.uiUpdateChan <- toolBatchResultMsg{results: results[:len(batch)]}
+	}()
 
-			// Check if context was canceled
-			if ctx.Err() != nil {
-				err := fmt.Errorf("tool call canceled: %v", ctx.Err())
-				result.Response = mkErrorResponseStruct(err)
+	// Return empty results - actual results will come asynchronously via messages
+	return nil, nil
+}
 
-				// Update the view model through the helper
-				m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
-					vm.Status = ToolCallStatusCompleted
-					vm.Error = err
-				})
+// handleToolCallResult records the result of an asynchronous tool call, unless
+// the server cancelled the call meanwhile. The results of calls made together
+// are sent to the model by handleToolBatchResult.
+func (m *Model) handleToolCallResult(msg toolCallResultMsg) tea.Cmd {
+	delete(m.toolCancels, msg.call.ID)
+	m.processingTool = len(m.toolCancels) > 0
+	if m.cancelledTools[msg.call.ID] {
+		log.Printf("Dropping result of cancelled tool call %s (%s)", msg.call.Name, msg.call.ID)
+		return nil
+	}
 
-				m.uiUpdateChan <- toolCallResultMsg{
-					results: []*ToolResponse{&result},
-					call:    call,
-				}
-				cancel()
-				return
+	var result *structpb.Struct
+	if len(msg.results) > 0 {
+		result = msg.results[0].Response
+	}
+	vm := m.getOrCreateToolVM(msg.call.ID, func(vm *ToolCallViewModel) {
+		vm.Status = toolResultStatus(msg.err)
+		vm.Result = result
+		vm.Error = msg.err
+	})
+	m.replaceToolCallMessage(*vm)
+	for _, res := range msg.results {
+		m.messages = append(m.messages, m.toolResultMessage(res, ToolCallStatusCompleted)) // tool_diff.go
+	}
+	m.viewport.GotoBottom()
+	return nil
+}
+
+// handleToolBatchResult sends the results of calls made together to the
+// model in the order of the calls, leaving out calls the server cancelled.
+func (m *Model) handleToolBatchResult(msg toolBatchResultMsg) tea.Cmd {
+	var results []*ToolResponse
+	for _, res := range msg.results {
+		if m.cancelledTools[res.Id] {
+			delete(m.cancelledTools, res.Id)
+			continue
+		}
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return m.sendToolResultsCmd(results)
+}
+
+// hasRunningToolCalls reports whether any tool call is running.
+func (m *Model) hasRunningToolCalls() bool {
+	for _, vm := range m.toolCallCache {
+		if vm.Status == ToolCallStatusRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// addToolProgress shows a progress line under a running tool call.
+func (m *Model) addToolProgress(id, line string) {
+	vm, ok := m.toolCallCache[id]
+	if !ok || vm.Status != ToolCallStatusRunning {
+		return // Late output from a call that already ended
+	}
+	vm.addProgress(line)
+	m.replaceToolCallMessage(*vm)
+	m.viewport.GotoBottom()
+}
+
+// cancelRunningToolCalls cancels the tool calls that are running, whether in
+// a chat turn or a Live session. Each returns a cancelled response to the
+// model. It reports whether any call was cancelled.
+func (m *Model) cancelRunningToolCalls() bool {
+	cancelled := false
+	for id, vm := range m.toolCallCache {
+		if vm.Status != ToolCallStatusRunning {
+			continue
+		}
+		if cancel, ok := m.toolCancels[id]; ok {
+			cancel(ErrToolCancelled)
+		} else if m.chat == nil || !m.chat.CancelToolCall(id) {
+			continue
+		}
+		log.Printf("Cancelling tool call %s (%s)", vm.Name, id)
+		m.messages = append(m.messages, formatMessage(senderNameSystem, fmt.Sprintf("Cancelling tool call '%s'...", vm.Name)))
+		cancelled = true
+	}
+	return cancelled
+}
+
+// cancelToolCalls handles a server cancellation of earlier tool calls: running
+// calls are stopped and their results dropped, and calls still waiting for
+// approval are removed from the approval queue.
+func (m *Model) cancelToolCalls(ids []string) {
+	for _, id := range ids {
+		name := id
+		if vm, ok := m.toolCallCache[id]; ok && vm.Name != "" {
+			name = vm.Name
+		}
+
+		if cancel, ok := m.toolCancels[id]; ok {
+			if m.cancelledTools == nil {
+				m.cancelledTools = make(map[string]bool)
 			}
+			m.cancelledTools[id] = true
+			cancel(nil)
+		}
 
-			// Create the response map
-			resp := map[string]any{
-				"result": response,
+		for i, call := range m.pendingToolCalls {
+			if call.ID != id {
+				continue
 			}
-			if err != nil {
-				resp["error"] = err.Error()
+			name = call.Name
+			m.pendingToolCalls = append(m.pendingToolCalls[:i:i], m.pendingToolCalls[i+1:]...)
+			if i < m.approvalIndex {
+				m.approvalIndex--
 			}
-			result.Response, _ = structpb.NewStruct(resp)
+			break
+		}
 
-			// Update the view model through the helper
-			updatedVM := m.getOrCreateToolVM(call.ID, func(vm *ToolCallViewModel) {
-				vm.Status = ToolCallStatusCompleted
-				vm.Result = result.Response
-				if err != nil {
-					vm.Error = err
-				}
-			})
+		vm := m.getOrCreateToolVM(id, func(vm *ToolCallViewModel) {
+			vm.Status = ToolCallStatusRejected
+			vm.Error = fmt.Errorf("cancelled by the model")
+		})
+		m.replaceToolCallMessage(*vm)
+		m.messages = append(m.messages, formatMessage(senderNameSystem, fmt.Sprintf("Tool call '%s' was cancelled by the model.", name)))
+	}
 
-			// Send the result through the channel
-			m.uiUpdateChan <- toolCallResultMsg{
-				results:   []*ToolResponse{&result},
-				call:      call,
-				viewModel: updatedVM,
-			}
+	if m.approvalIndex >= len(m.pendingToolCalls) {
+		m.showToolApproval = false
+		m.pendingToolCalls = nil
+		m.approvalIndex = 0
+		m.runApprovedToolCalls()
+	}
+	m.viewport.GotoBottom()
+}
 
-			// Clean up
-			cancel()
-		}(call, callCtx, callCancel, toolVM)
+// runApprovedToolCalls executes the calls approved from the approval queue
+// together, so their results go back to the model in the order of the calls.
+func (m *Model) runApprovedToolCalls() {
+	calls := m.approvedToolCalls
+	m.approvedToolCalls = nil
+	if len(calls) == 0 {
+		return
 	}
+	if _, err := m.executeToolCalls(calls); err != nil {
+		log.Printf("Error executing tool calls: %v", err)
+		m.messages = append(m.messages, formatError(fmt.Errorf("error executing tool calls: %w", err)))
+	}
+}
 
-	// Return empty results - actual results will come asynchronously via messages
-	return nil, nil
+// replaceToolCallMessage updates the chat message showing a tool call.
+func (m *Model) replaceToolCallMessage(vm ToolCallViewModel) {
+	for i, msg := range m.messages {
+		if msg.IsToolCall() && msg.ToolCall.ID == vm.ID {
+			m.messages[i] = formatToolCallMessageFromViewModel(vm)
+			return
+		}
+	}
 }
 
 // sendToolResultsCmd creates a command that sends tool results back to the model
@@ -787,6 +1222,9 @@ type FileToolDefinition struct {
 	Parameters  json.RawMessage `json:"parameters"`        // Read as raw JSON first
 	Handler     string          `json:"handler"`           // Custom field for defining handler type
 	Command     string          `json:"command,omitempty"` // Custom field used by specific handlers
+	Timeout     string          `json:"timeout,omitempty"` // How long a call may run, e.g. "30s"
+	// ParallelSafe set to false makes calls run alone, never alongside other tool calls
+	ParallelSafe *bool `json:"parallel_safe,omitempty"`
 }
 
 // LoadToolsFromFile loads tool definitions from a JSON file
@@ -824,26 +1262,50 @@ func LoadToolsFromFile(filePath string, tm *ToolManager) error {
 			continue
 		}
 
-		// Create the handler based on the FileToolDefinition
-		handler, err := createHandlerForFileDefinition(def)
-		if err != nil {
-			log.Printf("Warning: Skipping tool '%s': %v", def.Name, err)
-			continue
+		var timeout time.Duration
+		if def.Timeout != "" {
+			d, err := time.ParseDuration(def.Timeout)
+			if err != nil || d <= 0 {
+				log.Printf("Warning: Skipping tool '%s' due to invalid timeout %q", def.Name, def.Timeout)
+				continue
+			}
+			timeout = d
 		}
 
-		// Register the tool using the parsed definition and handler
-		// Pass def.Parameters (json.RawMessage) directly; RegisterTool handles conversion.
-		err = tm.RegisterTool(
-			def.Name,
-			def.Description,
-			def.Parameters, // Pass as json.RawMessage
-			handler,
-		)
+		// Custom tools run an executable, which streams progress and can be cancelled
+		if def.Handler == "custom" {
+			if timeout == 0 {
+				timeout = customToolTimeout
+			}
+			err = tm.RegisterStreamingTool(def.Name, def.Description, def.Parameters, commandToolHandler(def.Command))
+		} else if handler, ok := fileToolHandlers[def.Handler]; ok {
+			// Built-in file tools, confined to the workspace
+			err = tm.RegisterStreamingTool(def.Name, def.Description, def.Parameters, tm.fileToolHandler(handler))
+		} else {
+			// Create the handler based on the FileToolDefinition
+			var handler func(json.RawMessage) (any, error)
+			handler, err = createHandlerForFileDefinition(def)
+			if err != nil {
+				log.Printf("Warning: Skipping tool '%s': %v", def.Name, err)
+				continue
+			}
+
+			// Register the tool using the parsed definition and handler
+			// Pass def.Parameters (json.RawMessage) directly; RegisterTool handles conversion.
+			err = tm.RegisterTool(
+				def.Name,
+				def.Description,
+				def.Parameters, // Pass as json.RawMessage
+				handler,
+			)
+		}
 		if err != nil {
 			log.Printf("Warning: Failed to register tool '%s': %v", def.Name, err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
+		tm.SetToolTimeout(def.Name, timeout)
+		tm.SetToolExclusive(def.Name, def.ParallelSafe != nil && !*def.ParallelSafe)
 		log.Printf("Registered tool from file: %s", def.Name)
 	}
 
@@ -852,11 +1314,8 @@ func LoadToolsFromFile(filePath string, tm *ToolManager) error {
 
 // ExecuteCommandTool executes a shell command and returns the result
 func ExecuteCommandTool(command string, args []string, timeout time.Duration) (string, error) {
-	ctx := context.Background()
-	var cancel context.CancelFunc
-
 	// TODO: configurable prefix?
-	command = fmt.Sprintf("aistudio-tool-%s", command)
+	command = toolExecutablePrefix + command
 
 	// Check if the command exists in PATH
 	execPath, found := findExecutableInPath(command)
@@ -864,14 +1323,34 @@ func ExecuteCommandTool(command string, args []string, timeout time.Duration) (s
 		return "", fmt.Errorf("command '%s' not found in PATH", command)
 	}
 	// Use the full path to the executable
-	command = execPath
-
+	ctx := context.Background()
 	if timeout > 0 {
+		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
+	return runToolExecutable(ctx, execPath, args, nil)
+}
 
+// commandToolHandler returns a handler running the aistudio-tool-<command>
+// executable with the call arguments as JSON.
+func commandToolHandler(command string) ToolStreamHandler {
+	return func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		execPath, found := findExecutableInPath(toolExecutablePrefix + command)
+		if !found {
+			return nil, fmt.Errorf("command '%s' not found in PATH", toolExecutablePrefix+command)
+		}
+		return runToolExecutable(ctx, execPath, []string{string(args)}, progress)
+	}
+}
+
+// runToolExecutable runs a tool executable by path and returns its stdout.
+// Lines the executable writes to stdout or stderr are passed to progress, when
+// set, as they arrive. Cancelling ctx kills the executable.
+func runToolExecutable(ctx context.Context, command string, args []string, progress func(string)) (string, error) {
 	cmd := exec.CommandContext(ctx, command, args...)
+	// Don't wait for children that still hold the output open after a kill
+	cmd.WaitDelay = time.Second
 	// set up very restricted environment:
 	path := os.Getenv("PATH")
 	cmd.Env = []string{
@@ -882,6 +1361,13 @@ func ExecuteCommandTool(command string, args []string, timeout time.Duration) (s
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if progress != nil {
+		outLines, errLines := &lineWriter{emit: progress}, &lineWriter{emit: progress}
+		defer outLines.Flush()
+		defer errLines.Flush()
+		cmd.Stdout = io.MultiWriter(&stdout, outLines)
+		cmd.Stderr = io.MultiWriter(&stderr, errLines)
+	}
 
 	err := cmd.Run()
 	if err != nil {
@@ -891,6 +1377,32 @@ func ExecuteCommandTool(command string, args []string, timeout time.Duration) (s
 	return stdout.String(), nil
 }
 
+// lineWriter passes each complete line written to it to emit.
+type lineWriter struct {
+	emit func(string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.emit(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush emits a final line that has no newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
 // findExecutableInPath checks if an executable exists in the PATH
 func findExecutableInPath(executable string) (string, bool) {
 	// Try to find the executable in PATH
@@ -900,7 +1412,7 @@ func findExecutableInPath(executable string) (string, bool) {
 	}
 
 	// Check if a tool-specific executable exists (aistudio-tool-{name})
-	toolSpecificName := "aistudio-tool-" + executable
+	toolSpecificName := toolExecutablePrefix + executable
 	path, err = exec.LookPath(toolSpecificName)
 	if err == nil {
 		return path, true
@@ -909,7 +1421,9 @@ func findExecutableInPath(executable string) (string, bool) {
 	return "", false
 }
 
-// createHandlerForFileDefinition creates a handler function based on a FileToolDefinition
+// createHandlerForFileDefinition creates a handler function based on a FileToolDefinition.
+// The custom handler and the file tool handlers (see fileToolHandlers) are
+// registered as streaming tools by LoadToolsFromFile instead.
 func createHandlerForFileDefinition(def FileToolDefinition) (func(json.RawMessage) (any, error), error) {
 	switch def.Handler {
 	case "system_info":
@@ -924,23 +1438,11 @@ func createHandlerForFileDefinition(def FileToolDefinition) (func(json.RawMessag
 			return nil, fmt.Errorf("exec_command handler is not implemented yet")
 		}, nil
 
-	case "file_operations":
-		// This handler provides file operations
-		return func(args json.RawMessage) (any, error) {
-			return nil, fmt.Errorf("file_operations handler is not implemented yet")
-		}, nil
-
-	case "read_file":
-		// This handler is defined inline for simplicity, matching RegisterDefaultTools
-		return func(args json.RawMessage) (any, error) {
-			return nil, fmt.Errorf("read_file handler is not implemented yet")
-		}, nil
-
 	case "custom":
 		// Custom handler - delegates to a specific command
 		return func(args json.RawMessage) (any, error) {
 			// Set a default timeout
-			timeout := 5 * time.Second
+			timeout := customToolTimeout
 
 			// Execute the command with args passed as JSON
 			cmdArgs := []string{string(args)}
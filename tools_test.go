@@ -1,13 +1,18 @@
 package aistudio
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
@@ -261,3 +266,364 @@ func TestCreateHandlerForFileDefinitionFixed(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteWithProgress(t *testing.T) {
+	tm := NewToolManager()
+	err := tm.RegisterStreamingTool("wait", "Reports progress, then waits to be stopped", nil,
+		func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+			progress("started")
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := ToolCall{ID: "1", Name: "wait"}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		var lines []string
+		resp, err := tm.ExecuteWithProgress(ctx, call, func(line string) {
+			lines = append(lines, line)
+			cancel(ErrToolCancelled)
+		})
+		if !errors.Is(err, ErrToolCancelled) {
+			t.Fatalf("Expected ErrToolCancelled, got %v", err)
+		}
+		if !resp.Response.Fields["cancelled"].GetBoolValue() || len(lines) != 1 {
+			t.Errorf("Expected a cancelled response after one progress line, got %v and %q", resp.Response, lines)
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		if err := tm.SetToolTimeout("wait", 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := tm.Execute(context.Background(), call)
+		if err == nil || !resp.Response.Fields["timed_out"].GetBoolValue() {
+			t.Errorf("Expected a timed out response, got %v, %v", resp.Response, err)
+		}
+	})
+
+	t.Run("unsendable result", func(t *testing.T) {
+		err := tm.RegisterStreamingTool("latin1", "Returns bytes that are not UTF-8", nil,
+			func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+				return "caf\xe9", nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "2", Name: "latin1"})
+		if err == nil || resp.Response.GetFields()["error"] == nil {
+			t.Errorf("Expected an error response, got %v, %v", resp.Response, err)
+		}
+	})
+}
+
+func TestPrepareToolCall(t *testing.T) {
+	tm := NewToolManager()
+	params := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {"type": "string"},
+			"lines": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 50},
+			"mode": {"type": "string", "enum": ["head", "tail"], "default": "head"}
+		},
+		"required": ["path"]
+	}`)
+	var got json.RawMessage
+	err := tm.RegisterTool("read_lines", "Reads lines from a file", params, func(args json.RawMessage) (any, error) {
+		got = args
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     string
+		want     string
+		problems []string
+	}{
+		{"defaults", `{"path":"go.mod"}`, `{"lines":50,"mode":"head","path":"go.mod"}`, nil},
+		{"no arguments", `null`, "", []string{`$: missing required property "path"`}},
+		{"invalid", `{"path":3,"lines":0,"mode":"middle"}`, "", []string{
+			"$.lines: 0 is less than the minimum 1",
+			`$.mode: "middle" is not one of ["head" "tail"]`,
+			"$.path: expected string, got number",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			call := ToolCall{ID: "1", Name: "read_lines", Arguments: json.RawMessage(tt.args)}
+			resp, err := tm.Execute(context.Background(), call)
+
+			var argsErr *ToolArgumentsError
+			if tt.problems == nil {
+				if err != nil || string(got) != tt.want {
+					t.Errorf("Expected the handler to get %s, got %s (%v)", tt.want, got, err)
+				}
+				return
+			}
+			if !errors.As(err, &argsErr) || fmt.Sprint(argsErr.Problems) != fmt.Sprint(tt.problems) {
+				t.Fatalf("Expected problems %q, got %v", tt.problems, err)
+			}
+			if got != nil {
+				t.Error("Expected the handler not to run")
+			}
+			if n := len(resp.Response.Fields["validation_errors"].GetListValue().GetValues()); n != len(tt.problems) {
+				t.Errorf("Expected %d validation errors in the response, got %v", len(tt.problems), resp.Response)
+			}
+		})
+	}
+}
+
+func TestExecuteQueued(t *testing.T) {
+	tm := NewToolManager()
+	tm.SetConcurrency(2)
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var events []string
+	handler := func(name string) ToolStreamHandler {
+		return func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			events = append(events, name+" started")
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			running--
+			events = append(events, name+" done")
+			mu.Unlock()
+			return "ok", nil
+		}
+	}
+	for _, name := range []string{"read", "write"} {
+		if err := tm.RegisterStreamingTool(name, "Test tool", nil, handler(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tm.SetToolExclusive("write", true); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tm.ExecuteQueued(context.Background(), ToolCall{ID: fmt.Sprint(i), Name: "read"}, func() {}, nil)
+			}()
+		}
+		wg.Wait()
+		if peak != 2 {
+			t.Errorf("Expected at most 2 calls at once, got %d", peak)
+		}
+	})
+
+	t.Run("exclusive", func(t *testing.T) {
+		events = nil
+		var wg sync.WaitGroup
+		for i, name := range []string{"read", "write", "read"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tm.ExecuteQueued(context.Background(), ToolCall{ID: fmt.Sprint(i), Name: name}, func() {}, nil)
+			}()
+			time.Sleep(5 * time.Millisecond)
+		}
+		wg.Wait()
+		for i, entry := range events {
+			if entry == "write started" && (i+1 == len(events) || events[i+1] != "write done") {
+				t.Errorf("Expected write to run alone, got %q", events)
+			}
+		}
+	})
+
+	t.Run("cancelled while queued", func(t *testing.T) {
+		hold, release := make(chan struct{}), make(chan struct{})
+		if err := tm.RegisterStreamingTool("hold", "Holds a slot", nil, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+			close(hold)
+			<-release
+			return "ok", nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tm.SetToolExclusive("hold", true); err != nil {
+			t.Fatal(err)
+		}
+		go tm.ExecuteQueued(context.Background(), ToolCall{ID: "hold", Name: "hold"}, func() {}, nil)
+		<-hold
+		defer close(release)
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(ErrToolCancelled)
+		started := false
+		resp, err := tm.ExecuteQueued(ctx, ToolCall{ID: "queued", Name: "read"}, func() { started = true }, nil)
+		if !errors.Is(err, ErrToolCancelled) || started {
+			t.Errorf("Expected ErrToolCancelled without starting, got %v (started %v)", err, started)
+		}
+		if !resp.Response.Fields["cancelled"].GetBoolValue() {
+			t.Errorf("Expected a cancelled response, got %v", resp.Response)
+		}
+	})
+}
+
+func TestRunToolExecutable(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "aistudio-tool-slow")
+	body := "#!/bin/sh\necho one\necho two >&2\nprintf three\nsleep 30\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		_, err := runToolExecutable(ctx, script, nil, func(line string) { lines <- line })
+		done <- err
+	}()
+
+	// Lines from stdout and stderr arrive while the tool runs
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case line := <-lines:
+			got[line] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for progress, got %v", got)
+		}
+	}
+	if !got["one"] || !got["two"] {
+		t.Errorf("Unexpected progress lines %v", got)
+	}
+
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error from the killed tool")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cancelling did not stop the tool")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Cancelling took %v", elapsed)
+	}
+	// An unterminated last line is reported when the tool exits
+	if line := <-lines; line != "three" {
+		t.Errorf("Expected the final partial line, got %q", line)
+	}
+}
+
+func TestToolProgressView(t *testing.T) {
+	m := &Model{}
+	vm := m.getOrCreateToolVM("1", func(vm *ToolCallViewModel) {
+		vm.Name = "build"
+		vm.Status = ToolCallStatusRunning
+	})
+	m.messages = append(m.messages, formatToolCallMessageFromViewModel(*vm))
+	for i := 1; i <= maxToolProgressLines+2; i++ {
+		m.addToolProgress("1", fmt.Sprintf("step %d", i))
+	}
+
+	content := StripANSI(m.messages[0].Content)
+	if strings.Contains(content, "step 2\n") || !strings.Contains(content, "step 7") {
+		t.Errorf("Expected the last %d progress lines, got:\n%s", maxToolProgressLines, content)
+	}
+
+	cancelled := false
+	m.processingTool = true
+	m.toolCancels = map[string]context.CancelCauseFunc{"1": func(cause error) {
+		cancelled = errors.Is(cause, ErrToolCancelled)
+	}}
+	if !m.cancelRunningToolCalls() || !cancelled {
+		t.Error("Expected the running tool call to be cancelled")
+	}
+}
+
+// TestCancelToolCallsKeepsDraft tests ctrl+k cancels running tool calls
+// without deleting the draft, as the textarea would
+func TestCancelToolCallsKeepsDraft(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	m := New(WithTools(true))
+	m.focusedComponent = "input"
+	m.textarea.Focus()
+	m.textarea.SetValue("keep this draft")
+	m.textarea.CursorStart()
+	m.getOrCreateToolVM("1", func(vm *ToolCallViewModel) {
+		vm.Name = "build"
+		vm.Status = ToolCallStatusRunning
+	})
+	cancelled := false
+	m.processingTool = true
+	m.toolCancels = map[string]context.CancelCauseFunc{"1": func(error) { cancelled = true }}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	m = updated.(*Model)
+	if !cancelled {
+		t.Error("Expected the running tool call to be cancelled")
+	}
+	if got := m.textarea.Value(); got != "keep this draft" {
+		t.Errorf("Expected the draft kept, got %q", got)
+	}
+}
+
+func TestApprovedToolCallsRunTogether(t *testing.T) {
+	cleanup := SetupTestLogging(t)
+	defer cleanup()
+
+	m := New(WithTools(true))
+	for _, name := range []string{"slow", "fast"} {
+		delay := map[string]time.Duration{"slow": 20 * time.Millisecond}[name]
+		if err := m.toolManager.RegisterStreamingTool(name, "Test tool", nil, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+			time.Sleep(delay)
+			return name, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.requireApproval = true
+	m.pendingToolCalls = []ToolCall{{ID: "1", Name: "slow"}, {ID: "2", Name: "fast"}}
+	m.showToolApproval = true
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = updated.(*Model)
+	if len(m.toolCancels) != 0 {
+		t.Fatal("Expected the approved call to wait for the rest of the queue")
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = updated.(*Model)
+
+	// Results are recorded in the view models by Update alone
+	for {
+		msg := <-m.uiUpdateChan
+		if batch, ok := msg.(toolBatchResultMsg); ok {
+			if len(batch.results) != 2 || batch.results[0].Id != "1" || batch.results[1].Id != "2" {
+				t.Fatalf("Expected results of calls 1 and 2 in order, got %v", batch.results)
+			}
+			break
+		}
+		updated, _ = m.Update(msg)
+		m = updated.(*Model)
+	}
+	for _, id := range []string{"1", "2"} {
+		if status := m.toolCallCache[id].Status; status != ToolCallStatusCompleted {
+			t.Errorf("Expected call %s completed, got %s", id, status)
+		}
+	}
+
+	// A start seen after the result leaves the call finished
+	updated, _ = m.Update(toolStartedMsg{id: "1"})
+	m = updated.(*Model)
+	if m.hasRunningToolCalls() {
+		t.Error("Expected no running tool calls")
+	}
+}
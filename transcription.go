@@ -0,0 +1,35 @@
+package aistudio
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transcriptionContinueWindow is how long after the last fragment a new
+// transcription fragment still extends the same message.
+const transcriptionContinueWindow = 5 * time.Second
+
+// appendTranscription adds a fragment of Live API speech transcription to the
+// chat. Fragments arrive a few words at a time, so consecutive fragments from
+// the same speaker extend the latest message instead of starting a new one.
+// The transcript is saved to history like any other message.
+func (m *Model) appendTranscription(sender senderName, text string) tea.Cmd {
+	if text == "" {
+		return nil
+	}
+
+	if n := len(m.messages); n > 0 && m.messages[n-1].Sender == sender &&
+		time.Since(m.messages[n-1].Timestamp) < transcriptionContinueWindow {
+		m.messages[n-1].Content += text
+		m.messages[n-1].Timestamp = time.Now()
+	} else {
+		m.messages = append(m.messages, formatMessage(sender, text))
+	}
+	m.viewport.GotoBottom()
+
+	if m.historyEnabled && m.historyManager != nil {
+		return m.saveSessionCmd()
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package aistudio
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAppendTranscription tests that fragments build one message per speaker turn
+func TestAppendTranscription(t *testing.T) {
+	m := &Model{}
+	m.appendTranscription(senderNameUserSpoken, "What's the ")
+	m.appendTranscription(senderNameUserSpoken, "weather?")
+	m.appendTranscription(senderNameModelSpoken, "It's sunny.")
+	m.appendTranscription(senderNameModelSpoken, "")
+
+	if len(m.messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(m.messages))
+	}
+	if m.messages[0].Sender != senderNameUserSpoken || m.messages[0].Content != "What's the weather?" {
+		t.Errorf("Unexpected user transcript: %+v", m.messages[0])
+	}
+	if m.messages[1].Sender != senderNameModelSpoken || m.messages[1].Content != "It's sunny." {
+		t.Errorf("Unexpected model transcript: %+v", m.messages[1])
+	}
+
+	// A pause starts a new message even from the same speaker
+	m.messages[1].Timestamp = time.Now().Add(-time.Minute)
+	m.appendTranscription(senderNameModelSpoken, "Anything else?")
+	if len(m.messages) != 3 {
+		t.Errorf("Expected a new message after a pause, got %d messages", len(m.messages))
+	}
+}
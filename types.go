@@ -17,6 +17,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/tmc/aistudio/api"
 	"github.com/tmc/aistudio/audioplayer"
+	"github.com/tmc/aistudio/prompts"
 	"github.com/tmc/aistudio/settings"
 )
 
@@ -76,6 +77,7 @@ type Message struct {
 	ID        string     // Unique identifier for the message
 	Sender    senderName // Who sent the message (You, Gemini, System)
 	Content   string     // The message text
+	Thoughts  string     // Thought summary that preceded the answer, if requested
 	HasAudio  bool       // Whether the message has associated audio
 	AudioData []byte     // The raw audio data (if HasAudio is true) - stores the *complete* audio after consolidation
 	IsPlaying bool       // Whether the audio is currently playing
@@ -85,6 +87,7 @@ type Message struct {
 	ToolStatus ToolCallStatus // Status of the tool call (e.g., PENDING, APPROVED, REJECTED)
 
 	ToolResponse *ToolResponse // The tool result associated with this message (if any)
+	ToolOutput   []string      // Progress lines the tool call reported, such as a sub-agent's transcript
 
 	IsExecutableCode       bool                  // Whether this message contains executable code
 	ExecutableCode         *ExecutableCode       // The executable code associated with this message (if any)
@@ -93,6 +96,11 @@ type Message struct {
 
 	// Safety ratings for this message's content
 	SafetyRatings []*SafetyRating // Safety ratings associated with this message
+	BlockReason   string          // Why the prompt or response was blocked, if it was
+
+	// Structured output validation against the response schema
+	SchemaChecked bool     // Whether the content was validated as JSON against the schema
+	SchemaErrors  []string // Validation problems, empty if the content matched
 
 	// Grounding information
 	HasGroundingMetadata bool               // Whether this message has grounding metadata
@@ -103,6 +111,11 @@ type Message struct {
 
 	HasTokenInfo bool // Whether token count information is available for this message
 
+	// Regenerated responses
+	Alternates     []string // All versions of this response, oldest first (empty if never regenerated)
+	AlternateIndex int      // Which entry of Alternates is currently shown in Content
+	Stopped        bool     // Whether the user stopped generation before the response completed
+
 	Timestamp time.Time // When the message was sent
 }
 
@@ -118,6 +131,7 @@ type TokenCounts struct {
 	PromptTokenCount   int32 // Number of tokens in the prompt
 	ResponseTokenCount int32 // Number of tokens in the response
 	TotalTokenCount    int32 // Total tokens used (prompt + response)
+	ThoughtsTokenCount int32 // Tokens spent thinking (included in TotalTokenCount)
 }
 
 // Component is the interface that all UI components should implement
@@ -159,6 +173,11 @@ type Model struct {
 	stream     generativelanguagepb.GenerativeService_StreamGenerateContentClient
 	bidiStream generativelanguagepb.GenerativeService_StreamGenerateContentClient
 
+	// Conversation turns outside Live sessions go through the chat (chat.go)
+	chat          *Chat
+	chatStream    *ChatStream
+	chatApprovals map[string]chan *ToolCall // Replies for chat tool calls awaiting approval
+
 	currentState AppState // Current state of the application
 
 	useBidi  bool      // Whether to use BidiGenerateContent (true) or StreamGenerateContent (false)
@@ -193,6 +212,13 @@ type Model struct {
 	playerCmd       string      // Config: Command to play raw PCM audio
 	showLogo        bool        // Whether to show a logo or not
 
+	// Generation control
+	stopRequested bool             // Set when the user stops a response; swallows the resulting cancellation error
+	regenerate    *regenerateState // Previous answers to attach to the next response after a regenerate
+
+	// Credential sources used when no API key is passed directly
+	apiKeySources map[BackendType]api.KeySource // Per-backend api_key_command/keyring lookup
+
 	// Generation parameters
 	temperature     float32 // Controls randomness (0.0-1.0)
 	topP            float32 // Controls diversity (0.0-1.0)
@@ -211,6 +237,18 @@ type Model struct {
 	responseSchemaFile  string // Path to JSON schema file defining response structure
 	displayTokenCounts  bool   // Whether to display token counts in the UI
 
+	// Structured output validation; see structured_output.go
+	responseSchema   *generativelanguagepb.Schema // Loaded from responseSchemaFile
+	schemaRetries    int                          // How many times to ask the model to fix an invalid response
+	schemaRetryCount int                          // Retries used for the current prompt
+
+	safetySettings []*generativelanguagepb.SafetySetting // Per-category block thresholds (nil = server defaults)
+
+	// Thinking (Gemini 2.5 models)
+	thinkingBudget  *int32 // Thinking token budget (nil = model default)
+	includeThoughts bool   // Ask for thought summaries
+	detailsExpanded bool   // Show thought summaries and tool output in full instead of collapsed
+
 	// Log Messages
 	logMessages     []string // Stores recent log messages
 	maxLogMessages  int      // Maximum number of log messages to store
@@ -257,22 +295,37 @@ type Model struct {
 	// History management
 	historyManager *HistoryManager // Manages chat history
 	historyEnabled bool            // Whether history is enabled
+	inputHistory   *InputHistory   // Previously sent prompts, recalled with up/down
+
+	// Prompt templates
+	promptLibrary  *prompts.Library // Reusable prompts loaded from the prompts directories
+	templatePicker *templatePicker  // Non-nil while the template picker is open
 
 	// Tool calling support
-	enableTools       bool                          // Whether tool calling is enabled
-	toolManager       *ToolManager                  // Tool manager for handling tools
-	activeToolCall    *ToolCall                     // Currently active tool call, if any
-	processingTool    bool                          // Whether a tool call is being processed
-	pendingToolCalls  []ToolCall                    // Tool calls waiting for approval
-	showToolApproval  bool                          // Whether to show the tool approval modal
-	approvalIndex     int                           // Current tool call being approved
-	requireApproval   bool                          // Whether tool calls require approval
-	approvedToolTypes map[string]bool               // Tool types that don't need approval anymore
-	toolCallCache     map[string]*ToolCallViewModel // Cache of tool calls by ID for UI state
+	enableTools       bool                               // Whether tool calling is enabled
+	toolManager       *ToolManager                       // Tool manager for handling tools
+	activeToolCall    *ToolCall                          // Currently active tool call, if any
+	processingTool    bool                               // Whether a tool call is being processed
+	pendingToolCalls  []ToolCall                         // Tool calls waiting for approval
+	approvedToolCalls []ToolCall                         // Approved calls, run together once the approval queue empties
+	showToolApproval  bool                               // Whether to show the tool approval modal
+	approvalIndex     int                                // Current tool call being approved
+	requireApproval   bool                               // Whether tool calls require approval
+	approvedToolTypes map[string]bool                    // Tool types that don't need approval anymore
+	toolCallCache     map[string]*ToolCallViewModel      // Cache of tool calls by ID for UI state
+	toolCancels       map[string]context.CancelCauseFunc // Cancels running tool calls by ID
+	cancelledTools    map[string]bool                    // Tool calls withdrawn by the server; results are dropped
+	toolSources       []*toolSource                      // Tools file and directory, reloaded when they change
 
 	// System prompt
 	systemPrompt string // System prompt to use for the conversation
 
+	// Live API context window compression
+	contextCompression       bool  // Drop the oldest turns instead of ending the session at the context limit
+	compressionTriggerTokens int64 // Context size that triggers compression (0 = server default)
+	compressionTargetTokens  int64 // Context size to compress down to (0 = server default)
+	sessionTokenCount        int32 // Latest total token usage reported for the live session
+
 	// Experimental integrations moved to .wip files
 	// TODO: Re-enable when stabilized
 }
@@ -444,13 +497,8 @@ func (m *Model) ProcessGenerativeLanguageResponse(output api.StreamOutput) {
 
 		log.Printf("Adding %d safety ratings to message %d", len(output.SafetyRatings), idx)
 
-		// Convert each safety rating to our internal format
-		for _, apiRating := range output.SafetyRatings {
-			safetyRating := convertSafetyRating(apiRating)
-			if safetyRating != nil {
-				m.messages[idx].SafetyRatings = append(m.messages[idx].SafetyRatings, safetyRating)
-			}
-		}
+		// Each chunk carries the ratings so far, so the latest replace earlier ones
+		m.messages[idx].SafetyRatings = NewAPIFormatter().ConvertSafetyRatings(output.SafetyRatings)
 
 		// Update the viewport content
 		m.viewport.SetContent(m.renderAllMessages())
@@ -468,6 +516,7 @@ func (m *Model) ProcessGenerativeLanguageResponse(output api.StreamOutput) {
 			PromptTokenCount:   output.PromptTokenCount,
 			ResponseTokenCount: output.CandidateTokenCount,
 			TotalTokenCount:    output.TotalTokenCount,
+			ThoughtsTokenCount: output.ThoughtsTokenCount,
 		}
 		m.messages[idx].HasTokenInfo = true
 
@@ -499,6 +548,8 @@ type GroundingChunk struct {
 // GroundingSupport represents support for a specific claim in the response
 type GroundingSupport struct {
 	Text           string            // The text segment this support applies to
+	StartIndex     int               // Byte offset of the segment start in the response text
+	EndIndex       int               // Byte offset of the segment end (exclusive) in the response text
 	ChunkIndices   []int             // Indices to grounding chunks supporting this segment
 	ChunksSelected []*GroundingChunk // The actual supporting chunks
 	Confidence     []float32         // Confidence scores for each supporting chunk
@@ -0,0 +1,374 @@
+package aistudio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tmc/aistudio/internal/htmlmd"
+	"golang.org/x/net/html/charset"
+)
+
+// Defaults for the web_fetch tool.
+const (
+	defaultWebFetchMaxBytes = 5 << 20 // Largest response body read
+	defaultWebFetchCacheTTL = 15 * time.Minute
+	maxWebFetchBytes        = 100_000 // Longest content returned to the model
+	maxWebFetchRedirects    = 10
+)
+
+// ErrDomainNotAllowed is returned when web_fetch is asked for, or redirected
+// to, a host outside its allowlist.
+var ErrDomainNotAllowed = errors.New("domain is not in the web fetch allowlist")
+
+// ErrPrivateAddress is returned when web_fetch, without an allowlist, would
+// connect to a loopback, private, link-local or unspecified address, such as
+// a cloud metadata service.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// WebFetcher fetches web pages for the web_fetch tool, converting HTML to
+// markdown and caching responses on disk.
+type WebFetcher struct {
+	Client       *http.Client  // Defaults to the client made by NewWebFetcher
+	AllowDomains []string      // Hosts that may be fetched, with their subdomains; empty allows any public host
+	MaxBytes     int64         // Largest response body read; longer bodies are cut short
+	CacheDir     string        // Directory for cached responses; empty disables the cache
+	CacheTTL     time.Duration // How long cached responses are used
+	UserAgent    string
+}
+
+// NewWebFetcher returns a fetcher limited to the allowDomains hosts, or to
+// any public host when allowDomains is empty, caching responses in the user
+// cache directory.
+func NewWebFetcher(allowDomains []string) *WebFetcher {
+	f := &WebFetcher{
+		AllowDomains: allowDomains,
+		CacheDir:     DefaultWebCacheDir(),
+	}
+	// Addresses are checked as connections are made, after DNS resolution
+	// and on every redirect. Proxies are not used, as they would connect
+	// on the fetcher's behalf.
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return f.checkAddress(address)
+		},
+	}
+	f.Client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+	return f
+}
+
+// DefaultWebCacheDir returns the directory web_fetch caches responses in by
+// default, or "" if there is no user cache directory.
+func DefaultWebCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "aistudio", "web")
+}
+
+// WebPage is a fetched page, as cached on disk.
+type WebPage struct {
+	URL         string    `json:"url"`
+	FinalURL    string    `json:"final_url"` // After redirects
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	Truncated   bool      `json:"truncated"` // Body was longer than MaxBytes
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// allowed reports whether host may be fetched.
+func (f *WebFetcher) allowed(host string) bool {
+	if len(f.AllowDomains) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range f.AllowDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddress reports why the resolved address, a host and port, may not be
+// connected to. Without an allowlist only public addresses may be; an
+// allowlist may name local hosts on purpose.
+func (f *WebFetcher) checkAddress(address string) error {
+	if len(f.AllowDomains) > 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsInterfaceLocalMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%s: %w", ip, ErrPrivateAddress)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which is
+// not public either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// checkURL reports why u may not be fetched, if it may not.
+func (f *WebFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q (want http or https)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("URL %q has no host", u)
+	}
+	if !f.allowed(u.Hostname()) {
+		return fmt.Errorf("%s: %w", u.Hostname(), ErrDomainNotAllowed)
+	}
+	return nil
+}
+
+// Fetch returns the page at rawURL, from the cache when it holds a fresh
+// copy.
+func (f *WebFetcher) Fetch(ctx context.Context, rawURL string) (page *WebPage, cached bool, err error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, false, err
+	}
+	u.Fragment = ""
+	if page := f.cached(u.String()); page != nil {
+		return page, true, nil
+	}
+
+	client := f.Client
+	if client == nil {
+		client = NewWebFetcher(f.AllowDomains).Client
+	}
+	// Follow redirects only to hosts that may be fetched
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxWebFetchRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxWebFetchRedirects)
+		}
+		return f.checkURL(req.URL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	userAgent := f.UserAgent
+	if userAgent == "" {
+		userAgent = "aistudio-web-fetch/1.0"
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, text/markdown, text/plain, application/json;q=0.9, */*;q=0.5")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultWebFetchMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read response: %w", err)
+	}
+	page = &WebPage{
+		URL:         u.String(),
+		FinalURL:    resp.Request.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+		FetchedAt:   time.Now(),
+	}
+	if int64(len(body)) > maxBytes {
+		page.Body, page.Truncated = body[:maxBytes], true
+	}
+	if resp.StatusCode == http.StatusOK {
+		f.store(page)
+	}
+	return page, false, nil
+}
+
+// cacheFile returns the file caching the page at rawURL, or "" when the cache
+// is disabled.
+func (f *WebFetcher) cacheFile(rawURL string) string {
+	if f.CacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(f.CacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cached returns the cached page at rawURL, or nil if there is no fresh copy.
+func (f *WebFetcher) cached(rawURL string) *WebPage {
+	file := f.cacheFile(rawURL)
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var page WebPage
+	if err := json.Unmarshal(data, &page); err != nil || page.URL != rawURL {
+		return nil
+	}
+	ttl := f.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultWebFetchCacheTTL
+	}
+	if time.Since(page.FetchedAt) > ttl {
+		return nil
+	}
+	return &page
+}
+
+// store caches page, logging failures: the fetch itself succeeded.
+func (f *WebFetcher) store(page *WebPage) {
+	file := f.cacheFile(page.URL)
+	if file == "" {
+		return
+	}
+	data, err := json.Marshal(page)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(file), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(file, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to cache %s: %v", page.URL, err)
+	}
+}
+
+// Markdown returns the page's content as UTF-8 text for the model: HTML
+// converted to markdown, and text formats as they are. The body is decoded
+// from its declared or detected charset.
+func (p *WebPage) Markdown() (title, content string, err error) {
+	mediaType, _, _ := mime.ParseMediaType(p.ContentType)
+	if mediaType == "" {
+		mediaType = http.DetectContentType(p.Body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	switch {
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		base, _ := url.Parse(p.FinalURL)
+		doc, err := htmlmd.Convert(bytes.NewReader(p.decodedBody()), base)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to convert HTML: %w", err)
+		}
+		return strings.ToValidUTF8(doc.Title, "\uFFFD"), strings.ToValidUTF8(doc.Markdown, "\uFFFD"), nil
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/json",
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/xml", mediaType == "application/javascript":
+		return "", strings.ToValidUTF8(string(p.decodedBody()), "\uFFFD"), nil
+	}
+	return "", "", fmt.Errorf("unsupported content type %q", mediaType)
+}
+
+// decodedBody returns the body converted to UTF-8, or as it is when its
+// charset is unknown.
+func (p *WebPage) decodedBody() []byte {
+	r, err := charset.NewReader(bytes.NewReader(p.Body), p.ContentType)
+	if err != nil {
+		return p.Body
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return p.Body
+	}
+	return body
+}
+
+// RegisterWebFetchTool registers the web_fetch tool, which fetches pages
+// with f.
+func (tm *ToolManager) RegisterWebFetchTool(f *WebFetcher) error {
+	tm.webFetcher = f
+	description := "Fetch a web page by URL and return its content, with HTML converted to markdown."
+	if len(f.AllowDomains) > 0 {
+		description += " Only these domains and their subdomains may be fetched: " + strings.Join(f.AllowDomains, ", ") + "."
+	}
+	parameters := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"url": {"type": "string", "description": "The http or https URL to fetch"}
+		},
+		"required": ["url"]
+	}`)
+	return tm.RegisterStreamingTool("web_fetch", description, parameters, func(ctx context.Context, args json.RawMessage, progress func(string)) (any, error) {
+		var params struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+		progress("Fetching " + params.URL)
+		page, cached, err := f.Fetch(ctx, params.URL)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]any{
+			"url":          page.FinalURL,
+			"status":       page.Status,
+			"content_type": page.ContentType,
+			"cached":       cached,
+		}
+		title, content, err := page.Markdown()
+		if err != nil {
+			return result, err
+		}
+		truncated := page.Truncated
+		if len(content) > maxWebFetchBytes {
+			// Cut on a rune boundary
+			n := maxWebFetchBytes
+			for n > 0 && !utf8.RuneStart(content[n]) {
+				n--
+			}
+			content, truncated = content[:n], true
+		}
+		if title != "" {
+			result["title"] = title
+		}
+		result["content"] = content
+		result["truncated"] = truncated
+		if page.Status >= 400 {
+			return result, fmt.Errorf("HTTP %d fetching %s", page.Status, page.FinalURL)
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,213 @@
+package aistudio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tmc/aistudio/internal/httprr"
+)
+
+// failingTransport fails every request, showing a response came from the
+// cache.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("unexpected request")
+}
+
+func TestWebFetch(t *testing.T) {
+	rr, err := httprr.Open("testdata/web_fetch.httprr.txt", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rr.Close()
+
+	f := &WebFetcher{
+		Client:       rr.Client(),
+		AllowDomains: []string{"example.com"},
+		CacheDir:     t.TempDir(),
+	}
+	tm := NewToolManager()
+	if err := tm.RegisterWebFetchTool(f); err != nil {
+		t.Fatal(err)
+	}
+	fetch := func(url string) (map[string]any, error) {
+		t.Helper()
+		args, _ := json.Marshal(map[string]string{"url": url})
+		resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: "web_fetch", Arguments: args})
+		result, _ := resp.Response.AsMap()["result"].(map[string]any)
+		return result, err
+	}
+
+	t.Run("html", func(t *testing.T) {
+		got, err := fetch("https://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "# Example Domain\n\nThis domain is for use in illustrative examples in documents. You may use this domain in literature without prior coordination or asking for permission.\n\n[More information...](https://www.iana.org/domains/example)"
+		if got["content"] != want {
+			t.Errorf("Got content\n%s\nwant\n%s", got["content"], want)
+		}
+		if got["title"] != "Example Domain" || got["cached"] != false {
+			t.Errorf("Got title %v, cached %v", got["title"], got["cached"])
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		f.Client = &http.Client{Transport: failingTransport{}}
+		defer func() { f.Client = rr.Client() }()
+		got, err := fetch("https://example.com/#top")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["cached"] != true || !strings.HasPrefix(got["content"].(string), "# Example Domain") {
+			t.Errorf("Expected the cached page, got %v", got)
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		got, err := fetch("https://www.example.com/robots.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["content"] != "User-agent: *\nDisallow:\n" {
+			t.Errorf("Got content %q", got["content"])
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		if _, err := fetch("https://example.org/"); !errors.Is(err, ErrDomainNotAllowed) {
+			t.Errorf("Expected ErrDomainNotAllowed, got %v", err)
+		}
+		if _, err := fetch("https://notexample.com/"); !errors.Is(err, ErrDomainNotAllowed) {
+			t.Errorf("Expected ErrDomainNotAllowed, got %v", err)
+		}
+		if _, err := fetch("file:///etc/passwd"); err == nil || !strings.Contains(err.Error(), "unsupported URL scheme") {
+			t.Errorf("Expected an unsupported scheme error, got %v", err)
+		}
+	})
+
+	t.Run("redirect not allowed", func(t *testing.T) {
+		if _, err := fetch("https://example.com/moved"); !errors.Is(err, ErrDomainNotAllowed) {
+			t.Errorf("Expected ErrDomainNotAllowed for a redirect to another domain, got %v", err)
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		got, err := fetch("https://example.com/missing")
+		if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+			t.Errorf("Expected an HTTP 404 error, got %v", err)
+		}
+		if got["content"] != "# Not Found" {
+			t.Errorf("Expected the error page's content, got %v", got["content"])
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		f.MaxBytes = 100
+		defer func() { f.MaxBytes = 0 }()
+		f.CacheDir = ""
+		page, _, err := f.Fetch(context.Background(), "https://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Body) != 100 || !page.Truncated {
+			t.Errorf("Expected 100 bytes of a truncated body, got %d bytes, truncated %v", len(page.Body), page.Truncated)
+		}
+	})
+}
+
+// TestWebPageMarkdownCharset tests pages in other charsets are returned as
+// UTF-8
+func TestWebPageMarkdownCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		page WebPage
+		want string
+	}{
+		{"declared html", WebPage{ContentType: "text/html; charset=iso-8859-1", Body: []byte("<p>caf\xe9</p>")}, "café"},
+		{"meta html", WebPage{ContentType: "text/html", Body: []byte("<meta charset=\"windows-1252\"><p>caf\xe9</p>")}, "café"},
+		{"declared text", WebPage{ContentType: "text/plain; charset=iso-8859-1", Body: []byte("caf\xe9")}, "café"},
+		{"utf-8 text", WebPage{ContentType: "text/plain; charset=utf-8", Body: []byte("café")}, "café"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := tt.page.Markdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Got content %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebFetchCheckAddress(t *testing.T) {
+	f := &WebFetcher{}
+	for _, tt := range []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"192.168.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.100.100.200:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[fe80::1]:80", false},
+	} {
+		err := f.checkAddress(tt.address)
+		if tt.public && err != nil {
+			t.Errorf("checkAddress(%s) = %v, want nil", tt.address, err)
+		}
+		if !tt.public && !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("checkAddress(%s) = %v, want ErrPrivateAddress", tt.address, err)
+		}
+	}
+}
+
+// TestWebFetchLocalServer tests local servers may only be fetched when
+// allowlisted, and long content is cut on a rune boundary
+func TestWebFetchLocalServer(t *testing.T) {
+	body := strings.Repeat("é", maxWebFetchBytes) // Two bytes each
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "x"+body)
+	}))
+	defer srv.Close()
+
+	f := NewWebFetcher(nil)
+	f.CacheDir = ""
+	if _, _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Expected ErrPrivateAddress without an allowlist, got %v", err)
+	}
+
+	f = NewWebFetcher([]string{"127.0.0.1"})
+	f.CacheDir = ""
+	tm := NewToolManager()
+	if err := tm.RegisterWebFetchTool(f); err != nil {
+		t.Fatal(err)
+	}
+	args, _ := json.Marshal(map[string]string{"url": srv.URL})
+	resp, err := tm.Execute(context.Background(), ToolCall{ID: "1", Name: "web_fetch", Arguments: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := resp.Response.AsMap()["result"].(map[string]any)
+	content := result["content"].(string)
+	if len(content) != maxWebFetchBytes-1 || !utf8.ValidString(content) || result["truncated"] != true {
+		t.Errorf("Expected %d bytes of valid UTF-8, got %d bytes, valid %v", maxWebFetchBytes-1, len(content), utf8.ValidString(content))
+	}
+}